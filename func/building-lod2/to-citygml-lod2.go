@@ -65,6 +65,7 @@ type Building struct {
 	RelativeToTerrain  string                    `xml:"core:relativeToTerrain,omitempty"`
 	MeasureAttribute   *MeasureAttribute         `xml:"gen:measureAttribute,omitempty"`
 	StringAttributes   []StringAttribute         `xml:"gen:stringAttribute,omitempty"`
+	DoubleAttributes   []DoubleAttribute         `xml:"gen:doubleAttribute,omitempty"`
 	Type               string                    `xml:"bldg:type,omitempty"`
 	Class              Class                     `xml:"bldg:class,omitempty"`
 	Function           Function                  `xml:"bldg:function,omitempty"`
@@ -92,6 +93,11 @@ type StringAttribute struct {
 	Value string `xml:"gen:value"`
 }
 
+type DoubleAttribute struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"gen:value"`
+}
+
 type Class struct {
 	Value     string `xml:",chardata"`
 	CodeSpace string `xml:"codeSpace,attr,omitempty"`
@@ -231,10 +237,11 @@ func main() {
 	inputDir := flag.String("input", "", "Directory containing OBJ files")
 	outputDir := flag.String("output", "", "Directory for output CityGML files")
 	epsgCode := flag.String("epsg", "32748", "EPSG code for the coordinate reference system")
+	injectRoofPitch := flag.Bool("inject-roof-pitch", false, "Add the area-weighted roof pitch as a buildingRoofPitch gen:doubleAttribute")
 	flag.Parse()
 
 	if *inputDir == "" || *outputDir == "" {
-		fmt.Println("Usage: obj2citygml -input <input_directory> -output <output_directory> [-epsg <epsg_code>]")
+		fmt.Println("Usage: obj2citygml -input <input_directory> -output <output_directory> [-epsg <epsg_code>] [-inject-roof-pitch]")
 		return
 	}
 
@@ -261,7 +268,7 @@ func main() {
 		fileNameWithoutExt := strings.TrimSuffix(baseFileName, filepath.Ext(baseFileName))
 		outputFile := filepath.Join(*outputDir, fileNameWithoutExt+".gml")
 
-		err := convertOBJToCityGML(objFile, outputFile, fileNameWithoutExt, *epsgCode)
+		err := convertOBJToCityGML(objFile, outputFile, fileNameWithoutExt, *epsgCode, *injectRoofPitch)
 		if err != nil {
 			fmt.Printf("Error processing %s: %v\n", baseFileName, err)
 			errorFiles = append(errorFiles, baseFileName)
@@ -436,7 +443,7 @@ func classifySurface(face OBJFace, vertices []OBJVertex, material string) string
 }
 
 // Convert OBJ file to CityGML
-func convertOBJToCityGML(objFile, outputFile, buildingID, epsgCode string) error {
+func convertOBJToCityGML(objFile, outputFile, buildingID, epsgCode string, injectRoofPitch bool) error {
 	// Parse OBJ file
 	vertices, faces, mtlLib, err := parseOBJFile(objFile)
 	if err != nil {
@@ -454,7 +461,7 @@ func convertOBJToCityGML(objFile, outputFile, buildingID, epsgCode string) error
 	}
 
 	// Create CityGML model
-	model := CreateCityGMLModel(vertices, faces, materials, buildingID, epsgCode)
+	model := CreateCityGMLModel(vertices, faces, materials, buildingID, epsgCode, injectRoofPitch)
 
 	// Write to file
 	file, err := os.Create(outputFile)
@@ -477,7 +484,7 @@ func convertOBJToCityGML(objFile, outputFile, buildingID, epsgCode string) error
 }
 
 // Create CityGML model from OBJ data
-func CreateCityGMLModel(vertices []OBJVertex, faces []OBJFace, materials map[string]MTLMaterial, buildingID, epsgCode string) CityModel {
+func CreateCityGMLModel(vertices []OBJVertex, faces []OBJFace, materials map[string]MTLMaterial, buildingID, epsgCode string, injectRoofPitch bool) CityModel {
 	// Calculate bounding box
 	minX, minY, minZ := math.MaxFloat64, math.MaxFloat64, math.MaxFloat64
 	maxX, maxY, maxZ := -math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64
@@ -570,6 +577,14 @@ func CreateCityGMLModel(vertices []OBJVertex, faces []OBJFace, materials map[str
 		},
 	}
 
+	// Add roof pitch (degrees from horizontal) if requested
+	if injectRoofPitch && len(roofFaces) > 0 {
+		building.DoubleAttributes = append(building.DoubleAttributes, DoubleAttribute{
+			Name:  "buildingRoofPitch",
+			Value: fmt.Sprintf("%.2f", computeRoofPitch(roofFaces, vertices)),
+		})
+	}
+
 	// Create boundary surfaces
 	boundedBy := []BoundarySurfaceProperty{}
 
@@ -663,6 +678,45 @@ func groupFacesByOrientation(faces []OBJFace, vertices []OBJVertex) [][]OBJFace
 	return result
 }
 
+// Compute the area-weighted average tilt of roof faces in degrees from horizontal (0 = flat roof)
+func computeRoofPitch(faces []OBJFace, vertices []OBJVertex) float64 {
+	var weightedPitch, totalArea float64
+
+	for _, face := range faces {
+		if len(face.VertexIndices) < 3 {
+			continue
+		}
+
+		// Sum fan triangle cross products; the length is twice the face area
+		normal := Vector3D{}
+		v0 := vertices[face.VertexIndices[0]]
+		for i := 1; i < len(face.VertexIndices)-1; i++ {
+			v1 := vertices[face.VertexIndices[i]]
+			v2 := vertices[face.VertexIndices[i+1]]
+			edge1 := Vector3D{v1.X - v0.X, v1.Y - v0.Y, v1.Z - v0.Z}
+			edge2 := Vector3D{v2.X - v0.X, v2.Y - v0.Y, v2.Z - v0.Z}
+			normal.X += edge1.Y*edge2.Z - edge1.Z*edge2.Y
+			normal.Y += edge1.Z*edge2.X - edge1.X*edge2.Z
+			normal.Z += edge1.X*edge2.Y - edge1.Y*edge2.X
+		}
+
+		length := math.Sqrt(normal.X*normal.X + normal.Y*normal.Y + normal.Z*normal.Z)
+		if length == 0 {
+			continue
+		}
+
+		area := length / 2
+		pitch := math.Acos(math.Min(math.Abs(normal.Z)/length, 1)) * 180 / math.Pi
+		weightedPitch += pitch * area
+		totalArea += area
+	}
+
+	if totalArea == 0 {
+		return 0
+	}
+	return weightedPitch / totalArea
+}
+
 // Simple UUID generator based on string hash
 func generateUUID(input string) string {
 	hash := 0
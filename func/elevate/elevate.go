@@ -2,15 +2,26 @@ package main
 
 import (
 	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 	"unsafe"
+
+	"citygml-gen/internal/axes"
+	"citygml-gen/internal/config"
+	"citygml-gen/internal/progress"
+	"citygml-gen/internal/units"
+	"citygml-gen/internal/version"
 )
 
 /*
@@ -31,12 +42,73 @@ type Vector3 struct {
 
 // DTMData holds Digital Terrain Model information
 type DTMData struct {
+	Path         string
 	Dataset      C.GDALDatasetH
 	GeoTransform [6]float64
 	Width        int
 	Height       int
 	NoDataValue  float64
 	HasNoData    bool
+	MinX, MinY   float64 // World-coordinate extent
+	MaxX, MaxY   float64
+	Cache        *ElevationCache // Recently read pixel values (nil reads every sample from the raster)
+}
+
+// DefaultCacheSize is the default number of DTM pixels kept per ElevationCache
+const DefaultCacheSize = 65536
+
+// ElevationCache is a bounded least-recently-used cache of DTM pixel values
+// keyed by pixel column and row
+type ElevationCache struct {
+	Capacity int
+	Hits     int // Pixel lookups answered from the cache
+	Misses   int // Pixel lookups that had to be read from the raster
+
+	entries map[[2]int]*list.Element
+	order   *list.List // Most recently used entry at the front
+}
+
+// cacheEntry is one pixel value held by an ElevationCache
+type cacheEntry struct {
+	pixel [2]int
+	value float64
+}
+
+// NewElevationCache creates a cache holding at most capacity pixel values
+func NewElevationCache(capacity int) *ElevationCache {
+	return &ElevationCache{
+		Capacity: capacity,
+		entries:  make(map[[2]int]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the cached value of pixel (pixelX, pixelY) and marks it as recently used
+func (ec *ElevationCache) Get(pixelX, pixelY int) (float64, bool) {
+	element, ok := ec.entries[[2]int{pixelX, pixelY}]
+	if !ok {
+		return 0, false
+	}
+	ec.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).value, true
+}
+
+// Put stores the value of pixel (pixelX, pixelY), evicting the least recently
+// used pixel when the cache is full
+func (ec *ElevationCache) Put(pixelX, pixelY int, value float64) {
+	pixel := [2]int{pixelX, pixelY}
+	if element, ok := ec.entries[pixel]; ok {
+		element.Value.(*cacheEntry).value = value
+		ec.order.MoveToFront(element)
+		return
+	}
+
+	if ec.order.Len() >= ec.Capacity {
+		oldest := ec.order.Back()
+		ec.order.Remove(oldest)
+		delete(ec.entries, oldest.Value.(*cacheEntry).pixel)
+	}
+	ec.entries[pixel] = ec.order.PushFront(&cacheEntry{pixel: pixel, value: value})
 }
 
 // Statistics holds processing statistics
@@ -44,6 +116,52 @@ type Statistics struct {
 	ProcessedFiles int
 	FailedFiles    []FailedFile
 	ElevationStats ElevationStats
+	DTMSamples     int           // Bottom-vertex DTM sample attempts
+	ValidSamples   int           // DTM samples that returned a valid elevation
+	Elapsed        time.Duration // Processing time accumulated so far
+}
+
+// statisticsJSON is the JSON representation of Statistics, which is also
+// what --stats-output writes. Keys are snake_case and every field is always
+// present so the schema stays stable between runs.
+type statisticsJSON struct {
+	Duration       float64        `json:"duration"` // Seconds, as printed by PrintSummary
+	ProcessedFiles int            `json:"processed_files"`
+	FailedFiles    []FailedFile   `json:"failed_files"`
+	ElevationStats ElevationStats `json:"elevation_stats"`
+	DTMSamples     int            `json:"dtm_samples"`
+	ValidSamples   int            `json:"valid_samples"`
+	ElapsedNanos   int64          `json:"elapsed_nanos"` // Exact Elapsed, restored by UnmarshalJSON
+}
+
+// MarshalJSON encodes Statistics with the duration both in seconds and as
+// nanosecond integers
+func (s Statistics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statisticsJSON{
+		Duration:       s.Elapsed.Seconds(),
+		ProcessedFiles: s.ProcessedFiles,
+		FailedFiles:    append([]FailedFile{}, s.FailedFiles...),
+		ElevationStats: s.ElevationStats,
+		DTMSamples:     s.DTMSamples,
+		ValidSamples:   s.ValidSamples,
+		ElapsedNanos:   int64(s.Elapsed),
+	})
+}
+
+// UnmarshalJSON decodes Statistics previously written by MarshalJSON
+func (s *Statistics) UnmarshalJSON(data []byte) error {
+	var aux statisticsJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	s.ProcessedFiles = aux.ProcessedFiles
+	s.FailedFiles = aux.FailedFiles
+	s.ElevationStats = aux.ElevationStats
+	s.DTMSamples = aux.DTMSamples
+	s.ValidSamples = aux.ValidSamples
+	s.Elapsed = time.Duration(aux.ElapsedNanos)
+	return nil
 }
 
 // ElevationStats tracks elevation adjustments
@@ -53,37 +171,199 @@ type ElevationStats struct {
 	MaxAdjustment    float64
 	AvgAdjustment    float64
 	TotalAdjustment  float64
+	RoughnessCount   int     // Files with a terrain roughness measurement
+	TotalRoughness   float64 // Sum of the per-file roughness standard deviations
+	MaxRoughness     float64 // Largest per-file roughness standard deviation
+
+	VertexAdjustments   int     // Vertices shifted individually in ModePerVertex
+	MinVertexAdjustment float64 // Smallest per-vertex shift in ModePerVertex
+	MaxVertexAdjustment float64 // Largest per-vertex shift in ModePerVertex
+
+	CacheHits   int // DTM pixel reads answered from the elevation cache
+	CacheMisses int // DTM pixel reads that went to the raster
+}
+
+// elevationStatsJSON is the JSON representation of ElevationStats.
+// Infinite min/max sentinels are encoded as null.
+type elevationStatsJSON struct {
+	TotalAdjustments int      `json:"total_adjustments"`
+	MinAdjustment    *float64 `json:"min_adjustment"`
+	MaxAdjustment    *float64 `json:"max_adjustment"`
+	AvgAdjustment    float64  `json:"avg_adjustment"`
+	TotalAdjustment  float64  `json:"total_adjustment"`
+	RoughnessCount   int      `json:"roughness_count"`
+	TotalRoughness   float64  `json:"total_roughness"`
+	MaxRoughness     float64  `json:"max_roughness"`
+
+	VertexAdjustments   int      `json:"vertex_adjustments"`
+	MinVertexAdjustment *float64 `json:"min_vertex_adjustment"`
+	MaxVertexAdjustment *float64 `json:"max_vertex_adjustment"`
+
+	CacheHits   int `json:"cache_hits"`
+	CacheMisses int `json:"cache_misses"`
+}
+
+// MarshalJSON encodes ElevationStats, writing infinite sentinels as null.
+// The average adjustment is computed like PrintSummary, which does not store it.
+func (es ElevationStats) MarshalJSON() ([]byte, error) {
+	avg := es.AvgAdjustment
+	if es.TotalAdjustments > 0 {
+		avg = es.TotalAdjustment / float64(es.TotalAdjustments)
+	}
+	return json.Marshal(elevationStatsJSON{
+		TotalAdjustments: es.TotalAdjustments,
+		MinAdjustment:    finiteOrNil(es.MinAdjustment),
+		MaxAdjustment:    finiteOrNil(es.MaxAdjustment),
+		AvgAdjustment:    avg,
+		TotalAdjustment:  es.TotalAdjustment,
+		RoughnessCount:   es.RoughnessCount,
+		TotalRoughness:   es.TotalRoughness,
+		MaxRoughness:     es.MaxRoughness,
+
+		VertexAdjustments:   es.VertexAdjustments,
+		MinVertexAdjustment: finiteOrNil(es.MinVertexAdjustment),
+		MaxVertexAdjustment: finiteOrNil(es.MaxVertexAdjustment),
+
+		CacheHits:   es.CacheHits,
+		CacheMisses: es.CacheMisses,
+	})
+}
+
+// UnmarshalJSON decodes ElevationStats, restoring null min/max as infinite sentinels
+func (es *ElevationStats) UnmarshalJSON(data []byte) error {
+	var aux elevationStatsJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	es.TotalAdjustments = aux.TotalAdjustments
+	es.MinAdjustment = math.Inf(1)
+	if aux.MinAdjustment != nil {
+		es.MinAdjustment = *aux.MinAdjustment
+	}
+	es.MaxAdjustment = math.Inf(-1)
+	if aux.MaxAdjustment != nil {
+		es.MaxAdjustment = *aux.MaxAdjustment
+	}
+	es.AvgAdjustment = aux.AvgAdjustment
+	es.TotalAdjustment = aux.TotalAdjustment
+	es.RoughnessCount = aux.RoughnessCount
+	es.TotalRoughness = aux.TotalRoughness
+	es.MaxRoughness = aux.MaxRoughness
+	es.VertexAdjustments = aux.VertexAdjustments
+	es.MinVertexAdjustment = math.Inf(1)
+	if aux.MinVertexAdjustment != nil {
+		es.MinVertexAdjustment = *aux.MinVertexAdjustment
+	}
+	es.MaxVertexAdjustment = math.Inf(-1)
+	if aux.MaxVertexAdjustment != nil {
+		es.MaxVertexAdjustment = *aux.MaxVertexAdjustment
+	}
+	es.CacheHits = aux.CacheHits
+	es.CacheMisses = aux.CacheMisses
+	return nil
+}
+
+// finiteOrNil returns nil for infinite or NaN values so they encode as JSON null
+func finiteOrNil(v float64) *float64 {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+// AdjustmentRecord describes the elevation adjustment applied to one file
+type AdjustmentRecord struct {
+	Adjustment      float64  `json:"adjustment"`
+	BottomVertices  int      `json:"bottomVertices"`
+	DTMSamples      int      `json:"dtmSamples"`
+	TargetElevation float64  `json:"targetElevation"`
+	TerrainMean     *float64 `json:"terrainMean,omitempty"` // Mean DTM elevation under the footprint (with RoughnessGrid)
+	Roughness       *float64 `json:"roughness,omitempty"`   // Standard deviation of the DTM under the footprint (with RoughnessGrid)
+
+	MinVertexAdjustment *float64 `json:"minVertexAdjustment,omitempty"` // Smallest per-vertex shift (ModePerVertex)
+	MaxVertexAdjustment *float64 `json:"maxVertexAdjustment,omitempty"` // Largest per-vertex shift (ModePerVertex)
 }
 
 // FailedFile represents a failed file with error message
 type FailedFile struct {
-	Name  string
-	Error string
+	Name  string `json:"name"`
+	Error string `json:"error"`
 }
 
 // DTMElevator handles DTM-based elevation adjustments
 type DTMElevator struct {
-	InputDir  string
-	OutputDir string
-	DTMPath   string
-	DTMData   *DTMData
-	Stats     Statistics
-	StartTime time.Time
-	Debug     bool
+	InputDir        string
+	OutputDir       string
+	DTMPath         string
+	DTMData         *DTMData
+	DTMTiles        []*DTMData // Tiles loaded with LoadDTMDir
+	tileIndex       *tileIndex
+	Stats           Statistics
+	StartTime       time.Time
+	Debug           bool
+	AxisPermutation axes.Permutation            // Output axis order applied at write time (identity by default)
+	Adjustments     map[string]AdjustmentRecord // Adjustment details per successfully processed file
+	SummaryOnly     bool                        // Run all processing steps but write no output files
+	ObjUnits        string                      // Input OBJ units, scaled to metres at load time
+	RoughnessGrid   int                         // Samples per axis for terrain roughness under each footprint (0 disables)
+	Interpolation   string                      // DTM sampling method: InterpolationNearest, InterpolationBilinear or InterpolationBicubic
+	Mode            string                      // ModeUniform shifts each file as a whole, ModePerVertex follows the terrain under every vertex
+	CacheSize       int                         // DTM pixel values cached per DTM or tile (0 disables the cache)
+
+	referencePoint     [2]float64 // X/Y sampled by SetReferencePoint
+	referenceElevation *float64   // Target elevation shared by all files (nil samples each footprint)
+	interruptedFiles   int        // Input files not processed because the run was cancelled
+}
+
+// SetReferencePoint samples the DTM once at (x, y) and uses that elevation as
+// the target elevation for every file instead of sampling each footprint.
+// The DTM must be loaded first.
+func (de *DTMElevator) SetReferencePoint(x, y float64) error {
+	de.Stats.DTMSamples++
+	elevation, err := de.GetElevation(x, y)
+	if err != nil {
+		return fmt.Errorf("could not get DTM elevation at reference point (%.6f, %.6f): %v", x, y, err)
+	}
+	de.Stats.ValidSamples++
+	de.referencePoint = [2]float64{x, y}
+	de.referenceElevation = &elevation
+	return nil
 }
 
+// Elevation adjustment modes
+const (
+	ModeUniform   = "uniform"
+	ModePerVertex = "per-vertex"
+)
+
+// DTM interpolation methods
+const (
+	InterpolationNearest  = "nearest"
+	InterpolationBilinear = "bilinear"
+	InterpolationBicubic  = "bicubic"
+)
+
 // NewDTMElevator creates a new DTMElevator
 func NewDTMElevator(inputDir, outputDir, dtmPath string, debug bool) *DTMElevator {
 	return &DTMElevator{
-		InputDir:  inputDir,
-		OutputDir: outputDir,
-		DTMPath:   dtmPath,
-		Debug:     debug,
-		StartTime: time.Now(),
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		DTMPath:         dtmPath,
+		Debug:           debug,
+		StartTime:       time.Now(),
+		AxisPermutation: axes.Identity,
+		ObjUnits:        "m",
+		Interpolation:   InterpolationBilinear,
+		Mode:            ModeUniform,
+		CacheSize:       DefaultCacheSize,
+		Adjustments:     make(map[string]AdjustmentRecord),
 		Stats: Statistics{
 			ElevationStats: ElevationStats{
-				MinAdjustment: math.Inf(1),
-				MaxAdjustment: math.Inf(-1),
+				MinAdjustment:       math.Inf(1),
+				MaxAdjustment:       math.Inf(-1),
+				MinVertexAdjustment: math.Inf(1),
+				MaxVertexAdjustment: math.Inf(-1),
 			},
 		},
 	}
@@ -96,14 +376,78 @@ func (de *DTMElevator) LoadDTM() error {
 	// Register GDAL drivers
 	C.GDALAllRegister()
 
+	dtm, err := openDTM(de.DTMPath)
+	if err != nil {
+		return err
+	}
+	de.attachCache(dtm)
+	de.DTMData = dtm
+
+	gt := dtm.GeoTransform
+	fmt.Printf("DTM loaded successfully:\n")
+	fmt.Printf("  Dimensions: %dx%d pixels\n", dtm.Width, dtm.Height)
+	fmt.Printf("  Origin: (%.6f, %.6f)\n", gt[0], gt[3])
+	fmt.Printf("  Pixel size: (%.6f, %.6f)\n", gt[1], gt[5])
+	if dtm.HasNoData {
+		fmt.Printf("  NoData value: %.6f\n", dtm.NoDataValue)
+	}
+
+	return nil
+}
+
+// LoadDTMDir loads every .tif/.tiff file in dtmDir as a DTM tile and builds a
+// spatial index so elevation lookups are dispatched to the covering tile(s)
+func (de *DTMElevator) LoadDTMDir(dtmDir string) error {
+	fmt.Println("Loading DTM tiles...")
+
+	// Register GDAL drivers
+	C.GDALAllRegister()
+
+	entries, err := os.ReadDir(dtmDir)
+	if err != nil {
+		return fmt.Errorf("failed to read DTM directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".tif" && ext != ".tiff") {
+			continue
+		}
+
+		tilePath := filepath.Join(dtmDir, entry.Name())
+		tile, err := openDTM(tilePath)
+		if err != nil {
+			fmt.Printf("  Warning: Skipping DTM tile %s: %v\n", entry.Name(), err)
+			continue
+		}
+		de.attachCache(tile)
+		de.DTMTiles = append(de.DTMTiles, tile)
+
+		if de.Debug {
+			fmt.Printf("  Tile %s: %dx%d pixels, extent (%.3f, %.3f) - (%.3f, %.3f)\n",
+				entry.Name(), tile.Width, tile.Height, tile.MinX, tile.MinY, tile.MaxX, tile.MaxY)
+		}
+	}
+
+	if len(de.DTMTiles) == 0 {
+		return fmt.Errorf("no readable DTM tiles found in directory: %s", dtmDir)
+	}
+
+	de.tileIndex = newTileIndex(de.DTMTiles)
+	fmt.Printf("DTM tiles loaded successfully: %d tiles\n", len(de.DTMTiles))
+	return nil
+}
+
+// openDTM opens a DTM raster and reads its geotransform, size and NoData value
+func openDTM(path string) (*DTMData, error) {
 	// Convert Go string to C string
-	cPath := C.CString(de.DTMPath)
+	cPath := C.CString(path)
 	defer C.free(unsafe.Pointer(cPath))
 
 	// Open the DTM file
 	dataset := C.GDALOpen(cPath, C.GA_ReadOnly)
 	if dataset == nil {
-		return fmt.Errorf("failed to open DTM file: %s", de.DTMPath)
+		return nil, fmt.Errorf("failed to open DTM file: %s", path)
 	}
 
 	// Get raster information
@@ -114,7 +458,7 @@ func (de *DTMElevator) LoadDTM() error {
 	var geoTransform [6]C.double
 	if C.GDALGetGeoTransform(dataset, &geoTransform[0]) != C.CE_None {
 		C.GDALClose(dataset)
-		return fmt.Errorf("failed to get geotransform from DTM")
+		return nil, fmt.Errorf("failed to get geotransform from DTM")
 	}
 
 	// Convert C array to Go array
@@ -127,14 +471,15 @@ func (de *DTMElevator) LoadDTM() error {
 	band := C.GDALGetRasterBand(dataset, 1)
 	if band == nil {
 		C.GDALClose(dataset)
-		return fmt.Errorf("failed to get raster band from DTM")
+		return nil, fmt.Errorf("failed to get raster band from DTM")
 	}
 
 	// Get NoData value
 	var hasNoData C.int
 	noDataValue := float64(C.GDALGetRasterNoDataValue(band, &hasNoData))
 
-	de.DTMData = &DTMData{
+	dtm := &DTMData{
+		Path:         path,
 		Dataset:      dataset,
 		GeoTransform: goGeoTransform,
 		Width:        width,
@@ -142,16 +487,96 @@ func (de *DTMElevator) LoadDTM() error {
 		NoDataValue:  noDataValue,
 		HasNoData:    hasNoData != 0,
 	}
+	dtm.computeExtent()
 
-	fmt.Printf("DTM loaded successfully:\n")
-	fmt.Printf("  Dimensions: %dx%d pixels\n", width, height)
-	fmt.Printf("  Origin: (%.6f, %.6f)\n", goGeoTransform[0], goGeoTransform[3])
-	fmt.Printf("  Pixel size: (%.6f, %.6f)\n", goGeoTransform[1], goGeoTransform[5])
-	if hasNoData != 0 {
-		fmt.Printf("  NoData value: %.6f\n", noDataValue)
+	return dtm, nil
+}
+
+// attachCache gives dtm an elevation cache of CacheSize pixels, unless the cache is disabled
+func (de *DTMElevator) attachCache(dtm *DTMData) {
+	if de.CacheSize > 0 {
+		dtm.Cache = NewElevationCache(de.CacheSize)
 	}
+}
 
-	return nil
+// updateCacheStats totals the cache hits and misses of the DTM and all tiles
+func (de *DTMElevator) updateCacheStats() {
+	de.Stats.ElevationStats.CacheHits = 0
+	de.Stats.ElevationStats.CacheMisses = 0
+	for _, dtm := range append([]*DTMData{de.DTMData}, de.DTMTiles...) {
+		if dtm != nil && dtm.Cache != nil {
+			de.Stats.ElevationStats.CacheHits += dtm.Cache.Hits
+			de.Stats.ElevationStats.CacheMisses += dtm.Cache.Misses
+		}
+	}
+}
+
+// computeExtent derives the world-coordinate bounding box from the geotransform
+func (d *DTMData) computeExtent() {
+	gt := d.GeoTransform
+	corners := [][2]float64{{0, 0}, {float64(d.Width), 0}, {0, float64(d.Height)}, {float64(d.Width), float64(d.Height)}}
+
+	d.MinX, d.MinY = math.Inf(1), math.Inf(1)
+	d.MaxX, d.MaxY = math.Inf(-1), math.Inf(-1)
+	for _, c := range corners {
+		x := gt[0] + c[0]*gt[1] + c[1]*gt[2]
+		y := gt[3] + c[0]*gt[4] + c[1]*gt[5]
+		d.MinX = math.Min(d.MinX, x)
+		d.MaxX = math.Max(d.MaxX, x)
+		d.MinY = math.Min(d.MinY, y)
+		d.MaxY = math.Max(d.MaxY, y)
+	}
+}
+
+// Contains reports whether (x, y) lies within the DTM extent
+func (d *DTMData) Contains(x, y float64) bool {
+	return x >= d.MinX && x <= d.MaxX && y >= d.MinY && y <= d.MaxY
+}
+
+// tileIndex is a uniform grid over tile extents used to find candidate tiles for a point
+type tileIndex struct {
+	CellSize float64
+	Cells    map[[2]int][]*DTMData
+}
+
+// newTileIndex builds a grid index with cells sized to the largest tile
+func newTileIndex(tiles []*DTMData) *tileIndex {
+	cellSize := 0.0
+	for _, tile := range tiles {
+		cellSize = math.Max(cellSize, math.Max(tile.MaxX-tile.MinX, tile.MaxY-tile.MinY))
+	}
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+
+	index := &tileIndex{CellSize: cellSize, Cells: make(map[[2]int][]*DTMData)}
+	for _, tile := range tiles {
+		minCell := index.cell(tile.MinX, tile.MinY)
+		maxCell := index.cell(tile.MaxX, tile.MaxY)
+		for cx := minCell[0]; cx <= maxCell[0]; cx++ {
+			for cy := minCell[1]; cy <= maxCell[1]; cy++ {
+				key := [2]int{cx, cy}
+				index.Cells[key] = append(index.Cells[key], tile)
+			}
+		}
+	}
+	return index
+}
+
+// cell returns the grid cell containing (x, y)
+func (ti *tileIndex) cell(x, y float64) [2]int {
+	return [2]int{int(math.Floor(x / ti.CellSize)), int(math.Floor(y / ti.CellSize))}
+}
+
+// TilesAt returns the tiles whose extent contains (x, y)
+func (ti *tileIndex) TilesAt(x, y float64) []*DTMData {
+	var tiles []*DTMData
+	for _, tile := range ti.Cells[ti.cell(x, y)] {
+		if tile.Contains(x, y) {
+			tiles = append(tiles, tile)
+		}
+	}
+	return tiles
 }
 
 // CloseDTM closes the DTM dataset
@@ -159,16 +584,78 @@ func (de *DTMElevator) CloseDTM() {
 	if de.DTMData != nil && de.DTMData.Dataset != nil {
 		C.GDALClose(de.DTMData.Dataset)
 	}
+	for _, tile := range de.DTMTiles {
+		if tile.Dataset != nil {
+			C.GDALClose(tile.Dataset)
+		}
+	}
 }
 
 // GetElevationAtPoint gets elevation from DTM at given X,Y coordinates
 func (de *DTMElevator) GetElevationAtPoint(x, y float64) (float64, error) {
-	if de.DTMData == nil {
-		return 0, fmt.Errorf("DTM data not loaded")
+	return de.sampleDTM(x, y, (*DTMData).ElevationAt)
+}
+
+// GetElevationAtPointBilinear gets elevation using bilinear interpolation
+func (de *DTMElevator) GetElevationAtPointBilinear(x, y float64) (float64, error) {
+	return de.sampleDTM(x, y, (*DTMData).ElevationAtBilinear)
+}
+
+// GetElevationAtPointBicubic gets elevation using bicubic interpolation
+func (de *DTMElevator) GetElevationAtPointBicubic(x, y float64) (float64, error) {
+	return de.sampleDTM(x, y, (*DTMData).ElevationAtBicubic)
+}
+
+// GetElevation gets elevation using the configured Interpolation method
+func (de *DTMElevator) GetElevation(x, y float64) (float64, error) {
+	switch de.Interpolation {
+	case InterpolationNearest:
+		return de.GetElevationAtPoint(x, y)
+	case InterpolationBicubic:
+		return de.GetElevationAtPointBicubic(x, y)
+	}
+	return de.GetElevationAtPointBilinear(x, y)
+}
+
+// sampleDTM samples the loaded DTM at (x, y). In tile mode the point is
+// dispatched to every covering tile and valid samples from overlapping tiles
+// are averaged.
+func (de *DTMElevator) sampleDTM(x, y float64, sample func(d *DTMData, x, y float64) (float64, error)) (float64, error) {
+	if de.tileIndex == nil {
+		if de.DTMData == nil {
+			return 0, fmt.Errorf("DTM data not loaded")
+		}
+		return sample(de.DTMData, x, y)
+	}
+
+	tiles := de.tileIndex.TilesAt(x, y)
+	if len(tiles) == 0 {
+		return 0, fmt.Errorf("coordinates (%.6f, %.6f) are outside all DTM tiles", x, y)
+	}
+
+	var total float64
+	var valid int
+	var lastErr error
+	for _, tile := range tiles {
+		elevation, err := sample(tile, x, y)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		total += elevation
+		valid++
+	}
+
+	if valid == 0 {
+		return 0, lastErr
 	}
+	return total / float64(valid), nil
+}
 
+// ElevationAt gets elevation from this DTM at given X,Y coordinates
+func (d *DTMData) ElevationAt(x, y float64) (float64, error) {
 	// Convert world coordinates to pixel coordinates using inverse geotransform
-	gt := de.DTMData.GeoTransform
+	gt := d.GeoTransform
 
 	// Inverse geotransform calculation
 	det := gt[1]*gt[5] - gt[2]*gt[4]
@@ -184,42 +671,30 @@ func (de *DTMElevator) GetElevationAtPoint(x, y float64) (float64, error) {
 	pixelY := int(math.Floor(py))
 
 	// Check bounds
-	if pixelX < 0 || pixelX >= de.DTMData.Width || pixelY < 0 || pixelY >= de.DTMData.Height {
+	if pixelX < 0 || pixelX >= d.Width || pixelY < 0 || pixelY >= d.Height {
 		return 0, fmt.Errorf("coordinates (%.6f, %.6f) are outside DTM bounds", x, y)
 	}
 
-	// Get the raster band
-	band := C.GDALGetRasterBand(de.DTMData.Dataset, 1)
-	if band == nil {
-		return 0, fmt.Errorf("failed to get raster band")
-	}
-
 	// Read elevation value at pixel
-	var buffer C.double
-	err := C.GDALRasterIO(band, C.GF_Read, C.int(pixelX), C.int(pixelY), 1, 1,
-		unsafe.Pointer(&buffer), 1, 1, C.GDT_Float64, 0, 0)
-	if err != C.CE_None {
-		return 0, fmt.Errorf("failed to read elevation data")
+	buffer, err := d.readBlock(pixelX, pixelY, 1, 1)
+	if err != nil {
+		return 0, err
 	}
 
-	elevation := float64(buffer)
+	elevation := buffer[0]
 
 	// Check for NoData value
-	if de.DTMData.HasNoData && elevation == de.DTMData.NoDataValue {
+	if d.HasNoData && elevation == d.NoDataValue {
 		return 0, fmt.Errorf("no elevation data available at coordinates (%.6f, %.6f)", x, y)
 	}
 
 	return elevation, nil
 }
 
-// GetElevationAtPointBilinear gets elevation using bilinear interpolation
-func (de *DTMElevator) GetElevationAtPointBilinear(x, y float64) (float64, error) {
-	if de.DTMData == nil {
-		return 0, fmt.Errorf("DTM data not loaded")
-	}
-
+// ElevationAtBilinear gets elevation from this DTM using bilinear interpolation
+func (d *DTMData) ElevationAtBilinear(x, y float64) (float64, error) {
 	// Convert world coordinates to pixel coordinates
-	gt := de.DTMData.GeoTransform
+	gt := d.GeoTransform
 	det := gt[1]*gt[5] - gt[2]*gt[4]
 	if det == 0 {
 		return 0, fmt.Errorf("invalid geotransform matrix")
@@ -235,45 +710,37 @@ func (de *DTMElevator) GetElevationAtPointBilinear(x, y float64) (float64, error
 	y2 := y1 + 1
 
 	// Check bounds
-	if x1 < 0 || x2 >= de.DTMData.Width || y1 < 0 || y2 >= de.DTMData.Height {
+	if x1 < 0 || x2 >= d.Width || y1 < 0 || y2 >= d.Height {
 		// Fall back to nearest neighbor if out of bounds
-		return de.GetElevationAtPoint(x, y)
+		return d.ElevationAt(x, y)
 	}
 
 	// Get fractional parts
 	fx := px - float64(x1)
 	fy := py - float64(y1)
 
-	// Get the raster band
-	band := C.GDALGetRasterBand(de.DTMData.Dataset, 1)
-	if band == nil {
-		return 0, fmt.Errorf("failed to get raster band")
-	}
-
 	// Read 2x2 pixel block
-	buffer := make([]C.double, 4)
-	err := C.GDALRasterIO(band, C.GF_Read, C.int(x1), C.int(y1), 2, 2,
-		unsafe.Pointer(&buffer[0]), 2, 2, C.GDT_Float64, 0, 0)
-	if err != C.CE_None {
-		return 0, fmt.Errorf("failed to read elevation data")
+	buffer, err := d.readBlock(x1, y1, 2, 2)
+	if err != nil {
+		return 0, err
 	}
 
 	// Check for NoData values
-	if de.DTMData.HasNoData {
+	if d.HasNoData {
 		for _, val := range buffer {
-			if float64(val) == de.DTMData.NoDataValue {
+			if val == d.NoDataValue {
 				// Fall back to nearest neighbor if any NoData found
-				return de.GetElevationAtPoint(x, y)
+				return d.ElevationAt(x, y)
 			}
 		}
 	}
 
 	// Bilinear interpolation
 	// buffer layout: [top-left, top-right, bottom-left, bottom-right]
-	topLeft := float64(buffer[0])
-	topRight := float64(buffer[1])
-	bottomLeft := float64(buffer[2])
-	bottomRight := float64(buffer[3])
+	topLeft := buffer[0]
+	topRight := buffer[1]
+	bottomLeft := buffer[2]
+	bottomRight := buffer[3]
 
 	// Interpolate along X axis
 	top := topLeft*(1-fx) + topRight*fx
@@ -285,6 +752,119 @@ func (de *DTMElevator) GetElevationAtPointBilinear(x, y float64) (float64, error
 	return elevation, nil
 }
 
+// ElevationAtBicubic gets elevation from this DTM using bicubic interpolation
+// of the surrounding 4x4 pixels with the Keys cubic kernel (a = -0.5). Near
+// the raster edge or NoData pixels it falls back to bilinear interpolation,
+// which in turn falls back to the nearest pixel.
+func (d *DTMData) ElevationAtBicubic(x, y float64) (float64, error) {
+	// Convert world coordinates to pixel coordinates
+	gt := d.GeoTransform
+	det := gt[1]*gt[5] - gt[2]*gt[4]
+	if det == 0 {
+		return 0, fmt.Errorf("invalid geotransform matrix")
+	}
+
+	px := ((x-gt[0])*gt[5] - (y-gt[3])*gt[2]) / det
+	py := ((y-gt[3])*gt[1] - (x-gt[0])*gt[4]) / det
+
+	// The 4x4 block starts one pixel before the pixel containing the point
+	x1 := int(math.Floor(px))
+	y1 := int(math.Floor(py))
+	if x1-1 < 0 || x1+2 >= d.Width || y1-1 < 0 || y1+2 >= d.Height {
+		return d.ElevationAtBilinear(x, y)
+	}
+
+	fx := px - float64(x1)
+	fy := py - float64(y1)
+
+	// Read 4x4 pixel block, row by row
+	buffer, err := d.readBlock(x1-1, y1-1, 4, 4)
+	if err != nil {
+		return 0, err
+	}
+
+	if d.HasNoData {
+		for _, val := range buffer {
+			if val == d.NoDataValue {
+				return d.ElevationAtBilinear(x, y)
+			}
+		}
+	}
+
+	// Interpolate each row along X, then the row results along Y
+	var rows [4]float64
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			rows[row] += buffer[row*4+col] * keysCubic(fx-float64(col-1))
+		}
+	}
+
+	var elevation float64
+	for row := 0; row < 4; row++ {
+		elevation += rows[row] * keysCubic(fy-float64(row-1))
+	}
+	return elevation, nil
+}
+
+// readBlock returns the width x height pixels starting at (pixelX, pixelY), row
+// by row. When every pixel is in the cache the raster is not read at all;
+// otherwise the whole block is read and cached.
+func (d *DTMData) readBlock(pixelX, pixelY, width, height int) ([]float64, error) {
+	values := make([]float64, width*height)
+	if d.Cache != nil {
+		cached := true
+		for i := range values {
+			value, ok := d.Cache.Get(pixelX+i%width, pixelY+i/width)
+			if !ok {
+				cached = false
+				break
+			}
+			values[i] = value
+		}
+		if cached {
+			d.Cache.Hits += len(values)
+			return values, nil
+		}
+	}
+
+	band := C.GDALGetRasterBand(d.Dataset, 1)
+	if band == nil {
+		return nil, fmt.Errorf("failed to get raster band")
+	}
+
+	buffer := make([]C.double, width*height)
+	err := C.GDALRasterIO(band, C.GF_Read, C.int(pixelX), C.int(pixelY), C.int(width), C.int(height),
+		unsafe.Pointer(&buffer[0]), C.int(width), C.int(height), C.GDT_Float64, 0, 0)
+	if err != C.CE_None {
+		return nil, fmt.Errorf("failed to read elevation data")
+	}
+
+	for i, value := range buffer {
+		values[i] = float64(value)
+		if d.Cache != nil {
+			d.Cache.Put(pixelX+i%width, pixelY+i/width, values[i])
+		}
+	}
+	if d.Cache != nil {
+		d.Cache.Misses += len(values)
+	}
+	return values, nil
+}
+
+// keysCubic evaluates the Keys cubic convolution kernel with a = -0.5 at
+// distance t from a sample
+func keysCubic(t float64) float64 {
+	const a = -0.5
+	t = math.Abs(t)
+	switch {
+	case t <= 1:
+		return (a+2)*t*t*t - (a+3)*t*t + 1
+	case t < 2:
+		return a*t*t*t - 5*a*t*t + 8*a*t - 4*a
+	}
+	return 0
+}
+
 // LoadObjFile loads vertices and other data from OBJ file
 func (de *DTMElevator) LoadObjFile(objPath string) ([]Vector3, []string, error) {
 	file, err := os.Open(objPath)
@@ -303,23 +883,12 @@ func (de *DTMElevator) LoadObjFile(objPath string) ([]Vector3, []string, error)
 		line := scanner.Text()
 		allLines = append(allLines, line)
 
-		trimmedLine := strings.TrimSpace(line)
-
-		if strings.HasPrefix(trimmedLine, "v ") {
-			// Parse vertex
-			parts := strings.Fields(trimmedLine)
-			if len(parts) >= 4 {
-				x, err1 := strconv.ParseFloat(parts[1], 64)
-				y, err2 := strconv.ParseFloat(parts[2], 64)
-				z, err3 := strconv.ParseFloat(parts[3], 64)
-				if err1 == nil && err2 == nil && err3 == nil {
-					vertices = append(vertices, Vector3{x, y, z})
-				} else {
-					if de.Debug {
-						fmt.Printf("Warning: Invalid vertex at line %d in %s: %s\n", lineNum, filepath.Base(objPath), line)
-					}
-				}
-			}
+		vertex, isVertex, ok := parseVertexLine(line)
+		if ok {
+			scale := units.Scales[de.ObjUnits]
+			vertices = append(vertices, Vector3{vertex.X * scale, vertex.Y * scale, vertex.Z * scale})
+		} else if isVertex && de.Debug {
+			fmt.Printf("Warning: Invalid vertex at line %d in %s: %s\n", lineNum, filepath.Base(objPath), line)
 		}
 	}
 
@@ -336,8 +905,18 @@ func (de *DTMElevator) LoadObjFile(objPath string) ([]Vector3, []string, error)
 
 // CalculateElevationAdjustment calculates how much to adjust Z coordinates
 func (de *DTMElevator) CalculateElevationAdjustment(vertices []Vector3) (float64, error) {
+	record, err := de.calculateAdjustmentRecord(vertices)
+	if err != nil {
+		return 0, err
+	}
+	return record.Adjustment, nil
+}
+
+// calculateAdjustmentRecord calculates the elevation adjustment together with
+// the sampling details it was derived from
+func (de *DTMElevator) calculateAdjustmentRecord(vertices []Vector3) (AdjustmentRecord, error) {
 	if len(vertices) == 0 {
-		return 0, fmt.Errorf("no vertices to process")
+		return AdjustmentRecord{}, fmt.Errorf("no vertices to process")
 	}
 
 	// Find the minimum Z coordinate (bottom of the object)
@@ -358,48 +937,130 @@ func (de *DTMElevator) CalculateElevationAdjustment(vertices []Vector3) (float64
 	}
 
 	if len(bottomVertices) == 0 {
-		return 0, fmt.Errorf("no bottom vertices found")
+		return AdjustmentRecord{}, fmt.Errorf("no bottom vertices found")
 	}
 
-	// Sample DTM elevations at bottom vertex locations
-	var elevations []float64
+	var targetElevation float64
 	validElevations := 0
 
-	for _, vertex := range bottomVertices {
-		elevation, err := de.GetElevationAtPointBilinear(vertex.X, vertex.Y)
-		if err != nil {
-			if de.Debug {
-				fmt.Printf("    Warning: Could not get elevation at (%.6f, %.6f): %v\n", vertex.X, vertex.Y, err)
+	if de.referenceElevation != nil {
+		// Every file shares the elevation sampled at the reference point
+		targetElevation = *de.referenceElevation
+	} else {
+		// Sample DTM elevations at bottom vertex locations
+		var elevations []float64
+
+		for _, vertex := range bottomVertices {
+			de.Stats.DTMSamples++
+			elevation, err := de.GetElevation(vertex.X, vertex.Y)
+			if err != nil {
+				if de.Debug {
+					fmt.Printf("    Warning: Could not get elevation at (%.6f, %.6f): %v\n", vertex.X, vertex.Y, err)
+				}
+				continue
 			}
-			continue
+			elevations = append(elevations, elevation)
+			validElevations++
+			de.Stats.ValidSamples++
 		}
-		elevations = append(elevations, elevation)
-		validElevations++
-	}
 
-	if validElevations == 0 {
-		return 0, fmt.Errorf("could not get DTM elevation for any bottom vertices")
-	}
+		if validElevations == 0 {
+			return AdjustmentRecord{}, fmt.Errorf("could not get DTM elevation for any bottom vertices")
+		}
 
-	// Calculate target elevation (average of valid DTM elevations)
-	var totalElevation float64
-	for _, elevation := range elevations {
-		totalElevation += elevation
+		// Calculate target elevation (average of valid DTM elevations)
+		var totalElevation float64
+		for _, elevation := range elevations {
+			totalElevation += elevation
+		}
+		targetElevation = totalElevation / float64(validElevations)
 	}
-	targetElevation := totalElevation / float64(validElevations)
 
 	// Calculate adjustment needed
 	adjustment := targetElevation - minZ
 
 	if de.Debug {
-		fmt.Printf("    Bottom vertices: %d (%.6f tolerance)\n", len(bottomVertices))
-		fmt.Printf("    Valid DTM samples: %d\n", validElevations)
+		fmt.Printf("    Bottom vertices: %d (%.6f tolerance)\n", len(bottomVertices), tolerance)
+		if de.referenceElevation != nil {
+			fmt.Printf("    Reference elevation: %.6f\n", *de.referenceElevation)
+		} else {
+			fmt.Printf("    Valid DTM samples: %d\n", validElevations)
+		}
 		fmt.Printf("    Current min Z: %.6f\n", minZ)
 		fmt.Printf("    Target elevation: %.6f\n", targetElevation)
 		fmt.Printf("    Adjustment: %.6f\n", adjustment)
 	}
 
-	return adjustment, nil
+	record := AdjustmentRecord{
+		Adjustment:      adjustment,
+		BottomVertices:  len(bottomVertices),
+		DTMSamples:      validElevations,
+		TargetElevation: targetElevation,
+	}
+
+	if de.RoughnessGrid > 0 {
+		mean, stddev, err := de.ComputeRoughness(vertices, de.RoughnessGrid)
+		if err != nil {
+			if de.Debug {
+				fmt.Printf("    Warning: Could not compute terrain roughness: %v\n", err)
+			}
+		} else {
+			record.TerrainMean = &mean
+			record.Roughness = &stddev
+			if de.Debug {
+				fmt.Printf("    Terrain roughness: %.6f (mean %.6f)\n", stddev, mean)
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// ComputeRoughness samples the DTM on a sampleGrid x sampleGrid grid of cell
+// centres within the X/Y bounding box of footprintVertices and returns the
+// mean and standard deviation of the valid samples. The samples do not count
+// toward the DTM coverage statistics.
+func (de *DTMElevator) ComputeRoughness(footprintVertices []Vector3, sampleGrid int) (mean, stddev float64, err error) {
+	if len(footprintVertices) == 0 {
+		return 0, 0, fmt.Errorf("no footprint vertices")
+	}
+	if sampleGrid < 1 {
+		return 0, 0, fmt.Errorf("invalid sample grid %d", sampleGrid)
+	}
+
+	minX, minY := footprintVertices[0].X, footprintVertices[0].Y
+	maxX, maxY := minX, minY
+	for _, v := range footprintVertices[1:] {
+		minX, maxX = math.Min(minX, v.X), math.Max(maxX, v.X)
+		minY, maxY = math.Min(minY, v.Y), math.Max(maxY, v.Y)
+	}
+
+	stepX := (maxX - minX) / float64(sampleGrid)
+	stepY := (maxY - minY) / float64(sampleGrid)
+	var samples []float64
+	for i := 0; i < sampleGrid; i++ {
+		for j := 0; j < sampleGrid; j++ {
+			elevation, err := de.GetElevation(minX+(float64(i)+0.5)*stepX, minY+(float64(j)+0.5)*stepY)
+			if err != nil {
+				continue
+			}
+			samples = append(samples, elevation)
+		}
+	}
+
+	if len(samples) == 0 {
+		return 0, 0, fmt.Errorf("no valid DTM samples under the footprint")
+	}
+
+	for _, sample := range samples {
+		mean += sample
+	}
+	mean /= float64(len(samples))
+	for _, sample := range samples {
+		stddev += (sample - mean) * (sample - mean)
+	}
+	stddev = math.Sqrt(stddev / float64(len(samples)))
+	return mean, stddev, nil
 }
 
 // AdjustVertices applies elevation adjustment to all vertices
@@ -415,6 +1076,69 @@ func (de *DTMElevator) AdjustVertices(vertices []Vector3, adjustment float64) []
 	return adjustedVertices
 }
 
+// AdjustVerticesPerVertex places every vertex relative to the terrain directly
+// beneath it: v.Z = dtmZ + (v.Z - minZ), with dtmZ sampled at the vertex's own
+// X/Y using the configured interpolation. Vertices sharing an X/Y share one
+// DTM sample. Vertices without a DTM sample are shifted by fallbackAdjustment.
+// The returned shifts are the smallest and largest applied to any vertex.
+func (de *DTMElevator) AdjustVerticesPerVertex(vertices []Vector3, fallbackAdjustment float64) ([]Vector3, float64, float64) {
+	minZ := math.Inf(1)
+	for _, vertex := range vertices {
+		minZ = math.Min(minZ, vertex.Z)
+	}
+
+	terrain := make(map[[2]float64]*float64)
+	minShift, maxShift := math.Inf(1), math.Inf(-1)
+	missing := 0
+	adjustedVertices := make([]Vector3, len(vertices))
+	for i, vertex := range vertices {
+		key := [2]float64{vertex.X, vertex.Y}
+		dtmZ, ok := terrain[key]
+		if !ok {
+			if elevation, err := de.GetElevation(vertex.X, vertex.Y); err == nil {
+				dtmZ = &elevation
+			}
+			terrain[key] = dtmZ
+		}
+
+		shift := fallbackAdjustment
+		if dtmZ != nil {
+			shift = *dtmZ - minZ
+		} else {
+			missing++
+		}
+		minShift = math.Min(minShift, shift)
+		maxShift = math.Max(maxShift, shift)
+
+		adjustedVertices[i] = Vector3{
+			X: vertex.X,
+			Y: vertex.Y,
+			Z: vertex.Z + shift,
+		}
+	}
+
+	if missing > 0 && de.Debug {
+		fmt.Printf("    Warning: %d vertices had no DTM elevation and were shifted by %.6f\n", missing, fallbackAdjustment)
+	}
+	return adjustedVertices, minShift, maxShift
+}
+
+// applyAdjustment adjusts vertices according to Mode. In ModePerVertex the
+// range of per-vertex shifts is added to record.
+func (de *DTMElevator) applyAdjustment(vertices []Vector3, record *AdjustmentRecord) []Vector3 {
+	if de.Mode != ModePerVertex {
+		return de.AdjustVertices(vertices, record.Adjustment)
+	}
+
+	adjustedVertices, minShift, maxShift := de.AdjustVerticesPerVertex(vertices, record.Adjustment)
+	record.MinVertexAdjustment = &minShift
+	record.MaxVertexAdjustment = &maxShift
+	if de.Debug {
+		fmt.Printf("    Per-vertex adjustment: %.6f to %.6f\n", minShift, maxShift)
+	}
+	return adjustedVertices
+}
+
 // SaveObjFile saves the adjusted OBJ file
 func (de *DTMElevator) SaveObjFile(outputPath string, adjustedVertices []Vector3, allLines []string) error {
 	file, err := os.Create(outputPath)
@@ -430,27 +1154,35 @@ func (de *DTMElevator) SaveObjFile(outputPath string, adjustedVertices []Vector3
 	writer.WriteString(fmt.Sprintf("# Elevated by DTM Elevator v%s\n", Version))
 	writer.WriteString(fmt.Sprintf("# Original vertices adjusted based on DTM: %s\n", filepath.Base(de.DTMPath)))
 	writer.WriteString(fmt.Sprintf("# Vertices: %d\n", len(adjustedVertices)))
+	if de.ObjUnits != "m" {
+		writer.WriteString(fmt.Sprintf("# Input units: %s (scaled to metres by %g)\n", de.ObjUnits, units.Scales[de.ObjUnits]))
+	}
 	writer.WriteString("\n")
 
 	vertexIndex := 0
+	mirrored := de.AxisPermutation.Odd()
 
 	// Process each line from the original file
 	for _, line := range allLines {
-		trimmedLine := strings.TrimSpace(line)
-
-		if strings.HasPrefix(trimmedLine, "v ") {
+		if _, _, ok := parseVertexLine(line); ok {
 			// This is a vertex line - replace with adjusted vertex
 			if vertexIndex < len(adjustedVertices) {
 				vertex := adjustedVertices[vertexIndex]
+				vertex.X, vertex.Y, vertex.Z = de.AxisPermutation.Apply(vertex.X, vertex.Y, vertex.Z)
 				writer.WriteString(fmt.Sprintf("v %.6f %.6f %.6f\n", vertex.X, vertex.Y, vertex.Z))
 				vertexIndex++
 			} else {
 				// Fallback: write original line if we somehow have more vertex lines than vertices
 				writer.WriteString(line + "\n")
 			}
+		} else if mirrored && strings.HasPrefix(line, "f ") {
+			// An odd permutation mirrors the mesh, so the winding is reversed
+			// to keep the faces pointing outwards
+			writer.WriteString(reverseFaceLine(line) + "\n")
 		} else {
-			// Write all non-vertex lines as-is (faces, normals, textures, etc.)
-			writer.WriteString(line + "\n")
+			// Write all other lines as-is (faces, textures, invalid vertices,
+			// etc.); normals get the same axis order as the vertices
+			writer.WriteString(de.AxisPermutation.NormalLine(line) + "\n")
 		}
 	}
 
@@ -461,8 +1193,23 @@ func (de *DTMElevator) SaveObjFile(outputPath string, adjustedVertices []Vector3
 	return nil
 }
 
-// ProcessObjFile processes a single OBJ file
-func (de *DTMElevator) ProcessObjFile(objPath string) {
+// reverseFaceLine returns an "f" line with its corners in reverse order
+func reverseFaceLine(line string) string {
+	corners := strings.Fields(line)[1:]
+	for i, j := 0, len(corners)-1; i < j; i, j = i+1, j-1 {
+		corners[i], corners[j] = corners[j], corners[i]
+	}
+	return "f " + strings.Join(corners, " ")
+}
+
+// ProcessObjFile processes a single OBJ file. Once ctx is cancelled the
+// file is counted as interrupted instead of being processed.
+func (de *DTMElevator) ProcessObjFile(ctx context.Context, objPath string) {
+	if ctx.Err() != nil {
+		de.interruptedFiles++
+		return
+	}
+
 	if de.Debug {
 		fmt.Printf("\nProcessing: %s\n", filepath.Base(objPath))
 	}
@@ -486,12 +1233,13 @@ func (de *DTMElevator) ProcessObjFile(objPath string) {
 	if de.Debug {
 		fmt.Println("  Calculating elevation adjustment...")
 	}
-	adjustment, err := de.CalculateElevationAdjustment(vertices)
+	record, err := de.calculateAdjustmentRecord(vertices)
 	if err != nil {
 		fmt.Printf("  Failed to calculate elevation adjustment: %v\n", err)
 		de.Stats.FailedFiles = append(de.Stats.FailedFiles, FailedFile{filepath.Base(objPath), err.Error()})
 		return
 	}
+	adjustment := record.Adjustment
 
 	if de.Debug {
 		fmt.Printf("  Elevation adjustment: %.6f meters\n", adjustment)
@@ -501,43 +1249,155 @@ func (de *DTMElevator) ProcessObjFile(objPath string) {
 	if de.Debug {
 		fmt.Println("  Applying elevation adjustment...")
 	}
-	adjustedVertices := de.AdjustVertices(vertices, adjustment)
+	adjustedVertices := de.applyAdjustment(vertices, &record)
 
 	// Save adjusted OBJ file
 	baseName := filepath.Base(objPath)
 	outputPath := filepath.Join(de.OutputDir, baseName)
 
-	if de.Debug {
-		fmt.Printf("  Saving to: %s\n", outputPath)
-	}
-	if err := de.SaveObjFile(outputPath, adjustedVertices, allLines); err != nil {
-		fmt.Printf("  Failed to save adjusted OBJ file: %v\n", err)
-		de.Stats.FailedFiles = append(de.Stats.FailedFiles, FailedFile{filepath.Base(objPath), err.Error()})
-		return
+	if !de.SummaryOnly {
+		if de.Debug {
+			fmt.Printf("  Saving to: %s\n", outputPath)
+		}
+		if err := de.SaveObjFile(outputPath, adjustedVertices, allLines); err != nil {
+			fmt.Printf("  Failed to save adjusted OBJ file: %v\n", err)
+			de.Stats.FailedFiles = append(de.Stats.FailedFiles, FailedFile{filepath.Base(objPath), err.Error()})
+			return
+		}
 	}
 
 	// Update statistics
+	de.recordAdjustment(baseName, record)
+
+	if de.Debug {
+		fmt.Printf("  Successfully processed %s\n", filepath.Base(objPath))
+	}
+}
+
+// recordAdjustment adds the adjustment of a processed file to the statistics
+func (de *DTMElevator) recordAdjustment(baseName string, record AdjustmentRecord) {
+	de.Adjustments[baseName] = record
 	de.Stats.ProcessedFiles++
 	de.Stats.ElevationStats.TotalAdjustments++
-	de.Stats.ElevationStats.TotalAdjustment += adjustment
+	de.Stats.ElevationStats.TotalAdjustment += record.Adjustment
 
-	if adjustment < de.Stats.ElevationStats.MinAdjustment {
-		de.Stats.ElevationStats.MinAdjustment = adjustment
+	if record.Adjustment < de.Stats.ElevationStats.MinAdjustment {
+		de.Stats.ElevationStats.MinAdjustment = record.Adjustment
 	}
-	if adjustment > de.Stats.ElevationStats.MaxAdjustment {
-		de.Stats.ElevationStats.MaxAdjustment = adjustment
+	if record.Adjustment > de.Stats.ElevationStats.MaxAdjustment {
+		de.Stats.ElevationStats.MaxAdjustment = record.Adjustment
 	}
 
-	if de.Debug {
-		fmt.Printf("  Successfully processed %s\n", filepath.Base(objPath))
+	if record.Roughness != nil {
+		de.Stats.ElevationStats.RoughnessCount++
+		de.Stats.ElevationStats.TotalRoughness += *record.Roughness
+		de.Stats.ElevationStats.MaxRoughness = math.Max(de.Stats.ElevationStats.MaxRoughness, *record.Roughness)
+	}
+
+	if record.MinVertexAdjustment != nil && record.MaxVertexAdjustment != nil {
+		de.Stats.ElevationStats.VertexAdjustments++
+		de.Stats.ElevationStats.MinVertexAdjustment = math.Min(de.Stats.ElevationStats.MinVertexAdjustment, *record.MinVertexAdjustment)
+		de.Stats.ElevationStats.MaxVertexAdjustment = math.Max(de.Stats.ElevationStats.MaxVertexAdjustment, *record.MaxVertexAdjustment)
 	}
 }
 
-// ProcessAllFiles processes all OBJ files in the input directory
-func (de *DTMElevator) ProcessAllFiles() error {
+// EstimateReport is the JSON report written by BatchEstimate
+type EstimateReport struct {
+	Adjustments    map[string]AdjustmentRecord `json:"adjustments"`
+	FailedFiles    []FailedFile                `json:"failedFiles"`
+	ElevationStats ElevationStats              `json:"elevationStats"`
+	DTMCoverage    float64                     `json:"dtmCoverage"`
+}
+
+// BatchEstimate computes the elevation adjustment of every OBJ file in the
+// input directory without adjusting or writing any OBJ files, and writes the
+// results to a JSON report at reportPath. Once ctx is cancelled the remaining
+// files are skipped and the report covers the files estimated so far.
+func (de *DTMElevator) BatchEstimate(ctx context.Context, reportPath string) error {
+	pattern := filepath.Join(de.InputDir, "*.obj")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("error finding OBJ files: %v", err)
+	}
+
+	if len(matches) == 0 {
+		fmt.Printf("No OBJ files found in directory: %s\n", de.InputDir)
+		return nil
+	}
+
+	fmt.Printf("Estimating adjustments for %d OBJ files (no output files will be written)\n", len(matches))
+
+	bar := progress.New(len(matches), !de.Debug)
+	for i, objPath := range matches {
+		if ctx.Err() != nil {
+			de.interruptedFiles = len(matches) - i
+			break
+		}
+		baseName := filepath.Base(objPath)
+		if de.Debug {
+			fmt.Printf("\nEstimating: %s\n", baseName)
+		}
+
+		de.estimateFile(objPath)
+		bar.Increment()
+	}
+	bar.Finish()
+
+	report := EstimateReport{
+		Adjustments:    de.Adjustments,
+		FailedFiles:    de.Stats.FailedFiles,
+		ElevationStats: de.Stats.ElevationStats,
+		DTMCoverage:    de.ComputeDTMCoverage(),
+	}
+	if report.FailedFiles == nil {
+		report.FailedFiles = []FailedFile{}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode estimate report: %v", err)
+	}
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write estimate report: %v", err)
+	}
+
+	de.PrintSummary()
+	fmt.Printf("Estimate report written to: %s\n", reportPath)
+	return nil
+}
+
+// estimateFile computes and records the elevation adjustment of one OBJ file
+// for BatchEstimate
+func (de *DTMElevator) estimateFile(objPath string) {
+	baseName := filepath.Base(objPath)
+	vertices, _, err := de.LoadObjFile(objPath)
+	if err != nil {
+		fmt.Printf("  Failed to load OBJ file %s: %v\n", baseName, err)
+		de.Stats.FailedFiles = append(de.Stats.FailedFiles, FailedFile{baseName, err.Error()})
+		return
+	}
+
+	record, err := de.calculateAdjustmentRecord(vertices)
+	if err != nil {
+		fmt.Printf("  Failed to calculate elevation adjustment for %s: %v\n", baseName, err)
+		de.Stats.FailedFiles = append(de.Stats.FailedFiles, FailedFile{baseName, err.Error()})
+		return
+	}
+	if de.Mode == ModePerVertex {
+		de.applyAdjustment(vertices, &record)
+	}
+	de.recordAdjustment(baseName, record)
+}
+
+// ProcessAllFiles processes all OBJ files in the input directory. Cancelling
+// ctx stops the run after the file in progress; the summary still covers the
+// files processed so far.
+func (de *DTMElevator) ProcessAllFiles(ctx context.Context) error {
 	// Ensure output directory exists
-	if err := os.MkdirAll(de.OutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %v", err)
+	if !de.SummaryOnly {
+		if err := os.MkdirAll(de.OutputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %v", err)
+		}
 	}
 
 	// Find all OBJ files
@@ -554,25 +1414,193 @@ func (de *DTMElevator) ProcessAllFiles() error {
 
 	fmt.Printf("Found %d OBJ files to process\n", len(matches))
 	fmt.Printf("Input directory: %s\n", de.InputDir)
-	fmt.Printf("Output directory: %s\n", de.OutputDir)
+	if de.SummaryOnly {
+		fmt.Println("Summary-only mode: no output files will be written")
+	} else {
+		fmt.Printf("Output directory: %s\n", de.OutputDir)
+	}
 
 	// Process each file
+	bar := progress.New(len(matches), !de.Debug)
 	for _, objPath := range matches {
-		de.ProcessObjFile(objPath)
+		de.ProcessObjFile(ctx, objPath)
+		bar.Increment()
 	}
+	bar.Finish()
 
 	de.PrintSummary()
 	return nil
 }
 
+// WriteStatistics writes the processing statistics to path as JSON
+func (de *DTMElevator) WriteStatistics(path string) error {
+	data, err := json.MarshalIndent(de.Stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode statistics: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write statistics file: %v", err)
+	}
+	return nil
+}
+
+// ExportElevationAdjustments writes a JSON object mapping each processed file
+// name to the adjustment applied and the DTM sampling details behind it
+func (de *DTMElevator) ExportElevationAdjustments(outputPath string) error {
+	data, err := json.MarshalIndent(de.Adjustments, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode adjustments: %v", err)
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write adjustments file: %v", err)
+	}
+
+	fmt.Printf("Elevation adjustments written to: %s\n", outputPath)
+	return nil
+}
+
+// slopeAspectNoData marks output pixels where slope and aspect are undefined
+const slopeAspectNoData = -9999.0
+
+// ExportSlopeAspect computes slope (degrees from horizontal) and aspect
+// (compass direction of steepest descent, 0 = north, clockwise) for every DTM
+// pixel using central finite differences, and writes them as single-band
+// Float32 GeoTIFFs. Flat pixels get an aspect of -1; pixels next to NoData get
+// NoData. The DTM is assumed to be north-up.
+func (de *DTMElevator) ExportSlopeAspect(slopePath, aspectPath string) error {
+	dtm := de.DTMData
+	if dtm == nil {
+		return fmt.Errorf("DTM data not loaded")
+	}
+
+	width, height := dtm.Width, dtm.Height
+	if width < 2 || height < 2 {
+		return fmt.Errorf("DTM is too small for gradient computation (%dx%d)", width, height)
+	}
+
+	band := C.GDALGetRasterBand(dtm.Dataset, 1)
+	if band == nil {
+		return fmt.Errorf("failed to get raster band")
+	}
+
+	elevations := make([]float64, width*height)
+	if C.GDALRasterIO(band, C.GF_Read, 0, 0, C.int(width), C.int(height),
+		unsafe.Pointer(&elevations[0]), C.int(width), C.int(height), C.GDT_Float64, 0, 0) != C.CE_None {
+		return fmt.Errorf("failed to read elevation data")
+	}
+
+	pixelX := math.Abs(dtm.GeoTransform[1])
+	pixelY := math.Abs(dtm.GeoTransform[5])
+	valid := func(col, row int) bool {
+		return !dtm.HasNoData || elevations[row*width+col] != dtm.NoDataValue
+	}
+
+	slope := make([]float32, width*height)
+	aspect := make([]float32, width*height)
+	for row := 0; row < height; row++ {
+		// Central differences inside the raster, one-sided at the edges
+		up, down := max(row-1, 0), min(row+1, height-1)
+		for col := 0; col < width; col++ {
+			left, right := max(col-1, 0), min(col+1, width-1)
+			i := row*width + col
+
+			if !valid(col, row) || !valid(left, row) || !valid(right, row) || !valid(col, up) || !valid(col, down) {
+				slope[i] = slopeAspectNoData
+				aspect[i] = slopeAspectNoData
+				continue
+			}
+
+			dzdx := (elevations[row*width+right] - elevations[row*width+left]) / (float64(right-left) * pixelX)
+			// Rows increase southwards, so north is towards the previous row
+			dzdn := (elevations[up*width+col] - elevations[down*width+col]) / (float64(down-up) * pixelY)
+
+			slope[i] = float32(math.Atan(math.Hypot(dzdx, dzdn)) * 180 / math.Pi)
+			if dzdx == 0 && dzdn == 0 {
+				aspect[i] = -1
+				continue
+			}
+			direction := math.Atan2(-dzdx, -dzdn) * 180 / math.Pi
+			if direction < 0 {
+				direction += 360
+			}
+			aspect[i] = float32(direction)
+		}
+	}
+
+	if err := de.writeFloat32GeoTIFF(slopePath, slope); err != nil {
+		return fmt.Errorf("failed to write slope map: %v", err)
+	}
+	if err := de.writeFloat32GeoTIFF(aspectPath, aspect); err != nil {
+		return fmt.Errorf("failed to write aspect map: %v", err)
+	}
+
+	fmt.Printf("Slope map written to: %s\n", slopePath)
+	fmt.Printf("Aspect map written to: %s\n", aspectPath)
+	return nil
+}
+
+// writeFloat32GeoTIFF writes values as a single-band GeoTIFF with the DTM's
+// size, geotransform and projection
+func (de *DTMElevator) writeFloat32GeoTIFF(outputPath string, values []float32) error {
+	cDriverName := C.CString("GTiff")
+	defer C.free(unsafe.Pointer(cDriverName))
+	driver := C.GDALGetDriverByName(cDriverName)
+	if driver == nil {
+		return fmt.Errorf("GTiff driver not available")
+	}
+
+	cPath := C.CString(outputPath)
+	defer C.free(unsafe.Pointer(cPath))
+
+	dtm := de.DTMData
+	dataset := C.GDALCreate(driver, cPath, C.int(dtm.Width), C.int(dtm.Height), 1, C.GDT_Float32, nil)
+	if dataset == nil {
+		return fmt.Errorf("failed to create %s", outputPath)
+	}
+	defer C.GDALClose(dataset)
+
+	var geoTransform [6]C.double
+	for i, v := range dtm.GeoTransform {
+		geoTransform[i] = C.double(v)
+	}
+	C.GDALSetGeoTransform(dataset, &geoTransform[0])
+	C.GDALSetProjection(dataset, C.GDALGetProjectionRef(dtm.Dataset))
+
+	band := C.GDALGetRasterBand(dataset, 1)
+	if band == nil {
+		return fmt.Errorf("failed to get raster band of %s", outputPath)
+	}
+	C.GDALSetRasterNoDataValue(band, slopeAspectNoData)
+
+	if C.GDALRasterIO(band, C.GF_Write, 0, 0, C.int(dtm.Width), C.int(dtm.Height),
+		unsafe.Pointer(&values[0]), C.int(dtm.Width), C.int(dtm.Height), C.GDT_Float32, 0, 0) != C.CE_None {
+		return fmt.Errorf("failed to write raster data")
+	}
+	return nil
+}
+
+// ComputeDTMCoverage returns the fraction of bottom-vertex DTM samples that
+// produced a valid elevation across all processed files, or 0 if the DTM was
+// never sampled
+func (de *DTMElevator) ComputeDTMCoverage() float64 {
+	if de.Stats.DTMSamples == 0 {
+		return 0
+	}
+	return float64(de.Stats.ValidSamples) / float64(de.Stats.DTMSamples)
+}
+
 // PrintSummary prints processing summary
 func (de *DTMElevator) PrintSummary() {
-	endTime := time.Now()
-	duration := endTime.Sub(de.StartTime).Seconds()
+	de.Stats.Elapsed = time.Since(de.StartTime)
+	duration := de.Stats.Elapsed.Seconds()
 
 	fmt.Println("\n=== DTM Elevator v1.0.0 Summary ===")
 	fmt.Printf("Processing completed in %.2f seconds\n", duration)
 	fmt.Printf("Files processed: %d\n", de.Stats.ProcessedFiles)
+	if de.interruptedFiles > 0 {
+		fmt.Printf("Interrupted: %d files not processed\n", de.interruptedFiles)
+	}
 	fmt.Printf("Failed files: %d\n", len(de.Stats.FailedFiles))
 
 	if de.Stats.ElevationStats.TotalAdjustments > 0 {
@@ -584,6 +1612,37 @@ func (de *DTMElevator) PrintSummary() {
 		fmt.Printf("  Average adjustment: %.6f meters\n", avgAdjustment)
 	}
 
+	if de.Stats.ElevationStats.VertexAdjustments > 0 {
+		fmt.Println("\nPer-vertex adjustment statistics:")
+		fmt.Printf("  Files adjusted per vertex: %d\n", de.Stats.ElevationStats.VertexAdjustments)
+		fmt.Printf("  Min vertex adjustment: %.6f meters\n", de.Stats.ElevationStats.MinVertexAdjustment)
+		fmt.Printf("  Max vertex adjustment: %.6f meters\n", de.Stats.ElevationStats.MaxVertexAdjustment)
+	}
+
+	if de.Stats.ElevationStats.RoughnessCount > 0 {
+		fmt.Println("\nTerrain roughness (DTM standard deviation under footprints):")
+		fmt.Printf("  Files measured: %d\n", de.Stats.ElevationStats.RoughnessCount)
+		fmt.Printf("  Average roughness: %.6f meters\n", de.Stats.ElevationStats.TotalRoughness/float64(de.Stats.ElevationStats.RoughnessCount))
+		fmt.Printf("  Max roughness: %.6f meters\n", de.Stats.ElevationStats.MaxRoughness)
+	}
+
+	if de.referenceElevation != nil {
+		fmt.Printf("\nReference elevation: %.6f meters at (%.6f, %.6f)\n",
+			*de.referenceElevation, de.referencePoint[0], de.referencePoint[1])
+	}
+
+	de.updateCacheStats()
+	if lookups := de.Stats.ElevationStats.CacheHits + de.Stats.ElevationStats.CacheMisses; lookups > 0 {
+		fmt.Printf("\nElevation cache: %d hits, %d misses (%.1f%% hit rate)\n",
+			de.Stats.ElevationStats.CacheHits, de.Stats.ElevationStats.CacheMisses,
+			float64(de.Stats.ElevationStats.CacheHits)/float64(lookups)*100)
+	}
+
+	if de.Stats.DTMSamples > 0 {
+		fmt.Printf("\nDTM coverage: %.1f%% (%d/%d samples)\n",
+			de.ComputeDTMCoverage()*100, de.Stats.ValidSamples, de.Stats.DTMSamples)
+	}
+
 	if len(de.Stats.FailedFiles) > 0 {
 		fmt.Println("\nFailed files:")
 		for _, failed := range de.Stats.FailedFiles {
@@ -594,14 +1653,107 @@ func (de *DTMElevator) PrintSummary() {
 	fmt.Println("===================================")
 }
 
+// parseReferencePoint parses an "X,Y" coordinate pair
+func parseReferencePoint(point string) (float64, float64, error) {
+	parts := strings.Split(point, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid --z-reference-point '%s' (expected X,Y)", point)
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errX != nil || errY != nil {
+		return 0, 0, fmt.Errorf("invalid --z-reference-point '%s' (expected X,Y)", point)
+	}
+	return x, y, nil
+}
+
+// parseVertexLine parses a "v x y z" line. isVertex reports whether the line
+// starts with the v keyword and ok whether its coordinates parsed; vn, vt and
+// vp lines are not vertices. LoadObjFile and SaveObjFile both use it so that
+// the adjusted vertices replace exactly the lines they were read from, and
+// every other line is written back unchanged apart from the axis order of
+// normals and, for mirroring permutations, the corner order of faces.
+func parseVertexLine(line string) (vertex Vector3, isVertex bool, ok bool) {
+	parts := strings.Fields(line)
+	if len(parts) == 0 || parts[0] != "v" {
+		return Vector3{}, false, false
+	}
+	if len(parts) < 4 {
+		return Vector3{}, true, false
+	}
+
+	x, err1 := strconv.ParseFloat(parts[1], 64)
+	y, err2 := strconv.ParseFloat(parts[2], 64)
+	z, err3 := strconv.ParseFloat(parts[3], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return Vector3{}, true, false
+	}
+	return Vector3{x, y, z}, true, true
+}
+
+// Config holds the options of a --config file. Its keys are the long flag
+// names with underscores, e.g. obj_dir for --obj-dir.
+type Config struct {
+	Input             string  `yaml:"input"`
+	Output            string  `yaml:"output"`
+	DTM               string  `yaml:"dtm"`
+	DTMDir            string  `yaml:"dtm_dir"`
+	Debug             bool    `yaml:"debug"`
+	StatsOutput       string  `yaml:"stats_output"`
+	ExportAdjustments string  `yaml:"export_adjustments"`
+	MinDTMCoverage    float64 `yaml:"min_dtm_coverage"`
+	RoughnessGrid     int     `yaml:"roughness_grid"`
+	Mode              string  `yaml:"mode"`
+	Interpolation     string  `yaml:"interpolation"`
+	CacheSize         int     `yaml:"cache_size"`
+	ZReferencePoint   string  `yaml:"z_reference_point"`
+	XYZSwap           string  `yaml:"xyz_swap"`
+	ObjUnits          string  `yaml:"obj_units"`
+	SlopeOutput       string  `yaml:"slope_output"`
+	AspectOutput      string  `yaml:"aspect_output"`
+	SummaryOnly       bool    `yaml:"summary_only"`
+	EstimateOnly      string  `yaml:"estimate_only"`
+	VersionCheck      bool    `yaml:"version_check"`
+}
+
 func main() {
 	var inputDir = flag.String("input", "", "Input directory containing OBJ files (required)")
-	var outputDir = flag.String("output", "", "Output directory for elevated OBJ files (required)")
-	var dtmPath = flag.String("dtm", "", "Path to DTM TIF file (required)")
+	var outputDir = flag.String("output", "", "Output directory for elevated OBJ files (required unless --summary-only is set)")
+	var dtmPath = flag.String("dtm", "", "Path to DTM TIF file (required unless --dtm-dir is set)")
+	var dtmDir = flag.String("dtm-dir", "", "Directory of DTM TIF tiles to stitch (alternative to --dtm)")
 	var debug = flag.Bool("debug", false, "Enable debug output")
+	var statsOutput = flag.String("stats-output", "", "Write the processing statistics to this JSON file")
+	var exportAdjustments = flag.String("export-adjustments", "", "Write per-file elevation adjustments to this JSON file")
+	var minDTMCoverage = flag.Float64("min-dtm-coverage", 0.8, "Exit with an error if DTM coverage falls below this fraction (0-1, 0 disables)")
+	var roughnessGrid = flag.Int("roughness-grid", 0, "Sample the DTM on an N x N grid under each footprint to measure terrain roughness (0 disables)")
+	var mode = flag.String("mode", ModeUniform, "Elevation adjustment: uniform (one shift per file) or per-vertex")
+	var interpolation = flag.String("interpolation", InterpolationBilinear, "DTM interpolation: nearest, bilinear or bicubic")
+	var cacheSize = flag.Int("cache-size", DefaultCacheSize, "Number of DTM pixel values cached per DTM or tile (0 disables the cache)")
+	var zReferencePoint = flag.String("z-reference-point", "", "Sample the DTM once at X,Y and use that elevation for every file")
+	var xyzSwap = flag.String("xyz-swap", "XYZ", "Reorder output vertex axes (e.g. XZY, YZX)")
+	var objUnits = flag.String("obj-units", "m", "Units of the input OBJ coordinates: mm, cm, m, ft or in")
+	var slopeOutput = flag.String("slope-output", "", "Write a DTM slope map (degrees) to this GeoTIFF")
+	var aspectOutput = flag.String("aspect-output", "", "Write a DTM aspect map (degrees from north) to this GeoTIFF")
+	var summaryOnly = flag.Bool("summary-only", false, "Process all files and print statistics without writing output")
+	var estimateOnly = flag.String("estimate-only", "", "Write the expected per-file adjustments to this JSON report without elevating any files")
+	var versionCheck = flag.Bool("version-check", false, "Check GitHub for a newer release")
+	var configPath = flag.String("config", "", "JSON or YAML file with default values for the long flag names")
 	var help = flag.Bool("help", false, "Show help message")
 	flag.Parse()
 
+	// Options from the config file apply to the flags not given on the command line
+	if *configPath != "" {
+		var cfg Config
+		present, err := config.Load(*configPath, &cfg)
+		if err == nil {
+			err = config.Apply(flag.CommandLine, &cfg, present)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if *help {
 		fmt.Println("DTM Elevator v1.0.0")
 		fmt.Println("Elevates OBJ files based on Digital Terrain Model (DTM) data")
@@ -611,20 +1763,92 @@ func main() {
 		fmt.Println("  --input      Directory containing OBJ files to process")
 		fmt.Println("  --output     Output directory for elevated OBJ files")
 		fmt.Println("  --dtm        Path to DTM TIF file")
+		fmt.Println("  --dtm-dir    Directory of DTM TIF tiles, used instead of --dtm")
 		fmt.Println("\nOptional arguments:")
 		fmt.Println("  --debug      Enable debug output with detailed processing info")
+		fmt.Println("  --export-adjustments")
+		fmt.Println("               Write per-file elevation adjustments to a JSON file")
+		fmt.Println("  --min-dtm-coverage")
+		fmt.Println("               Fail if the fraction of valid DTM samples is below this value (default: 0.8,")
+		fmt.Println("               0 disables the check)")
+		fmt.Println("  --roughness-grid")
+		fmt.Println("               Sample the DTM on an N x N grid over each building's bounding box and record the")
+		fmt.Println("               mean and standard deviation (roughness) in the adjustments export (default: 0, off)")
+		fmt.Println("  --mode       uniform shifts each file by one adjustment (default); per-vertex places every vertex")
+		fmt.Println("               relative to the DTM beneath it (z = dtm + z - minZ) so buildings follow sloped terrain")
+		fmt.Println("  --interpolation")
+		fmt.Println("               DTM sampling: nearest, bilinear (2x2 pixels, default) or bicubic (4x4 pixels, Keys")
+		fmt.Println("               kernel); near the raster edge bicubic falls back to bilinear, then nearest")
+		fmt.Println("  --cache-size Keep the N most recently read DTM pixels per DTM or tile in memory so vertices")
+		fmt.Println("               sharing pixels skip the raster read (default: 65536, 0 disables)")
+		fmt.Println("  --z-reference-point")
+		fmt.Println("               Sample the DTM once at X,Y and use that elevation as the target for every file")
+		fmt.Println("               instead of sampling each footprint, e.g. 431250.5,5402130.0")
+		fmt.Println("  --xyz-swap   Reorder output vertex axes, e.g. XZY or YZX (default: XYZ); orders that mirror")
+		fmt.Println("               the mesh (XZY, YXZ, ZYX) also reverse the face winding")
+		fmt.Println("  --obj-units  Input OBJ units mm, cm, m, ft or in; coordinates are scaled to metres (default: m)")
+		fmt.Println("  --slope-output")
+		fmt.Println("               Write the DTM slope in degrees to a GeoTIFF (requires --aspect-output)")
+		fmt.Println("  --aspect-output")
+		fmt.Println("               Write the DTM aspect in degrees from north to a GeoTIFF (requires --slope-output)")
+		fmt.Println("  --summary-only")
+		fmt.Println("               Run all processing and print the summary without writing any files")
+		fmt.Println("  --stats-output")
+		fmt.Println("               Write the statistics of the summary to a JSON file")
+		fmt.Println("  --estimate-only")
+		fmt.Println("               Only compute the adjustment of each file and write them to a JSON report")
+		fmt.Println("  --version-check")
+		fmt.Println("               Check GitHub for a newer release (3 second timeout)")
+		fmt.Println("  --config     JSON or YAML (.yaml/.yml) file whose keys match flag names, written with")
+		fmt.Println("               dashes or underscores (e.g. dtm_dir); command-line flags take precedence")
 		fmt.Println("  --help       Show this help message")
 		fmt.Println("\nExample:")
 		fmt.Printf("  %s --input ./buildings --output ./elevated --dtm ./terrain.tif\n", os.Args[0])
+		fmt.Printf("  %s --input ./buildings --output ./elevated --dtm-dir ./terrain_tiles\n", os.Args[0])
+		fmt.Println("\nWith --dtm-dir, each point is sampled from the tile covering it; overlapping tiles are averaged.")
 		os.Exit(0)
 	}
 
-	if *inputDir == "" || *outputDir == "" || *dtmPath == "" {
-		fmt.Println("Error: --input, --output, and --dtm arguments are all required")
+	if *inputDir == "" || (*outputDir == "" && !*summaryOnly && *estimateOnly == "") || (*dtmPath == "" && *dtmDir == "") {
+		if *configPath != "" {
+			var missing []string
+			if *inputDir == "" {
+				missing = append(missing, "input")
+			}
+			if *outputDir == "" && !*summaryOnly && *estimateOnly == "" {
+				missing = append(missing, "output")
+			}
+			if *dtmPath == "" && *dtmDir == "" {
+				missing = append(missing, "dtm or dtm-dir")
+			}
+			fmt.Printf("Error: %v\n", config.MissingError(*configPath, missing...))
+		} else {
+			fmt.Println("Error: --input, --output, and --dtm (or --dtm-dir) arguments are all required")
+		}
 		fmt.Println("Use --help for usage information")
 		os.Exit(1)
 	}
 
+	if *dtmPath != "" && *dtmDir != "" {
+		fmt.Println("Error: --dtm and --dtm-dir cannot be used together")
+		os.Exit(1)
+	}
+
+	if (*slopeOutput == "") != (*aspectOutput == "") {
+		fmt.Println("Error: --slope-output and --aspect-output must be used together")
+		os.Exit(1)
+	}
+
+	if *slopeOutput != "" && *dtmDir != "" {
+		fmt.Println("Error: --slope-output and --aspect-output require --dtm, not --dtm-dir")
+		os.Exit(1)
+	}
+
+	dtmSource := *dtmPath
+	if *dtmDir != "" {
+		dtmSource = *dtmDir
+	}
+
 	// Validate input directory
 	if info, err := os.Stat(*inputDir); err != nil {
 		fmt.Printf("Error: Cannot access input directory '%s': %v\n", *inputDir, err)
@@ -634,12 +1858,45 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Validate DTM file
-	if _, err := os.Stat(*dtmPath); err != nil {
-		fmt.Printf("Error: Cannot access DTM file '%s': %v\n", *dtmPath, err)
+	// Validate DTM file or tile directory
+	if info, err := os.Stat(dtmSource); err != nil {
+		fmt.Printf("Error: Cannot access DTM '%s': %v\n", dtmSource, err)
+		os.Exit(1)
+	} else if *dtmDir != "" && !info.IsDir() {
+		fmt.Printf("Error: DTM tile path '%s' is not a directory\n", dtmSource)
+		os.Exit(1)
+	}
+
+	axisPermutation, err := axes.Parse(*xyzSwap)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if _, ok := units.Scales[*objUnits]; !ok {
+		fmt.Printf("Error: Invalid --obj-units '%s' (expected mm, cm, m, ft or in)\n", *objUnits)
+		os.Exit(1)
+	}
+
+	if *roughnessGrid < 0 {
+		fmt.Printf("Error: Invalid --roughness-grid %d (expected 0 or a positive grid size)\n", *roughnessGrid)
+		os.Exit(1)
+	}
+
+	if *cacheSize < 0 {
+		fmt.Printf("Error: Invalid --cache-size %d (expected 0 or a positive number of pixels)\n", *cacheSize)
+		os.Exit(1)
+	}
+
+	var referenceX, referenceY float64
+	if *zReferencePoint != "" {
+		referenceX, referenceY, err = parseReferencePoint(*zReferencePoint)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Convert paths to absolute
 	absInputDir, err := filepath.Abs(*inputDir)
 	if err != nil {
@@ -653,9 +1910,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	absDTMPath, err := filepath.Abs(*dtmPath)
+	absDTMPath, err := filepath.Abs(dtmSource)
 	if err != nil {
-		fmt.Printf("Error: Invalid DTM path '%s': %v\n", *dtmPath, err)
+		fmt.Printf("Error: Invalid DTM path '%s': %v\n", dtmSource, err)
 		os.Exit(1)
 	}
 
@@ -664,24 +1921,132 @@ func main() {
 		fmt.Printf("Input Directory: %s\n", absInputDir)
 		fmt.Printf("Output Directory: %s\n", absOutputDir)
 		fmt.Printf("DTM File: %s\n", absDTMPath)
+		fmt.Printf("OBJ units: %s (scale factor %g)\n", *objUnits, units.Scales[*objUnits])
 	}
 
 	fmt.Println("DTM Elevator v1.0.0")
 	fmt.Println("===================")
 
+	// Check for a newer release in the background so processing is not blocked
+	var versionResult chan string
+	if *versionCheck {
+		versionResult = make(chan string, 1)
+		go func() { versionResult <- version.CheckForUpdate(Version) }()
+	}
+
 	// Create elevator instance
 	elevator := NewDTMElevator(absInputDir, absOutputDir, absDTMPath, *debug)
+	elevator.AxisPermutation = axisPermutation
+	elevator.SummaryOnly = *summaryOnly
+	elevator.ObjUnits = *objUnits
+	elevator.RoughnessGrid = *roughnessGrid
+	elevator.CacheSize = *cacheSize
+
+	if *mode != ModeUniform && *mode != ModePerVertex {
+		fmt.Printf("Error: Invalid --mode '%s' (expected uniform or per-vertex)\n", *mode)
+		os.Exit(1)
+	}
+	if *mode == ModePerVertex && *zReferencePoint != "" {
+		fmt.Println("Error: --z-reference-point cannot be combined with --mode per-vertex")
+		os.Exit(1)
+	}
+	elevator.Mode = *mode
+
+	switch *interpolation {
+	case InterpolationNearest, InterpolationBilinear, InterpolationBicubic:
+		elevator.Interpolation = *interpolation
+	default:
+		fmt.Printf("Error: Invalid --interpolation '%s' (expected nearest, bilinear or bicubic)\n", *interpolation)
+		os.Exit(1)
+	}
 
 	// Load DTM data
-	if err := elevator.LoadDTM(); err != nil {
+	if *dtmDir != "" {
+		err = elevator.LoadDTMDir(absDTMPath)
+	} else {
+		err = elevator.LoadDTM()
+	}
+	if err != nil {
 		fmt.Printf("Error loading DTM: %v\n", err)
 		os.Exit(1)
 	}
 	defer elevator.CloseDTM()
 
+	if *zReferencePoint != "" {
+		if err := elevator.SetReferencePoint(referenceX, referenceY); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			elevator.CloseDTM()
+			os.Exit(1)
+		}
+	}
+
+	if *slopeOutput != "" {
+		if err := elevator.ExportSlopeAspect(*slopeOutput, *aspectOutput); err != nil {
+			fmt.Printf("Error exporting slope/aspect maps: %v\n", err)
+			elevator.CloseDTM()
+			os.Exit(1)
+		}
+	}
+
+	// On SIGINT or SIGTERM, finish the file in progress and print the summary
+	// of the partial run; a second signal terminates immediately
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	// Process all files
-	if err := elevator.ProcessAllFiles(); err != nil {
+	if *estimateOnly != "" {
+		err = elevator.BatchEstimate(ctx, *estimateOnly)
+	} else {
+		err = elevator.ProcessAllFiles(ctx)
+	}
+	if err != nil {
 		fmt.Printf("Error processing files: %v\n", err)
 		os.Exit(1)
 	}
+
+	// An interrupted run still writes its exports and statistics for the
+	// files processed so far before exiting with status 2
+	if *exportAdjustments != "" && !*summaryOnly && *estimateOnly == "" {
+		if err := elevator.ExportElevationAdjustments(*exportAdjustments); err != nil {
+			fmt.Printf("Error exporting adjustments: %v\n", err)
+			elevator.CloseDTM()
+			os.Exit(1)
+		}
+	}
+
+	if *statsOutput != "" {
+		if err := elevator.WriteStatistics(*statsOutput); err != nil {
+			fmt.Printf("Error writing statistics: %v\n", err)
+			elevator.CloseDTM()
+			os.Exit(1)
+		}
+	}
+	if ctx.Err() != nil {
+		elevator.CloseDTM()
+		os.Exit(2)
+	}
+
+	if versionResult != nil {
+		fmt.Println(<-versionResult)
+	}
+
+	// A run without bottom vertices has nothing to measure the DTM alignment by
+	if elevator.Stats.DTMSamples > 0 && elevator.ComputeDTMCoverage() < *minDTMCoverage {
+		fmt.Printf("Error: DTM coverage %.1f%% is below the required %.1f%%\n",
+			elevator.ComputeDTMCoverage()*100, *minDTMCoverage*100)
+		elevator.CloseDTM()
+		os.Exit(1)
+	}
+
+	// Failed files were listed in the summary; the status lets callers such
+	// as process-buildings retry the files one at a time
+	if len(elevator.Stats.FailedFiles) > 0 {
+		fmt.Printf("Error: %d files failed\n", len(elevator.Stats.FailedFiles))
+		elevator.CloseDTM()
+		os.Exit(1)
+	}
 }
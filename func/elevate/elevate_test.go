@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"citygml-gen/internal/axes"
+)
+
+// normalsObj is a single triangle with per-vertex normals, written with the
+// v//vn face form that references them
+const normalsObj = "v 0 0 0\n" +
+	"v 1 0 0\n" +
+	"v 0 1 0\n" +
+	"vn 0.000000 0.000000 1.000000\n" +
+	"vn 0.577350 -0.577350 0.577350\n" +
+	"vn -0.707107 0.000000 0.707107\n" +
+	"f 1//1 2//2 3//3\n"
+
+func TestObjRoundTripKeepsNormals(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.obj")
+	outputPath := filepath.Join(dir, "out.obj")
+	if err := os.WriteFile(inputPath, []byte(normalsObj), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	elevator := NewDTMElevator(dir, dir, "dtm.tif", false)
+	vertices, lines, err := elevator.LoadObjFile(inputPath)
+	if err != nil {
+		t.Fatalf("LoadObjFile: %v", err)
+	}
+	if len(vertices) != 3 {
+		t.Fatalf("got %d vertices, want 3 (vn lines must not be read as vertices)", len(vertices))
+	}
+
+	for i := range vertices {
+		vertices[i].Z += 10
+	}
+	if err := elevator.SaveObjFile(outputPath, vertices, lines); err != nil {
+		t.Fatalf("SaveObjFile: %v", err)
+	}
+
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []string
+	for _, line := range strings.Split(normalsObj, "\n") {
+		if strings.HasPrefix(line, "vn ") || strings.HasPrefix(line, "f ") {
+			want = append(want, line)
+		}
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "vn ") || strings.HasPrefix(line, "f ") {
+			got = append(got, line)
+		}
+		if strings.HasPrefix(line, "v ") && !strings.HasSuffix(line, " 10.000000") {
+			t.Errorf("vertex not elevated: %q", line)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("normals and faces changed:\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSaveObjFileMirroredAxes(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.obj")
+	outputPath := filepath.Join(dir, "out.obj")
+	if err := os.WriteFile(inputPath, []byte(normalsObj), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	elevator := NewDTMElevator(dir, dir, "dtm.tif", false)
+	elevator.AxisPermutation = axes.Permutation{0, 2, 1}
+	vertices, lines, err := elevator.LoadObjFile(inputPath)
+	if err != nil {
+		t.Fatalf("LoadObjFile: %v", err)
+	}
+	if err := elevator.SaveObjFile(outputPath, vertices, lines); err != nil {
+		t.Fatalf("SaveObjFile: %v", err)
+	}
+
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"v 1.000000 0.000000 0.000000\n",
+		"v 0.000000 0.000000 1.000000\n",
+		"vn 0.000000 1.000000 0.000000\n",
+		"vn 0.577350 0.577350 -0.577350\n",
+		"f 3//3 2//2 1//1\n",
+	} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("output is missing %q:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// latin1CityGML is a one-building CityGML document encoded as ISO-8859-1;
+// \xfc is "ü" in that encoding and not valid UTF-8 on its own
+const latin1CityGML = "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+	"<core:CityModel xmlns:core=\"http://www.opengis.net/citygml/2.0\" xmlns:bldg=\"http://www.opengis.net/citygml/building/2.0\" xmlns:gml=\"http://www.opengis.net/gml\">\n" +
+	"<gml:boundedBy><gml:Envelope srsName=\"EPSG:25832\"><gml:lowerCorner>0 0 0</gml:lowerCorner><gml:upperCorner>1 1 1</gml:upperCorner></gml:Envelope></gml:boundedBy>\n" +
+	"<core:cityObjectMember>\n" +
+	"<bldg:Building gml:id=\"UUID_1\"><gml:name>M\xfcllerstra\xdfe 1</gml:name></bldg:Building>\n" +
+	"</core:cityObjectMember>\n" +
+	"</core:CityModel>\n"
+
+func TestStreamCityObjectsLatin1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latin1.gml")
+	if err := os.WriteFile(path, []byte(latin1CityGML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cityObjects []string
+	bounds, err := NewCityGMLMerger(false).StreamCityObjects(path, func(cityObject string) {
+		cityObjects = append(cityObjects, cityObject)
+	})
+	if err != nil {
+		t.Fatalf("StreamCityObjects: %v", err)
+	}
+	if len(cityObjects) != 1 {
+		t.Fatalf("got %d city objects, want 1", len(cityObjects))
+	}
+	if !strings.Contains(cityObjects[0], "Müllerstraße 1") {
+		t.Errorf("city object not converted to UTF-8: %q", cityObjects[0])
+	}
+	if bounds == nil || bounds.UpperX != 1 || bounds.SRS != "EPSG:25832" {
+		t.Errorf("unexpected bounds %+v", bounds)
+	}
+}
+
+// stubRow is one row of the stub database's buildings table
+type stubRow struct {
+	wkt    string
+	height interface{}
+	lod    interface{}
+}
+
+// stubDB is an in-memory stand-in for a SQL database that understands only
+// the CREATE TABLE and upserting INSERT statements issued by InsertPostGIS
+type stubDB struct {
+	mu        sync.Mutex
+	created   []string           // CREATE TABLE statements executed
+	rows      map[string]stubRow // gml_id -> committed row
+	committed bool
+}
+
+func (d *stubDB) Open(name string) (driver.Conn, error) { return &stubConn{db: d}, nil }
+
+type stubConn struct {
+	db      *stubDB
+	pending map[string]stubRow // Rows inserted in the open transaction
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return &stubStmt{conn: c, query: query}, nil
+}
+func (c *stubConn) Close() error { return nil }
+func (c *stubConn) Begin() (driver.Tx, error) {
+	c.pending = make(map[string]stubRow)
+	return c, nil
+}
+
+func (c *stubConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for id, row := range c.pending {
+		c.db.rows[id] = row
+	}
+	c.db.committed = true
+	c.pending = nil
+	return nil
+}
+
+func (c *stubConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+type stubStmt struct {
+	conn  *stubConn
+	query string
+}
+
+func (s *stubStmt) Close() error  { return nil }
+func (s *stubStmt) NumInput() int { return -1 }
+func (s *stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("stub database does not support queries")
+}
+
+func (s *stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		s.conn.db.mu.Lock()
+		s.conn.db.created = append(s.conn.db.created, s.query)
+		s.conn.db.mu.Unlock()
+	case strings.HasPrefix(s.query, "INSERT INTO"):
+		if len(args) != 4 {
+			return nil, fmt.Errorf("insert got %d arguments, want 4", len(args))
+		}
+		id, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("gml_id is %T, want string", args[0])
+		}
+		wkt, _ := args[1].(string)
+		s.conn.pending[id] = stubRow{wkt, args[2], args[3]}
+	default:
+		return nil, fmt.Errorf("unexpected statement %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// postGISCityGML holds two buildings, one of them twice, and a building
+// without polygon geometry that must be skipped
+const postGISCityGML = `<core:CityModel xmlns:core="http://www.opengis.net/citygml/2.0" xmlns:bldg="http://www.opengis.net/citygml/building/2.0" xmlns:gml="http://www.opengis.net/gml">
+<gml:boundedBy><gml:Envelope srsName="urn:ogc:def:crs:EPSG::25832"><gml:lowerCorner>0 0 0</gml:lowerCorner><gml:upperCorner>2 1 5</gml:upperCorner></gml:Envelope></gml:boundedBy>
+<core:cityObjectMember><bldg:Building gml:id="B1"><bldg:measuredHeight uom="m">5.5</bldg:measuredHeight><bldg:lod2MultiSurface><gml:MultiSurface><gml:surfaceMember><gml:Polygon><gml:exterior><gml:LinearRing><gml:posList>0 0 0 1 0 0 1 1 0 0 0 0</gml:posList></gml:LinearRing></gml:exterior></gml:Polygon></gml:surfaceMember></gml:MultiSurface></bldg:lod2MultiSurface></bldg:Building></core:cityObjectMember>
+<core:cityObjectMember><bldg:Building gml:id="B2"><bldg:lod1Solid><gml:Solid><gml:exterior><gml:CompositeSurface><gml:surfaceMember><gml:Polygon><gml:exterior><gml:LinearRing><gml:posList>1 0 0 2 0 0 2 1 0</gml:posList></gml:LinearRing></gml:exterior></gml:Polygon></gml:surfaceMember></gml:CompositeSurface></gml:exterior></gml:Solid></bldg:lod1Solid></bldg:Building></core:cityObjectMember>
+<core:cityObjectMember><bldg:Building gml:id="B1"><bldg:measuredHeight uom="m">6</bldg:measuredHeight><bldg:lod2MultiSurface><gml:MultiSurface><gml:surfaceMember><gml:Polygon><gml:exterior><gml:LinearRing><gml:posList>0 0 1 1 0 1 1 1 1 0 0 1</gml:posList></gml:LinearRing></gml:exterior></gml:Polygon></gml:surfaceMember></gml:MultiSurface></bldg:lod2MultiSurface></bldg:Building></core:cityObjectMember>
+<core:cityObjectMember><bldg:Building gml:id="B3"><bldg:measuredHeight uom="m">3</bldg:measuredHeight></bldg:Building></core:cityObjectMember>
+</core:CityModel>
+`
+
+func TestInsertPostGIS(t *testing.T) {
+	stub := &stubDB{rows: make(map[string]stubRow)}
+	sql.Register("citygml-stub", stub)
+	db, err := sql.Open("citygml-stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	inserted, err := NewCityGMLMerger(false).InsertPostGIS(db, postGISCityGML)
+	if err != nil {
+		t.Fatalf("InsertPostGIS: %v", err)
+	}
+	if inserted != 3 {
+		t.Errorf("inserted %d rows, want 3 (B3 has no geometry)", inserted)
+	}
+	if !stub.committed {
+		t.Fatal("transaction was not committed")
+	}
+	if len(stub.created) != 1 || !strings.Contains(stub.created[0], "buildings") || !strings.Contains(stub.created[0], "MultiPolygonZ, 25832") {
+		t.Errorf("unexpected CREATE TABLE statements %q", stub.created)
+	}
+
+	want := map[string]stubRow{
+		"B1": {"MULTIPOLYGON Z (((0 0 1, 1 0 1, 1 1 1, 0 0 1)))", 6.0, int64(2)},
+		"B2": {"MULTIPOLYGON Z (((1 0 0, 2 0 0, 2 1 0, 1 0 0)))", nil, int64(1)},
+	}
+	if len(stub.rows) != len(want) {
+		t.Errorf("got %d rows, want %d", len(stub.rows), len(want))
+	}
+	for id, wantRow := range want {
+		if row, ok := stub.rows[id]; !ok || row != wantRow {
+			t.Errorf("row %s = %+v, want %+v", id, row, wantRow)
+		}
+	}
+}
+
+// appearanceCityGML holds two buildings, B2 outside the filter box, and a
+// global appearance texturing a wall of each and a material on B2 only
+const appearanceCityGML = `<?xml version="1.0" encoding="UTF-8"?>
+<core:CityModel xmlns:core="http://www.opengis.net/citygml/2.0" xmlns:bldg="http://www.opengis.net/citygml/building/2.0" xmlns:app="http://www.opengis.net/citygml/appearance/2.0" xmlns:gml="http://www.opengis.net/gml">
+<core:cityObjectMember>
+<bldg:Building gml:id="UUID_B1"><gml:boundedBy><gml:Envelope><gml:lowerCorner>0 0 0</gml:lowerCorner><gml:upperCorner>10 10 5</gml:upperCorner></gml:Envelope></gml:boundedBy>
+<gml:Polygon gml:id="UUID_B1_wall"/></bldg:Building>
+</core:cityObjectMember>
+<core:cityObjectMember>
+<bldg:Building gml:id="UUID_B2"><gml:boundedBy><gml:Envelope><gml:lowerCorner>100 100 0</gml:lowerCorner><gml:upperCorner>110 110 5</gml:upperCorner></gml:Envelope></gml:boundedBy>
+<gml:Polygon gml:id="UUID_B2_wall"/></bldg:Building>
+</core:cityObjectMember>
+<app:appearanceMember>
+<app:Appearance gml:id="UUID_app">
+<app:surfaceDataMember>
+<app:ParameterizedTexture gml:id="UUID_tex">
+<app:target uri="#UUID_B1_wall"><app:TexCoordList/></app:target>
+<app:target uri="#UUID_B2_wall"><app:TexCoordList/></app:target>
+</app:ParameterizedTexture>
+</app:surfaceDataMember>
+<app:surfaceDataMember>
+<app:X3DMaterial gml:id="UUID_mat">
+<app:target>#UUID_B2_wall</app:target>
+</app:X3DMaterial>
+</app:surfaceDataMember>
+</app:Appearance>
+</app:appearanceMember>
+</core:CityModel>
+`
+
+func TestStreamMergedCityGMLPrunesAppearances(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.gml")
+	outputPath := filepath.Join(dir, "out.gml")
+	if err := os.WriteFile(inputPath, []byte(appearanceCityGML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	merger := NewCityGMLMerger(false)
+	merger.FilterBBox = &Bounds{LowerX: -1, LowerY: -1, UpperX: 20, UpperY: 20}
+	if err := merger.StreamMergedCityGML(context.Background(), []string{inputPath}, outputPath, "M", "test"); err != nil {
+		t.Fatalf("StreamMergedCityGML: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(data)
+	for _, want := range []string{`gml:id="M_B1"`, `uri="#M_B1_wall"`, `gml:id="M_tex"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %s", want)
+		}
+	}
+	for _, unwanted := range []string{`gml:id="M_B2"`, `#M_B2_wall`, `gml:id="M_mat"`} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("output still contains %s of the filtered-out building", unwanted)
+		}
+	}
+	if merger.Stats.AppearancesExtracted != 1 {
+		t.Errorf("AppearancesExtracted = %d, want 1", merger.Stats.AppearancesExtracted)
+	}
+	if _, err := os.Stat(outputPath + ".appearances.tmp"); !os.IsNotExist(err) {
+		t.Errorf("appearance spool file was left behind")
+	}
+}
+
+func TestMergeFilesRejectsCombinedSplits(t *testing.T) {
+	merger := NewCityGMLMerger(false)
+	merger.SplitByLODLevel = true
+	merger.SplitByType = true
+	err := merger.MergeFiles(context.Background(), t.TempDir(), filepath.Join(t.TempDir(), "merged.gml"), "M", "test")
+	if err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("MergeFiles error = %v, want the split modes to be rejected", err)
+	}
+}
+
+// lodCityGML holds an LOD1 and an LOD2 building far apart under one
+// envelope covering both
+const lodCityGML = `<?xml version="1.0" encoding="UTF-8"?>
+<core:CityModel xmlns:core="http://www.opengis.net/citygml/2.0" xmlns:bldg="http://www.opengis.net/citygml/building/2.0" xmlns:gml="http://www.opengis.net/gml">
+<gml:boundedBy><gml:Envelope srsName="EPSG:25832"><gml:lowerCorner>0 0 0</gml:lowerCorner><gml:upperCorner>110 110 20</gml:upperCorner></gml:Envelope></gml:boundedBy>
+<core:cityObjectMember>
+<bldg:Building gml:id="UUID_B1"><bldg:lod1Solid><gml:posList>0 0 0 10 0 0 10 10 5</gml:posList></bldg:lod1Solid></bldg:Building>
+</core:cityObjectMember>
+<core:cityObjectMember>
+<bldg:Building gml:id="UUID_B2"><bldg:lod2MultiSurface><gml:posList>100 100 0 110 100 0 110 110 20</gml:posList></bldg:lod2MultiSurface></bldg:Building>
+</core:cityObjectMember>
+</core:CityModel>
+`
+
+func TestSplitByLODEnvelopes(t *testing.T) {
+	dir := t.TempDir()
+	inputDir := filepath.Join(dir, "in")
+	if err := os.Mkdir(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "lod.gml"), []byte(lodCityGML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputFile := filepath.Join(dir, "merged.gml")
+
+	merger := NewCityGMLMerger(false)
+	merger.SplitByLODLevel = true
+	if err := merger.MergeFiles(context.Background(), inputDir, outputFile, "M", "test"); err != nil {
+		t.Fatalf("MergeFiles: %v", err)
+	}
+
+	for key, want := range map[string][2]string{
+		"lod1": {"0.000000 0.000000 0.000000", "10.000000 10.000000 5.000000"},
+		"lod2": {"100.000000 100.000000 0.000000", "110.000000 110.000000 20.000000"},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, "merged_"+key+".gml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds := NewCityGMLMerger(false).ExtractBounds(string(data))
+		if bounds == nil {
+			t.Fatalf("%s output has no envelope", key)
+		}
+		lower := fmt.Sprintf("%f %f %f", bounds.LowerX, bounds.LowerY, bounds.LowerZ)
+		upper := fmt.Sprintf("%f %f %f", bounds.UpperX, bounds.UpperY, bounds.UpperZ)
+		if lower != want[0] || upper != want[1] || bounds.SRS != "EPSG:25832" {
+			t.Errorf("%s envelope = %s / %s (%s), want %s / %s (EPSG:25832)", key, lower, upper, bounds.SRS, want[0], want[1])
+		}
+	}
+}
+
+func TestAnonymiseBuildingsCodeSpace(t *testing.T) {
+	merger := NewCityGMLMerger(false)
+	content := `<bldg:Building><gml:name codeSpace="urn:adv:names">Rathaus</gml:name><gml:name>Rathaus</gml:name><gml:name/>` +
+		`<gml:description>Town hall</gml:description><gml:names>kept</gml:names></bldg:Building>`
+
+	got := merger.AnonymiseBuildings(content)
+	want := `<bldg:Building><gml:name codeSpace="urn:adv:names">ANON_1</gml:name><gml:name>ANON_1</gml:name><gml:name/>` +
+		`<gml:description>ANON_2</gml:description><gml:names>kept</gml:names></bldg:Building>`
+	if got != want {
+		t.Errorf("AnonymiseBuildings =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// straddleCityGML holds one building inside the filter box and one crossing
+// its upper edge, each with its own gml:boundedBy
+const straddleCityGML = `<?xml version="1.0" encoding="UTF-8"?>
+<core:CityModel xmlns:core="http://www.opengis.net/citygml/2.0" xmlns:bldg="http://www.opengis.net/citygml/building/2.0" xmlns:gml="http://www.opengis.net/gml">
+<gml:boundedBy><gml:Envelope srsName="EPSG:25832"><gml:lowerCorner>0 0 0</gml:lowerCorner><gml:upperCorner>30 30 10</gml:upperCorner></gml:Envelope></gml:boundedBy>
+<core:cityObjectMember>
+<bldg:Building gml:id="UUID_B1"><gml:boundedBy><gml:Envelope><gml:lowerCorner>0 0 0</gml:lowerCorner><gml:upperCorner>10 10 5</gml:upperCorner></gml:Envelope></gml:boundedBy><bldg:lod2MultiSurface><gml:posList>0 0 0 10 0 0 10 10 5</gml:posList></bldg:lod2MultiSurface></bldg:Building>
+</core:cityObjectMember>
+<core:cityObjectMember>
+<bldg:Building gml:id="UUID_B2"><gml:boundedBy><gml:Envelope><gml:lowerCorner>15 15 0</gml:lowerCorner><gml:upperCorner>30 30 10</gml:upperCorner></gml:Envelope></gml:boundedBy><bldg:lod2MultiSurface><gml:posList>15 15 0 30 15 0 30 30 10</gml:posList></bldg:lod2MultiSurface></bldg:Building>
+</core:cityObjectMember>
+</core:CityModel>
+`
+
+func TestFilterBBoxClipsEnvelope(t *testing.T) {
+	dir := t.TempDir()
+	inputDir := filepath.Join(dir, "in")
+	if err := os.Mkdir(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "straddle.gml"), []byte(straddleCityGML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputFile := filepath.Join(dir, "merged.gml")
+
+	merger := NewCityGMLMerger(false)
+	merger.FilterBBox = &Bounds{LowerX: -5, LowerY: -5, UpperX: 20, UpperY: 20}
+	merger.Translation = [3]float64{100, 200, 0}
+	if err := merger.MergeFiles(context.Background(), inputDir, outputFile, "M", "test"); err != nil {
+		t.Fatalf("MergeFiles: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `gml:id="M_B2"`) {
+		t.Fatalf("building crossing the filter box edge was dropped")
+	}
+	bounds := NewCityGMLMerger(false).ExtractBounds(string(data))
+	if bounds == nil {
+		t.Fatal("output has no envelope")
+	}
+	// The data extent is clipped to the translated filter box on X and Y only
+	lower := fmt.Sprintf("%f %f %f", bounds.LowerX, bounds.LowerY, bounds.LowerZ)
+	upper := fmt.Sprintf("%f %f %f", bounds.UpperX, bounds.UpperY, bounds.UpperZ)
+	wantLower := "100.000000 200.000000 0.000000"
+	wantUpper := "120.000000 220.000000 10.000000"
+	if lower != wantLower || upper != wantUpper {
+		t.Errorf("envelope = %s / %s, want %s / %s", lower, upper, wantLower, wantUpper)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const Version = "1.0.0"
+
+// xmlHeader precedes the encoded city model in every output file
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- OBJ to CityGML LOD1 Block Model Converter Output -->
+`
+
+// CityGML 2.0 namespaces and schema locations
+const (
+	nsGML          = "http://www.opengis.net/gml"
+	nsCore         = "http://www.opengis.net/citygml/2.0"
+	nsBldg         = "http://www.opengis.net/citygml/building/2.0"
+	nsXSI          = "http://www.w3.org/2001/XMLSchema-instance"
+	schemaLocation = "http://www.opengis.net/citygml/2.0 http://schemas.opengis.net/citygml/2.0/cityGMLBase.xsd http://www.opengis.net/citygml/building/2.0 http://schemas.opengis.net/citygml/building/2.0/building.xsd"
+)
+
+// srsPattern matches the accepted --srs values
+var srsPattern = regexp.MustCompile(`^EPSG:[0-9]+$`)
+
+// CityModel is the root element of an output file
+type CityModel struct {
+	XMLName          xml.Name           `xml:"core:CityModel"`
+	GML              string             `xml:"xmlns:gml,attr"`
+	Core             string             `xml:"xmlns:core,attr"`
+	Bldg             string             `xml:"xmlns:bldg,attr"`
+	XSI              string             `xml:"xmlns:xsi,attr"`
+	SchemaLocation   string             `xml:"xsi:schemaLocation,attr"`
+	BoundedBy        BoundedBy          `xml:"gml:boundedBy"`
+	CityObjectMember []CityObjectMember `xml:"core:cityObjectMember"`
+}
+
+type BoundedBy struct {
+	Envelope Envelope `xml:"gml:Envelope"`
+}
+
+type Envelope struct {
+	SrsName      string `xml:"srsName,attr"`
+	SrsDimension string `xml:"srsDimension,attr"`
+	LowerCorner  string `xml:"gml:lowerCorner"`
+	UpperCorner  string `xml:"gml:upperCorner"`
+}
+
+type CityObjectMember struct {
+	Building Building `xml:"bldg:Building"`
+}
+
+// Building is an LOD1 block model; element order follows the CityGML 2.0 schema
+type Building struct {
+	ID             string         `xml:"gml:id,attr"`
+	Name           string         `xml:"gml:name"`
+	BoundedBy      BoundedBy      `xml:"gml:boundedBy"`
+	MeasuredHeight MeasuredHeight `xml:"bldg:measuredHeight"`
+	Lod1Solid      SolidProperty  `xml:"bldg:lod1Solid"`
+}
+
+type MeasuredHeight struct {
+	Value string `xml:",chardata"`
+	UOM   string `xml:"uom,attr"`
+}
+
+type SolidProperty struct {
+	Solid Solid `xml:"gml:Solid"`
+}
+
+type Solid struct {
+	ID       string        `xml:"gml:id,attr"`
+	Exterior SolidExterior `xml:"gml:exterior"`
+}
+
+type SolidExterior struct {
+	CompositeSurface CompositeSurface `xml:"gml:CompositeSurface"`
+}
+
+type CompositeSurface struct {
+	SurfaceMember []SurfaceMember `xml:"gml:surfaceMember"`
+}
+
+type SurfaceMember struct {
+	Polygon Polygon `xml:"gml:Polygon"`
+}
+
+type Polygon struct {
+	ID       string          `xml:"gml:id,attr"`
+	Exterior PolygonExterior `xml:"gml:exterior"`
+}
+
+type PolygonExterior struct {
+	LinearRing LinearRing `xml:"gml:LinearRing"`
+}
+
+type LinearRing struct {
+	PosList PosList `xml:"gml:posList"`
+}
+
+type PosList struct {
+	Value        string `xml:",chardata"`
+	SrsDimension string `xml:"srsDimension,attr"`
+}
+
+// Vector3 represents a 3D point
+type Vector3 struct {
+	X, Y, Z float64
+}
+
+// BoundingBox is the axis-aligned extent of an OBJ file's vertices
+type BoundingBox struct {
+	Min, Max Vector3
+}
+
+// ObjToCityGMLConverter writes an LOD1 block model CityGML file for each OBJ file
+type ObjToCityGMLConverter struct {
+	InputDir  string
+	OutputDir string
+	SrsName   string // Coordinate reference system of the OBJ vertices, e.g. EPSG:32748
+	Stats     ConversionStats
+	Debug     bool
+}
+
+// ConversionStats holds conversion statistics
+type ConversionStats struct {
+	ConvertedFiles int
+	FailedFiles    []FailedFile
+}
+
+// FailedFile represents a failed file with error message
+type FailedFile struct {
+	Name  string
+	Error string
+}
+
+// NewObjToCityGMLConverter creates a new ObjToCityGMLConverter
+func NewObjToCityGMLConverter(inputDir, outputDir, srsName string) *ObjToCityGMLConverter {
+	return &ObjToCityGMLConverter{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		SrsName:   srsName,
+	}
+}
+
+// ComputeBoundingBox reads the vertices of the OBJ file at objPath and returns their extent
+func (c *ObjToCityGMLConverter) ComputeBoundingBox(objPath string) (BoundingBox, error) {
+	file, err := os.Open(objPath)
+	if err != nil {
+		return BoundingBox{}, err
+	}
+	defer file.Close()
+
+	box := BoundingBox{
+		Min: Vector3{math.Inf(1), math.Inf(1), math.Inf(1)},
+		Max: Vector3{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+	}
+	vertexCount := 0
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "v" {
+			continue
+		}
+
+		x, errX := strconv.ParseFloat(fields[1], 64)
+		y, errY := strconv.ParseFloat(fields[2], 64)
+		z, errZ := strconv.ParseFloat(fields[3], 64)
+		if errX != nil || errY != nil || errZ != nil {
+			return BoundingBox{}, fmt.Errorf("invalid vertex line: %s", scanner.Text())
+		}
+
+		box.Min = Vector3{math.Min(box.Min.X, x), math.Min(box.Min.Y, y), math.Min(box.Min.Z, z)}
+		box.Max = Vector3{math.Max(box.Max.X, x), math.Max(box.Max.Y, y), math.Max(box.Max.Z, z)}
+		vertexCount++
+	}
+	if err := scanner.Err(); err != nil {
+		return BoundingBox{}, err
+	}
+
+	if vertexCount == 0 {
+		return BoundingBox{}, fmt.Errorf("no vertices found")
+	}
+	return box, nil
+}
+
+// CreateCityModel builds a city model holding one building with the given ID
+// whose lod1Solid is the box extruded from the footprint of box
+func (c *ObjToCityGMLConverter) CreateCityModel(buildingID string, box BoundingBox) CityModel {
+	envelope := BoundedBy{
+		Envelope: Envelope{
+			SrsName:      c.SrsName,
+			SrsDimension: "3",
+			LowerCorner:  formatPos(box.Min),
+			UpperCorner:  formatPos(box.Max),
+		},
+	}
+
+	var members []SurfaceMember
+	for i, ring := range boxFaces(box) {
+		members = append(members, SurfaceMember{
+			Polygon: Polygon{
+				ID: fmt.Sprintf("%s_lod1_poly_%d", buildingID, i+1),
+				Exterior: PolygonExterior{
+					LinearRing: LinearRing{PosList: PosList{Value: formatRing(ring), SrsDimension: "3"}},
+				},
+			},
+		})
+	}
+
+	building := Building{
+		ID:             buildingID,
+		Name:           buildingID,
+		BoundedBy:      envelope,
+		MeasuredHeight: MeasuredHeight{Value: fmt.Sprintf("%.2f", box.Max.Z-box.Min.Z), UOM: "m"},
+		Lod1Solid: SolidProperty{
+			Solid: Solid{
+				ID:       buildingID + "_lod1",
+				Exterior: SolidExterior{CompositeSurface: CompositeSurface{SurfaceMember: members}},
+			},
+		},
+	}
+
+	return CityModel{
+		GML:              nsGML,
+		Core:             nsCore,
+		Bldg:             nsBldg,
+		XSI:              nsXSI,
+		SchemaLocation:   schemaLocation,
+		BoundedBy:        envelope,
+		CityObjectMember: []CityObjectMember{{Building: building}},
+	}
+}
+
+// boxFaces returns the six faces of box as rings wound counter-clockwise seen
+// from outside, so that every face normal points out of the solid: ground,
+// roof, then the south, east, north and west walls
+func boxFaces(box BoundingBox) [][]Vector3 {
+	x0, y0, z0 := box.Min.X, box.Min.Y, box.Min.Z
+	x1, y1, z1 := box.Max.X, box.Max.Y, box.Max.Z
+	return [][]Vector3{
+		{{x0, y0, z0}, {x0, y1, z0}, {x1, y1, z0}, {x1, y0, z0}},
+		{{x0, y0, z1}, {x1, y0, z1}, {x1, y1, z1}, {x0, y1, z1}},
+		{{x0, y0, z0}, {x1, y0, z0}, {x1, y0, z1}, {x0, y0, z1}},
+		{{x1, y0, z0}, {x1, y1, z0}, {x1, y1, z1}, {x1, y0, z1}},
+		{{x1, y1, z0}, {x0, y1, z0}, {x0, y1, z1}, {x1, y1, z1}},
+		{{x0, y1, z0}, {x0, y0, z0}, {x0, y0, z1}, {x0, y1, z1}},
+	}
+}
+
+// formatPos formats a point as a gml:pos value
+func formatPos(v Vector3) string {
+	return fmt.Sprintf("%f %f %f", v.X, v.Y, v.Z)
+}
+
+// formatRing formats ring as a closed gml:posList, repeating the first point
+func formatRing(ring []Vector3) string {
+	positions := make([]string, 0, len(ring)+1)
+	for _, v := range append(ring, ring[0]) {
+		positions = append(positions, formatPos(v))
+	}
+	return strings.Join(positions, " ")
+}
+
+// buildingID returns the OBJ file name without extension as an XML NCName,
+// as required for gml:id: characters other than letters, digits, '.', '-'
+// and '_' become '_', and a name not starting with a letter or '_' is
+// prefixed with '_'
+func buildingID(objPath string) string {
+	name := strings.TrimSuffix(filepath.Base(objPath), filepath.Ext(objPath))
+	id := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+	if id == "" || !(id[0] == '_' || (id[0] >= 'a' && id[0] <= 'z') || (id[0] >= 'A' && id[0] <= 'Z')) {
+		id = "_" + id
+	}
+	return id
+}
+
+// ConvertFile writes the LOD1 block model of the OBJ file at objPath to
+// OutputDir as <name>.gml
+func (c *ObjToCityGMLConverter) ConvertFile(objPath string) error {
+	box, err := c.ComputeBoundingBox(objPath)
+	if err != nil {
+		return fmt.Errorf("failed to read OBJ file: %v", err)
+	}
+
+	id := buildingID(objPath)
+	model := c.CreateCityModel(id, box)
+
+	baseName := strings.TrimSuffix(filepath.Base(objPath), filepath.Ext(objPath))
+	outputPath := filepath.Join(c.OutputDir, baseName+".gml")
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	writer.WriteString(xmlHeader)
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(model); err != nil {
+		return fmt.Errorf("failed to encode CityGML: %v", err)
+	}
+	writer.WriteString("\n")
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+
+	if c.Debug {
+		fmt.Printf("  %s: building %s, extent (%.3f, %.3f, %.3f) - (%.3f, %.3f, %.3f)\n", filepath.Base(objPath), id,
+			box.Min.X, box.Min.Y, box.Min.Z, box.Max.X, box.Max.Y, box.Max.Z)
+	}
+	return nil
+}
+
+// ConvertAll converts every .obj file in InputDir
+func (c *ObjToCityGMLConverter) ConvertAll() error {
+	objFiles, err := filepath.Glob(filepath.Join(c.InputDir, "*.obj"))
+	if err != nil {
+		return fmt.Errorf("error finding OBJ files: %v", err)
+	}
+	if len(objFiles) == 0 {
+		return fmt.Errorf("no OBJ files found in directory: %s", c.InputDir)
+	}
+
+	if err := os.MkdirAll(c.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	fmt.Printf("Found %d OBJ files to process\n", len(objFiles))
+	for _, objPath := range objFiles {
+		if err := c.ConvertFile(objPath); err != nil {
+			fmt.Printf("Error processing %s: %v\n", filepath.Base(objPath), err)
+			c.Stats.FailedFiles = append(c.Stats.FailedFiles, FailedFile{filepath.Base(objPath), err.Error()})
+			continue
+		}
+		c.Stats.ConvertedFiles++
+	}
+	return nil
+}
+
+// PrintSummary prints conversion summary
+func (c *ObjToCityGMLConverter) PrintSummary() {
+	fmt.Printf("\n=== OBJ to CityGML LOD1 Converter v%s Summary ===\n", Version)
+	fmt.Printf("Converted files: %d\n", c.Stats.ConvertedFiles)
+	fmt.Printf("Failed files: %d\n", len(c.Stats.FailedFiles))
+	for _, failed := range c.Stats.FailedFiles {
+		fmt.Printf("- %s: %s\n", failed.Name, failed.Error)
+	}
+	fmt.Println("=================================================")
+}
+
+func main() {
+	var inputDir = flag.String("input", "", "Directory containing OBJ files (required)")
+	var outputDir = flag.String("output", "", "Output directory for CityGML files (required)")
+	var srs = flag.String("srs", "", "Coordinate reference system of the OBJ vertices as EPSG:xxxx (required)")
+	var debug = flag.Bool("debug", false, "Enable debug output")
+	var help = flag.Bool("help", false, "Show help message")
+	flag.Parse()
+
+	if *help {
+		fmt.Printf("OBJ to CityGML LOD1 Converter v%s\n", Version)
+		fmt.Println("Writes a CityGML 2.0 LOD1 block model for each OBJ file")
+		fmt.Println("\nUsage:")
+		fmt.Printf("  %s --input <obj_dir> --output <output_dir> --srs EPSG:<code> [options]\n\n", os.Args[0])
+		fmt.Println("Required arguments:")
+		fmt.Println("  --input      Directory containing OBJ files to convert")
+		fmt.Println("  --output     Output directory for the CityGML files")
+		fmt.Println("  --srs        Coordinate reference system of the OBJ vertices, e.g. EPSG:32748")
+		fmt.Println("\nOptional arguments:")
+		fmt.Println("  --debug      Enable debug output with the extent of every building")
+		fmt.Println("  --help       Show this help message")
+		fmt.Println("\nExample:")
+		fmt.Printf("  %s --input ./buildings --output ./lod1 --srs EPSG:32748\n", os.Args[0])
+		fmt.Println("\nEach building.obj becomes building.gml holding one bldg:Building with gml:id \"building\"")
+		fmt.Println("and a six-face bldg:lod1Solid extruded from the bounding box of its vertices.")
+		os.Exit(0)
+	}
+
+	if *inputDir == "" || *outputDir == "" || *srs == "" {
+		fmt.Println("Error: --input, --output, and --srs arguments are all required")
+		fmt.Println("Use --help for usage information")
+		os.Exit(1)
+	}
+
+	if !srsPattern.MatchString(*srs) {
+		fmt.Printf("Error: Invalid --srs '%s' (expected EPSG:xxxx, e.g. EPSG:32748)\n", *srs)
+		os.Exit(1)
+	}
+
+	// Validate input directory
+	if info, err := os.Stat(*inputDir); err != nil {
+		fmt.Printf("Error: Cannot access input directory '%s': %v\n", *inputDir, err)
+		os.Exit(1)
+	} else if !info.IsDir() {
+		fmt.Printf("Error: Input path '%s' is not a directory\n", *inputDir)
+		os.Exit(1)
+	}
+
+	fmt.Printf("OBJ to CityGML LOD1 Converter v%s\n", Version)
+	fmt.Println("================================")
+
+	converter := NewObjToCityGMLConverter(*inputDir, *outputDir, *srs)
+	converter.Debug = *debug
+
+	if err := converter.ConvertAll(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	converter.PrintSummary()
+	if len(converter.Stats.FailedFiles) > 0 {
+		os.Exit(1)
+	}
+}
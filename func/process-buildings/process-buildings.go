@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const Version = "1.0.0"
+
+// Prefixes of the flags forwarded to the elevation and semantic stages
+const (
+	elevatePrefix  = "elevate."
+	semanticPrefix = "semantic."
+)
+
+// FailedFile represents a failed file with error message, as written by both stages
+type FailedFile struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// ElevationStageStats is the part of the elevation stage's --stats-output file used in the summary
+type ElevationStageStats struct {
+	Duration       float64      `json:"duration"` // Seconds
+	ProcessedFiles int          `json:"processed_files"`
+	FailedFiles    []FailedFile `json:"failed_files"`
+	DTMSamples     int          `json:"dtm_samples"`
+	ValidSamples   int          `json:"valid_samples"`
+}
+
+// SemanticStageStats is the part of the semantic stage's --stats-output file used in the summary
+type SemanticStageStats struct {
+	Duration       float64        `json:"duration"` // Seconds
+	ProcessedFiles int            `json:"processed_files"`
+	FailedFiles    []FailedFile   `json:"failed_files"`
+	SplitFiles     map[string]int `json:"split_files"`
+}
+
+// perFileElevationStats is the elevation statistics file written when the
+// files were elevated one at a time
+type perFileElevationStats struct {
+	ElevationStageStats
+	Files []json.RawMessage `json:"files"` // Statistics of each run that completed
+}
+
+// PipelineReport combines the statistics of both stages. Like the stage
+// statistics it uses snake_case keys and durations in seconds.
+type PipelineReport struct {
+	Duration       float64         `json:"duration"` // Seconds
+	InputFiles     int             `json:"input_files"`
+	ElevatedFiles  int             `json:"elevated_files"`
+	SplitBuildings int             `json:"split_buildings"`
+	SplitFiles     int             `json:"split_files"`
+	DTMCoverage    float64         `json:"dtm_coverage"` // Fraction of DTM samples that returned a valid elevation
+	FailedFiles    []FailedFile    `json:"failed_files"` // Names are prefixed with the stage that failed them
+	Elevation      json.RawMessage `json:"elevation"`    // Full statistics of the elevation stage
+	Semantic       json.RawMessage `json:"semantic"`     // Full statistics of the semantic stage
+}
+
+// BuildingPipeline runs the DTM elevator and then the building colorizer on
+// its output. The stages run as separate commands; the elevated OBJ files
+// are written to a temporary directory in between.
+type BuildingPipeline struct {
+	InputDir         string
+	OutputDir        string
+	DTMPath          string // DTM TIF file, or a directory of tiles with DTMIsDir
+	DTMIsDir         bool
+	GeoJSONPath      string
+	ElevateCommand   []string // Command running the elevation stage, by default the elevate binary
+	SemanticCommand  []string // Command running the semantic stage, by default the semantic binary
+	ElevateArgs      []string // Extra flags for the elevation stage
+	SemanticArgs     []string // Extra flags for the semantic stage
+	KeepIntermediate bool     // Keep the elevated OBJ files and stage statistics after the run
+	Debug            bool
+	Report           PipelineReport
+}
+
+// NewBuildingPipeline creates a new BuildingPipeline running the elevate and
+// semantic tool binaries
+func NewBuildingPipeline(inputDir, outputDir, dtmPath, geoJSONPath string) *BuildingPipeline {
+	return &BuildingPipeline{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		DTMPath:         dtmPath,
+		GeoJSONPath:     geoJSONPath,
+		ElevateCommand:  toolCommand("elevate"),
+		SemanticCommand: toolCommand("semantic"),
+	}
+}
+
+// toolCommand returns the command running the tool binary name: the binary
+// next to this executable if there is one, otherwise name looked up on PATH
+func toolCommand(name string) []string {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if executable, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(executable), name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return []string{path}
+		}
+	}
+	return []string{name}
+}
+
+// Run elevates every OBJ file in InputDir, splits the elevated files into
+// OutputDir and fills Report from the statistics of both stages
+func (p *BuildingPipeline) Run() error {
+	start := time.Now()
+
+	workDir, err := os.MkdirTemp("", "process-buildings-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	if p.KeepIntermediate {
+		fmt.Printf("Intermediate files kept in: %s\n", workDir)
+	} else {
+		defer os.RemoveAll(workDir)
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(p.InputDir, "*.obj"))
+	if err != nil {
+		return fmt.Errorf("error finding OBJ files: %v", err)
+	}
+	p.Report.InputFiles = len(inputs)
+
+	elevatedDir := filepath.Join(workDir, "elevated")
+	elevateStatsPath := filepath.Join(workDir, "elevate-stats.json")
+	semanticStatsPath := filepath.Join(workDir, "semantic-stats.json")
+
+	fmt.Println("\n--- Stage 1/2: DTM elevation ---")
+	if err := p.runStage(p.ElevateCommand, p.elevateArgs(p.InputDir, elevatedDir, elevateStatsPath)); err != nil {
+		// One bad file must not cost the others their elevation
+		fmt.Printf("Elevation stage failed: %v; elevating the files one at a time\n", err)
+		if err := p.elevateEachFile(inputs, workDir, elevatedDir, elevateStatsPath); err != nil {
+			return err
+		}
+	}
+
+	semanticArgs := []string{"--obj-dir", elevatedDir, "--output", p.OutputDir, "--geojson", p.GeoJSONPath, "--stats-output", semanticStatsPath}
+	if p.Debug {
+		semanticArgs = append(semanticArgs, "--log-level", "DEBUG")
+	}
+	fmt.Println("\n--- Stage 2/2: Semantic mapping ---")
+	if err := p.runStage(p.SemanticCommand, append(semanticArgs, p.SemanticArgs...)); err != nil {
+		return fmt.Errorf("semantic stage failed: %v", err)
+	}
+
+	if err := p.mergeStatistics(elevateStatsPath, semanticStatsPath); err != nil {
+		return err
+	}
+	p.Report.Duration = time.Since(start).Seconds()
+	return nil
+}
+
+// elevateArgs returns the arguments of an elevation stage run from inputDir
+// to outputDir that writes its statistics to statsPath
+func (p *BuildingPipeline) elevateArgs(inputDir, outputDir, statsPath string) []string {
+	dtmFlag := "--dtm"
+	if p.DTMIsDir {
+		dtmFlag = "--dtm-dir"
+	}
+	args := []string{"--input", inputDir, "--output", outputDir, dtmFlag, p.DTMPath, "--stats-output", statsPath}
+	if p.Debug {
+		args = append(args, "--debug")
+	}
+	return append(args, p.ElevateArgs...)
+}
+
+// elevateEachFile runs the elevation stage once per input file, so that a
+// file making the stage exit non-zero is recorded as failed and skipped.
+// The statistics of the runs are combined into statsPath.
+func (p *BuildingPipeline) elevateEachFile(inputs []string, workDir, elevatedDir, statsPath string) error {
+	singleDir := filepath.Join(workDir, "single")
+	if err := os.MkdirAll(singleDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	runStatsPath := filepath.Join(workDir, "elevate-file-stats.json")
+
+	var combined perFileElevationStats
+	for _, input := range inputs {
+		name := filepath.Base(input)
+		link := filepath.Join(singleDir, name)
+		if err := os.Symlink(input, link); err != nil {
+			return fmt.Errorf("failed to link %s: %v", name, err)
+		}
+		os.Remove(runStatsPath)
+
+		err := p.runStage(p.ElevateCommand, p.elevateArgs(singleDir, elevatedDir, runStatsPath))
+		os.Remove(link)
+		if err != nil {
+			combined.FailedFiles = append(combined.FailedFiles, FailedFile{name, fmt.Sprintf("elevation stage failed: %v", err)})
+			continue
+		}
+
+		data, err := os.ReadFile(runStatsPath)
+		if err != nil {
+			return fmt.Errorf("failed to read elevation statistics of %s: %v", name, err)
+		}
+		var stats ElevationStageStats
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return fmt.Errorf("failed to parse elevation statistics of %s: %v", name, err)
+		}
+		combined.Duration += stats.Duration
+		combined.ProcessedFiles += stats.ProcessedFiles
+		combined.FailedFiles = append(combined.FailedFiles, stats.FailedFiles...)
+		combined.DTMSamples += stats.DTMSamples
+		combined.ValidSamples += stats.ValidSamples
+		combined.Files = append(combined.Files, data)
+	}
+
+	data, err := json.MarshalIndent(combined, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode elevation statistics: %v", err)
+	}
+	if err := os.WriteFile(statsPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write elevation statistics: %v", err)
+	}
+	return nil
+}
+
+// runStage runs command with args, streaming its output
+func (p *BuildingPipeline) runStage(command, args []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("no command configured")
+	}
+	if p.Debug {
+		fmt.Printf("Running: %s %s\n", strings.Join(command, " "), strings.Join(args, " "))
+	}
+
+	cmd := exec.Command(command[0], append(command[1:], args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// mergeStatistics reads the --stats-output files of both stages into Report
+func (p *BuildingPipeline) mergeStatistics(elevateStatsPath, semanticStatsPath string) error {
+	var err error
+	if p.Report.Elevation, err = os.ReadFile(elevateStatsPath); err != nil {
+		return fmt.Errorf("failed to read elevation statistics: %v", err)
+	}
+	if p.Report.Semantic, err = os.ReadFile(semanticStatsPath); err != nil {
+		return fmt.Errorf("failed to read semantic statistics: %v", err)
+	}
+
+	var elevation ElevationStageStats
+	if err := json.Unmarshal(p.Report.Elevation, &elevation); err != nil {
+		return fmt.Errorf("failed to parse elevation statistics: %v", err)
+	}
+	var semantic SemanticStageStats
+	if err := json.Unmarshal(p.Report.Semantic, &semantic); err != nil {
+		return fmt.Errorf("failed to parse semantic statistics: %v", err)
+	}
+
+	p.Report.ElevatedFiles = elevation.ProcessedFiles
+	p.Report.SplitBuildings = semantic.ProcessedFiles
+	p.Report.DTMCoverage = 0
+	if elevation.DTMSamples > 0 {
+		p.Report.DTMCoverage = float64(elevation.ValidSamples) / float64(elevation.DTMSamples)
+	}
+	p.Report.SplitFiles = 0
+	for _, count := range semantic.SplitFiles {
+		p.Report.SplitFiles += count
+	}
+
+	p.Report.FailedFiles = nil
+	for _, failed := range elevation.FailedFiles {
+		p.Report.FailedFiles = append(p.Report.FailedFiles, FailedFile{"elevate: " + failed.Name, failed.Error})
+	}
+	for _, failed := range semantic.FailedFiles {
+		p.Report.FailedFiles = append(p.Report.FailedFiles, FailedFile{"semantic: " + failed.Name, failed.Error})
+	}
+	return nil
+}
+
+// WriteReport writes Report to path as JSON
+func (p *BuildingPipeline) WriteReport(path string) error {
+	data, err := json.MarshalIndent(p.Report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report file: %v", err)
+	}
+	return nil
+}
+
+// PrintSummary prints the combined summary of both stages
+func (p *BuildingPipeline) PrintSummary() {
+	fmt.Printf("\n=== Building Pipeline v%s Summary ===\n", Version)
+	fmt.Printf("Processing completed in %.2f seconds\n", p.Report.Duration)
+	fmt.Printf("Input OBJ files: %d\n", p.Report.InputFiles)
+	fmt.Printf("Elevated files: %d (DTM coverage %.1f%%)\n", p.Report.ElevatedFiles, p.Report.DTMCoverage*100)
+	fmt.Printf("Split buildings: %d (%d split files)\n", p.Report.SplitBuildings, p.Report.SplitFiles)
+	fmt.Printf("Failed files: %d\n", len(p.Report.FailedFiles))
+	for _, failed := range p.Report.FailedFiles {
+		fmt.Printf("- %s: %s\n", failed.Name, failed.Error)
+	}
+	fmt.Println("=====================================")
+}
+
+// splitStageArgs removes the flags prefixed with elevate. or semantic. from
+// args and returns them, without the prefix, separately from the other
+// arguments. A forwarded flag that takes a value must be written as
+// --elevate.name=value.
+func splitStageArgs(args []string) (rest, elevateArgs, semanticArgs []string) {
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		switch {
+		case arg == name:
+			rest = append(rest, arg)
+		case strings.HasPrefix(name, elevatePrefix):
+			elevateArgs = append(elevateArgs, "--"+strings.TrimPrefix(name, elevatePrefix))
+		case strings.HasPrefix(name, semanticPrefix):
+			semanticArgs = append(semanticArgs, "--"+strings.TrimPrefix(name, semanticPrefix))
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, elevateArgs, semanticArgs
+}
+
+func main() {
+	rest, elevateArgs, semanticArgs := splitStageArgs(os.Args[1:])
+
+	var inputDir = flag.String("input", "", "Input directory containing OBJ files (required)")
+	var outputDir = flag.String("output", "", "Output directory for the split files (required)")
+	var dtmPath = flag.String("dtm", "", "Path to DTM TIF file (required unless --dtm-dir is set)")
+	var dtmDir = flag.String("dtm-dir", "", "Directory of DTM TIF tiles to stitch (alternative to --dtm)")
+	var geoJSON = flag.String("geojson", "", "Path to GeoJSON building outlines (required)")
+	var report = flag.String("report", "", "Write the combined statistics of both stages to this JSON file")
+	var keepIntermediate = flag.Bool("keep-intermediate", false, "Keep the temporary directory with the elevated OBJ files")
+	var elevateCmd = flag.String("elevate-cmd", "", "Command running the elevation stage (default: the elevate binary next to this executable or on PATH)")
+	var semanticCmd = flag.String("semantic-cmd", "", "Command running the semantic stage (default: the semantic binary next to this executable or on PATH)")
+	var debug = flag.Bool("debug", false, "Enable debug output in both stages")
+	var help = flag.Bool("help", false, "Show help message")
+	flag.CommandLine.Parse(rest)
+
+	if *help {
+		fmt.Printf("Building Pipeline v%s\n", Version)
+		fmt.Println("Elevates OBJ files on a DTM and splits them into Roof, Wall and Ground files in one run")
+		fmt.Println("\nUsage:")
+		fmt.Printf("  %s --input <obj_dir> --output <output_dir> --dtm <dtm.tif> --geojson <outlines.geojson> [options]\n\n", os.Args[0])
+		fmt.Println("Required arguments:")
+		fmt.Println("  --input      Directory containing OBJ files to process")
+		fmt.Println("  --output     Output directory for the split files")
+		fmt.Println("  --dtm        Path to DTM TIF file")
+		fmt.Println("  --dtm-dir    Directory of DTM TIF tiles, used instead of --dtm")
+		fmt.Println("  --geojson    GeoJSON file with building outlines")
+		fmt.Println("\nOptional arguments:")
+		fmt.Println("  --report     Write the combined statistics of both stages to a JSON file")
+		fmt.Println("  --keep-intermediate")
+		fmt.Println("               Keep the temporary directory with the elevated OBJ files and stage statistics")
+		fmt.Println("  --elevate-cmd")
+		fmt.Println("               Command running the elevation stage, e.g. \"go run func/elevate/elevate.go\"")
+		fmt.Println("               (default: the elevate binary next to this executable or on PATH)")
+		fmt.Println("  --semantic-cmd")
+		fmt.Println("               Command running the semantic stage, e.g. \"go run func/semantic/semantic-mapping.go\"")
+		fmt.Println("               (default: the semantic binary next to this executable or on PATH)")
+		fmt.Println("  --elevate.<flag>[=value]")
+		fmt.Println("               Pass --<flag> to the elevation stage, e.g. --elevate.mode=per-vertex")
+		fmt.Println("  --semantic.<flag>[=value]")
+		fmt.Println("               Pass --<flag> to the semantic stage, e.g. --semantic.orient-walls")
+		fmt.Println("  --debug      Enable debug output in both stages")
+		fmt.Println("  --help       Show this help message")
+		fmt.Println("\nExample:")
+		fmt.Printf("  %s --input ./buildings --output ./split --dtm ./terrain.tif --geojson ./outlines.geojson \\\n", os.Args[0])
+		fmt.Println("      --elevate.interpolation=bicubic --semantic.workers=4")
+		fmt.Println("\nBuild the elevate and semantic tools first (go build -o <dir>/elevate ./func/elevate, and likewise")
+		fmt.Println("for ./func/semantic), or point --elevate-cmd and --semantic-cmd at other commands. If the")
+		fmt.Println("elevation stage fails, the files are elevated one at a time and the failing ones skipped.")
+		os.Exit(0)
+	}
+
+	if *inputDir == "" || *outputDir == "" || *geoJSON == "" || (*dtmPath == "" && *dtmDir == "") {
+		fmt.Println("Error: --input, --output, --dtm (or --dtm-dir), and --geojson arguments are all required")
+		fmt.Println("Use --help for usage information")
+		os.Exit(1)
+	}
+
+	if *dtmPath != "" && *dtmDir != "" {
+		fmt.Println("Error: --dtm and --dtm-dir cannot be used together")
+		os.Exit(1)
+	}
+
+	// The stages set these themselves from the pipeline arguments
+	for _, forwarded := range [][]string{elevateArgs, semanticArgs} {
+		for _, arg := range forwarded {
+			name := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+			switch name {
+			case "input", "output", "dtm", "dtm-dir", "obj-dir", "geojson", "stats-output":
+				fmt.Printf("Error: --%s is set by the pipeline and cannot be forwarded to a stage\n", name)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Validate input directory
+	if info, err := os.Stat(*inputDir); err != nil {
+		fmt.Printf("Error: Cannot access input directory '%s': %v\n", *inputDir, err)
+		os.Exit(1)
+	} else if !info.IsDir() {
+		fmt.Printf("Error: Input path '%s' is not a directory\n", *inputDir)
+		os.Exit(1)
+	}
+
+	dtmSource := *dtmPath
+	if *dtmDir != "" {
+		dtmSource = *dtmDir
+	}
+
+	// The stages run with their own working directory semantics, so pass absolute paths
+	var paths []string
+	for _, path := range []string{*inputDir, *outputDir, dtmSource, *geoJSON} {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			fmt.Printf("Error: Invalid path '%s': %v\n", path, err)
+			os.Exit(1)
+		}
+		paths = append(paths, absPath)
+	}
+
+	fmt.Printf("Building Pipeline v%s\n", Version)
+	fmt.Println("=======================")
+
+	pipeline := NewBuildingPipeline(paths[0], paths[1], paths[2], paths[3])
+	pipeline.DTMIsDir = *dtmDir != ""
+	if *elevateCmd != "" {
+		pipeline.ElevateCommand = strings.Fields(*elevateCmd)
+	}
+	if *semanticCmd != "" {
+		pipeline.SemanticCommand = strings.Fields(*semanticCmd)
+	}
+	pipeline.ElevateArgs = elevateArgs
+	pipeline.SemanticArgs = semanticArgs
+	pipeline.KeepIntermediate = *keepIntermediate
+	pipeline.Debug = *debug
+
+	if err := pipeline.Run(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	pipeline.PrintSummary()
+
+	if *report != "" {
+		if err := pipeline.WriteReport(*report); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Pipeline report written to: %s\n", *report)
+	}
+
+	if len(pipeline.Report.FailedFiles) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const Version = "1.0.0"
+
+// IDRenamer replaces CityGML building IDs using an old -> new mapping
+type IDRenamer struct {
+	Mapping map[string]string
+	Stats   RenameStats
+	Debug   bool
+}
+
+// RenameStats holds renaming statistics
+type RenameStats struct {
+	RenamedIDs     int // gml:id attributes replaced with a mapped ID
+	UnchangedIDs   int // gml:id attributes without a mapping entry
+	RenamedHrefs   int // xlink:href references updated to a mapped ID
+	UnchangedHrefs int // xlink:href references without a mapping entry
+}
+
+// NewIDRenamer creates a new IDRenamer
+func NewIDRenamer(debug bool) *IDRenamer {
+	return &IDRenamer{
+		Mapping: make(map[string]string),
+		Debug:   debug,
+	}
+}
+
+// LoadMapping reads a two-column CSV (old_id, new_id). A header row whose
+// first column is "old_id" is skipped.
+func (r *IDRenamer) LoadMapping(csvPath string) error {
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	lineNum := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading mapping CSV: %v", err)
+		}
+		lineNum++
+
+		if len(record) < 2 {
+			if r.Debug {
+				fmt.Printf("Warning: Skipping line %d in %s: expected 2 columns\n", lineNum, filepath.Base(csvPath))
+			}
+			continue
+		}
+
+		oldID := strings.TrimSpace(record[0])
+		newID := strings.TrimSpace(record[1])
+		if lineNum == 1 && strings.EqualFold(oldID, "old_id") {
+			continue
+		}
+		if oldID == "" || newID == "" {
+			continue
+		}
+
+		r.Mapping[oldID] = newID
+	}
+
+	if len(r.Mapping) == 0 {
+		return fmt.Errorf("no ID mappings found in %s", csvPath)
+	}
+
+	fmt.Printf("Loaded %d ID mappings\n", len(r.Mapping))
+	return nil
+}
+
+// RenameIDs replaces all mapped gml:id="old_id" and xlink:href="#old_id"
+// occurrences in content. Unmapped IDs are left unchanged.
+func (r *IDRenamer) RenameIDs(content string) string {
+	content = r.replaceAttribute(content, `gml:id="`, &r.Stats.RenamedIDs, &r.Stats.UnchangedIDs)
+	content = r.replaceAttribute(content, `xlink:href="#`, &r.Stats.RenamedHrefs, &r.Stats.UnchangedHrefs)
+	return content
+}
+
+// replaceAttribute rewrites the ID following each occurrence of prefix up to
+// the closing quote, counting renamed and unchanged occurrences
+func (r *IDRenamer) replaceAttribute(content, prefix string, renamed, unchanged *int) string {
+	var result strings.Builder
+	pos := 0
+	for {
+		start := strings.Index(content[pos:], prefix)
+		if start == -1 {
+			break
+		}
+		start += pos + len(prefix)
+
+		end := strings.Index(content[start:], `"`)
+		if end == -1 {
+			break
+		}
+		end += start
+
+		id := content[start:end]
+		result.WriteString(content[pos:start])
+		if newID, ok := r.Mapping[id]; ok {
+			result.WriteString(newID)
+			*renamed++
+			if r.Debug {
+				fmt.Printf("  %s%s -> %s\n", prefix, id, newID)
+			}
+		} else {
+			result.WriteString(id)
+			*unchanged++
+		}
+
+		pos = end
+	}
+	result.WriteString(content[pos:])
+
+	return result.String()
+}
+
+// RenameFile renames the IDs in inputFile and writes the result to outputFile
+func (r *IDRenamer) RenameFile(inputFile, outputFile string) error {
+	data, err := ioutil.ReadFile(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %v", err)
+	}
+
+	content := r.RenameIDs(string(data))
+
+	if err := ioutil.WriteFile(outputFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+
+	fmt.Printf("Successfully created renamed CityGML file: %s\n", outputFile)
+	return nil
+}
+
+// PrintSummary prints renaming summary
+func (r *IDRenamer) PrintSummary() {
+	fmt.Printf("\n=== CityGML ID Renamer v%s Summary ===\n", Version)
+	fmt.Printf("Renamed IDs: %d\n", r.Stats.RenamedIDs)
+	fmt.Printf("Unchanged IDs: %d\n", r.Stats.UnchangedIDs)
+	fmt.Printf("Renamed references: %d\n", r.Stats.RenamedHrefs)
+	fmt.Printf("Unchanged references: %d\n", r.Stats.UnchangedHrefs)
+	fmt.Println("=======================================")
+}
+
+func main() {
+	var inputFile = flag.String("input", "", "Input CityGML file (required)")
+	var outputFile = flag.String("output", "", "Output path for renamed CityGML file (required)")
+	var mappingFile = flag.String("mapping", "", "CSV file with old_id,new_id rows (required)")
+	var debug = flag.Bool("debug", false, "Enable debug output")
+	var help = flag.Bool("help", false, "Show help message")
+	flag.Parse()
+
+	if *help {
+		fmt.Printf("CityGML ID Renamer v%s\n", Version)
+		fmt.Println("Renames CityGML building IDs using a mapping CSV")
+		fmt.Println("\nUsage:")
+		fmt.Printf("  %s --input <file.gml> --output <file.gml> --mapping <mapping.csv> [options]\n\n", os.Args[0])
+		fmt.Println("Required arguments:")
+		fmt.Println("  --input      CityGML file to rename IDs in")
+		fmt.Println("  --output     Output path for the renamed CityGML file")
+		fmt.Println("  --mapping    Two-column CSV (old_id, new_id)")
+		fmt.Println("\nOptional arguments:")
+		fmt.Println("  --debug      Enable debug output listing every replaced ID")
+		fmt.Println("  --help       Show this help message")
+		fmt.Println("\nExample:")
+		fmt.Printf("  %s --input merged.gml --output parcels.gml --mapping parcels.csv\n", os.Args[0])
+		fmt.Println("\nThe script replaces gml:id=\"old_id\" and xlink:href=\"#old_id\" with the mapped new_id.")
+		fmt.Println("IDs without a mapping entry are left unchanged.")
+		os.Exit(0)
+	}
+
+	if *inputFile == "" || *outputFile == "" || *mappingFile == "" {
+		fmt.Println("Error: --input, --output, and --mapping arguments are all required")
+		fmt.Println("Use --help for usage information")
+		os.Exit(1)
+	}
+
+	// Validate input files
+	if _, err := os.Stat(*inputFile); err != nil {
+		fmt.Printf("Error: Cannot access input file '%s': %v\n", *inputFile, err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*mappingFile); err != nil {
+		fmt.Printf("Error: Cannot access mapping file '%s': %v\n", *mappingFile, err)
+		os.Exit(1)
+	}
+
+	absOutputFile, err := filepath.Abs(*outputFile)
+	if err != nil {
+		fmt.Printf("Error: Invalid output file '%s': %v\n", *outputFile, err)
+		os.Exit(1)
+	}
+
+	// Ensure output directory exists
+	outputDir := filepath.Dir(absOutputFile)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Printf("Error: Cannot create output directory '%s': %v\n", outputDir, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("CityGML ID Renamer v%s\n", Version)
+	fmt.Println("======================")
+
+	renamer := NewIDRenamer(*debug)
+
+	if err := renamer.LoadMapping(*mappingFile); err != nil {
+		fmt.Printf("Error loading mapping: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := renamer.RenameFile(*inputFile, absOutputFile); err != nil {
+		fmt.Printf("Error renaming IDs: %v\n", err)
+		os.Exit(1)
+	}
+
+	renamer.PrintSummary()
+}
@@ -2,28 +2,96 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
+	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode"
+
+	"citygml-gen/internal/axes"
+	"citygml-gen/internal/config"
+	"citygml-gen/internal/outline"
+	"citygml-gen/internal/progress"
+	"citygml-gen/internal/units"
+	"citygml-gen/internal/version"
 )
 
 const Version = "2.0.0"
 
+// LogLevel is the minimum severity of messages printed by a Logger
+type LogLevel int
+
+// Log levels in increasing severity
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+// logLevelNames maps --log-level values to log levels
+var logLevelNames = map[string]LogLevel{
+	"DEBUG": LogDebug,
+	"INFO":  LogInfo,
+	"WARN":  LogWarn,
+	"ERROR": LogError,
+}
+
+// ParseLogLevel converts a level name such as "INFO" (case-insensitive) to a LogLevel
+func ParseLogLevel(name string) (LogLevel, error) {
+	level, ok := logLevelNames[strings.ToUpper(name)]
+	if !ok {
+		return LogInfo, fmt.Errorf("unknown log level '%s' (expected DEBUG, INFO, WARN or ERROR)", name)
+	}
+	return level, nil
+}
+
+// Logger prints messages at or above Level to standard output
+type Logger struct {
+	Level LogLevel
+}
+
+// Log prints a formatted message if level is enabled
+func (l *Logger) Log(level LogLevel, format string, args ...interface{}) {
+	if l.Enabled(level) {
+		fmt.Printf(format, args...)
+	}
+}
+
+// Enabled reports whether messages at level are printed
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level >= l.Level
+}
+
 // Color represents RGBA color values
 type Color struct {
-	R, G, B, A float64
+	R float64 `json:"r"`
+	G float64 `json:"g"`
+	B float64 `json:"b"`
+	A float64 `json:"a"`
 }
 
-// Colors definition with alpha channel
+// Colors are the built-in material colors with alpha channel
 var Colors = map[string]Color{
 	"Roof":   {0.6627, 0.2627, 0.0863, 1.0}, // Orange
 	"Wall":   {0.8314, 0.8314, 0.8471, 1.0}, // Grey
@@ -38,14 +106,35 @@ type Vector3 struct {
 // Face represents a mesh face with vertex indices
 type Face []int
 
-// Polygon represents a 2D polygon
+// FaceVertex is one corner of an OBJ face record: 0-based indices of its
+// vertex, texture coordinate and normal, with -1 for absent components
+type FaceVertex struct {
+	VertexIdx   int
+	TexCoordIdx int
+	NormalIdx   int
+}
+
+// TexCoord represents an OBJ texture coordinate. Valid is false for vertices
+// referenced without a vt index.
+type TexCoord struct {
+	U, V  float64
+	Valid bool
+}
+
+// Polygon is a building outline with its ground height and X/Y extent
 type Polygon struct {
-	Coordinates [][]float64
+	outline.Polygon
+	GroundZ    float64 // Minimum Z of the outline coordinates, valid if HasZ
+	HasZ       bool
+	MinX, MinY float64 // X/Y extent of the exterior ring
+	MaxX, MaxY float64
 }
 
 // GeoJSONFeature represents a GeoJSON feature
 type GeoJSONFeature struct {
-	Geometry struct {
+	ID         interface{}            `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   struct {
 		Type        string          `json:"type"`
 		Coordinates json.RawMessage `json:"coordinates"`
 	} `json:"geometry"`
@@ -61,15 +150,141 @@ type OptimizedFaceGroup struct {
 	Material          string
 	Faces             []Face
 	OptimizedVertices []Vector3
-	VertexMapping     map[int]int // old index -> new index
+	OptimizedUVs      []TexCoord       // Texture coordinate per optimized vertex, empty unless UV islands are kept
+	FaceVertices      [][]FaceVertex   // vt/vn references of each face's corners, parallel to Faces; nil if not loaded
+	VertexMapping     map[int]int      // old index -> new index
+	Adjacency         map[[2]int][]int // directed edge (v_a, v_b) -> indices of faces containing it
+}
+
+// Clone returns a deep copy of the group, so that passes running
+// concurrently can each modify their own copy
+func (g *OptimizedFaceGroup) Clone() *OptimizedFaceGroup {
+	clone := &OptimizedFaceGroup{Material: g.Material}
+
+	if g.Faces != nil {
+		clone.Faces = make([]Face, len(g.Faces))
+		for i, face := range g.Faces {
+			clone.Faces[i] = append(Face(nil), face...)
+		}
+	}
+	if g.OptimizedVertices != nil {
+		clone.OptimizedVertices = append([]Vector3(nil), g.OptimizedVertices...)
+	}
+	if g.OptimizedUVs != nil {
+		clone.OptimizedUVs = append([]TexCoord(nil), g.OptimizedUVs...)
+	}
+	if g.FaceVertices != nil {
+		clone.FaceVertices = make([][]FaceVertex, len(g.FaceVertices))
+		for i, corners := range g.FaceVertices {
+			clone.FaceVertices[i] = append([]FaceVertex(nil), corners...)
+		}
+	}
+	if g.VertexMapping != nil {
+		clone.VertexMapping = make(map[int]int, len(g.VertexMapping))
+		for oldIdx, newIdx := range g.VertexMapping {
+			clone.VertexMapping[oldIdx] = newIdx
+		}
+	}
+	if g.Adjacency != nil {
+		clone.Adjacency = make(map[[2]int][]int, len(g.Adjacency))
+		for edge, faces := range g.Adjacency {
+			clone.Adjacency[edge] = append([]int(nil), faces...)
+		}
+	}
+
+	return clone
+}
+
+// RoofPlane is a planar roof segment found by ClusterRoofPlanes
+type RoofPlane struct {
+	Faces    []int   // Indices into the group's Faces
+	Normal   Vector3 // Area-weighted unit normal
+	Centroid Vector3 // Area-weighted centroid
 }
 
 // MeshAnalyzer handles mesh analysis and validation
-type MeshAnalyzer struct{}
+type MeshAnalyzer struct {
+	PeakThreshold   float64 // Fraction of the largest histogram bin a bin must exceed to count as a ground peak
+	GroundTolerance float64 // Largest Z distance of a RANSAC ground plane inlier
+}
 
 // NewMeshAnalyzer creates a new MeshAnalyzer
 func NewMeshAnalyzer() *MeshAnalyzer {
-	return &MeshAnalyzer{}
+	return &MeshAnalyzer{PeakThreshold: 0.1, GroundTolerance: 0.01}
+}
+
+// DetectGroundPlaneRANSAC finds the ground level as the horizontal plane
+// (Z = constant) with the most inliers. Each iteration samples 3 vertices of
+// horizontal faces, skips samples more than GroundTolerance apart in Z, and
+// counts the horizontal-face vertices within GroundTolerance of their mean Z.
+// Ties go to the lower plane. The Z of the best plane is refined to the mean
+// of its inliers. Meshes without horizontal faces sample all vertices; if no
+// sample fits, the histogram analysis is used instead.
+func (ma *MeshAnalyzer) DetectGroundPlaneRANSAC(vertices []Vector3, faces []Face, iterations int) float64 {
+	if len(vertices) == 0 {
+		return 0.0
+	}
+
+	// Candidate points are the vertices of near-horizontal faces
+	seen := make(map[int]bool)
+	var candidates []float64
+	for _, face := range faces {
+		if len(face) < 3 || math.Abs(ma.faceNormal(vertices, face).Z) <= 0.95 {
+			continue
+		}
+		for _, idx := range face {
+			if !seen[idx] {
+				seen[idx] = true
+				candidates = append(candidates, vertices[idx].Z)
+			}
+		}
+	}
+	if len(candidates) < 3 {
+		candidates = candidates[:0]
+		for _, v := range vertices {
+			candidates = append(candidates, v.Z)
+		}
+	}
+
+	zValues := make([]float64, len(vertices))
+	for i, v := range vertices {
+		zValues[i] = v.Z
+	}
+	if len(candidates) < 3 {
+		return ma.AnalyzeZDistribution(zValues)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	bestInliers := 0
+	bestZ := 0.0
+	for i := 0; i < iterations; i++ {
+		a := candidates[rng.Intn(len(candidates))]
+		b := candidates[rng.Intn(len(candidates))]
+		c := candidates[rng.Intn(len(candidates))]
+		if math.Max(a, math.Max(b, c))-math.Min(a, math.Min(b, c)) > ma.GroundTolerance {
+			continue
+		}
+
+		planeZ := (a + b + c) / 3
+		inliers := 0
+		var sum float64
+		for _, z := range candidates {
+			if math.Abs(z-planeZ) <= ma.GroundTolerance {
+				inliers++
+				sum += z
+			}
+		}
+		refined := sum / float64(inliers)
+		if inliers > bestInliers || (inliers == bestInliers && refined < bestZ) {
+			bestInliers = inliers
+			bestZ = refined
+		}
+	}
+
+	if bestInliers == 0 {
+		return ma.AnalyzeZDistribution(zValues)
+	}
+	return bestZ
 }
 
 // AnalyzeZDistribution analyzes Z-coordinate distribution to find ground level
@@ -78,8 +293,30 @@ func (ma *MeshAnalyzer) AnalyzeZDistribution(zValues []float64) float64 {
 		return 0.0
 	}
 
-	// Create histogram of Z values
-	minZ := zValues[0]
+	minZ, binWidth, hist := ma.ZHistogram(zValues)
+	if binWidth == 0 {
+		return minZ
+	}
+
+	// Find the lowest significant peak
+	significantThreshold := ma.significantThreshold(hist)
+	for i, count := range hist {
+		if float64(count) > significantThreshold {
+			return minZ + float64(i)*binWidth
+		}
+	}
+
+	return minZ
+}
+
+// ZHistogram bins zValues into 50 equal-width bins between their minimum and
+// maximum. binWidth is 0 and hist holds a single bin when all values are equal.
+func (ma *MeshAnalyzer) ZHistogram(zValues []float64) (minZ, binWidth float64, hist []int) {
+	if len(zValues) == 0 {
+		return 0, 0, nil
+	}
+
+	minZ = zValues[0]
 	maxZ := zValues[0]
 	for _, z := range zValues {
 		if z < minZ {
@@ -91,12 +328,12 @@ func (ma *MeshAnalyzer) AnalyzeZDistribution(zValues []float64) float64 {
 	}
 
 	bins := 50
-	binWidth := (maxZ - minZ) / float64(bins)
+	binWidth = (maxZ - minZ) / float64(bins)
 	if binWidth == 0 {
-		return minZ
+		return minZ, 0, []int{len(zValues)}
 	}
 
-	hist := make([]int, bins)
+	hist = make([]int, bins)
 	for _, z := range zValues {
 		binIndex := int((z - minZ) / binWidth)
 		if binIndex >= bins {
@@ -104,23 +341,62 @@ func (ma *MeshAnalyzer) AnalyzeZDistribution(zValues []float64) float64 {
 		}
 		hist[binIndex]++
 	}
+	return minZ, binWidth, hist
+}
 
-	// Find the lowest significant peak
+// significantThreshold returns the count a histogram bin must exceed to be a
+// significant peak
+func (ma *MeshAnalyzer) significantThreshold(hist []int) float64 {
 	maxCount := 0
 	for _, count := range hist {
 		if count > maxCount {
 			maxCount = count
 		}
 	}
+	return float64(maxCount) * ma.PeakThreshold
+}
+
+// ExportZHistogram writes the Z histogram used for ground detection to a CSV
+// file with z_bin_centre, vertex_count and is_significant_peak columns
+func (ma *MeshAnalyzer) ExportZHistogram(csvPath string, zValues []float64) error {
+	file, err := os.Create(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to create histogram CSV: %v", err)
+	}
+	defer file.Close()
+
+	minZ, binWidth, hist := ma.ZHistogram(zValues)
+	threshold := ma.significantThreshold(hist)
 
-	significantThreshold := float64(maxCount) * 0.1
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"z_bin_centre", "vertex_count", "is_significant_peak"})
 	for i, count := range hist {
-		if float64(count) > significantThreshold {
-			return minZ + float64(i)*binWidth
-		}
+		writer.Write([]string{
+			strconv.FormatFloat(minZ+(float64(i)+0.5)*binWidth, 'f', 3, 64),
+			strconv.Itoa(count),
+			strconv.FormatBool(float64(count) > threshold),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write histogram CSV: %v", err)
 	}
+	return nil
+}
 
-	return minZ
+// ComputeNormalisedHeightAboveGround maps each vertex to its height above
+// groundHeight as a fraction of maxHeight - groundHeight, clamped to [0,1].
+// All fractions are 0 if maxHeight is not above groundHeight.
+func (ma *MeshAnalyzer) ComputeNormalisedHeightAboveGround(vertices []Vector3, groundHeight, maxHeight float64) []float64 {
+	heights := make([]float64, len(vertices))
+	span := maxHeight - groundHeight
+	if span <= 0 {
+		return heights
+	}
+	for i, v := range vertices {
+		heights[i] = math.Max(0, math.Min(1, (v.Z-groundHeight)/span))
+	}
+	return heights
 }
 
 // GetFaceCentroid calculates the centroid of a face
@@ -135,9 +411,325 @@ func (ma *MeshAnalyzer) GetFaceCentroid(vertices []Vector3, face Face) Vector3 {
 	return Vector3{sum.X / count, sum.Y / count, sum.Z / count}
 }
 
+// ComputeFaceArea computes the area of a planar face by fan triangulation
+func (ma *MeshAnalyzer) ComputeFaceArea(vertices []Vector3, face Face) float64 {
+	if len(face) < 3 {
+		return 0
+	}
+
+	var area float64
+	v0 := vertices[face[0]]
+	for i := 1; i < len(face)-1; i++ {
+		v1 := vertices[face[i]]
+		v2 := vertices[face[i+1]]
+
+		edge1 := Vector3{v1.X - v0.X, v1.Y - v0.Y, v1.Z - v0.Z}
+		edge2 := Vector3{v2.X - v0.X, v2.Y - v0.Y, v2.Z - v0.Z}
+		cross := Vector3{
+			edge1.Y*edge2.Z - edge1.Z*edge2.Y,
+			edge1.Z*edge2.X - edge1.X*edge2.Z,
+			edge1.X*edge2.Y - edge1.Y*edge2.X,
+		}
+		area += math.Sqrt(cross.X*cross.X+cross.Y*cross.Y+cross.Z*cross.Z) / 2
+	}
+	return area
+}
+
+// wallOrientations are the compass directions returned by GetFaceOrientation, clockwise from north
+var wallOrientations = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// GetFaceOrientation returns the compass direction the normal points to in the
+// X/Y plane, taking +Y as north and +X as east: N, NE, E, SE, S, SW, W or NW,
+// each covering 45°. A normal without a horizontal component returns "".
+func (ma *MeshAnalyzer) GetFaceOrientation(normal Vector3) string {
+	if math.Hypot(normal.X, normal.Y) < 1e-9 {
+		return ""
+	}
+	azimuth := math.Atan2(normal.X, normal.Y) * 180 / math.Pi // Degrees clockwise from north
+	sector := int(math.Floor((azimuth + 22.5) / 45))
+	return wallOrientations[(sector%8+8)%8]
+}
+
+// pointSpacingSamples is the number of edges EstimatePointSpacing measures
+const pointSpacingSamples = 100
+
+// EstimatePointSpacing estimates the mesh resolution as the mean length of
+// 100 face edges sampled with a fixed seed, or of all edges if there are
+// fewer. It returns 0 for a mesh without edges.
+func (ma *MeshAnalyzer) EstimatePointSpacing(vertices []Vector3, faces []Face) float64 {
+	var edges [][2]int
+	for _, face := range faces {
+		if len(face) < 2 {
+			continue
+		}
+		for i := range face {
+			edges = append(edges, [2]int{face[i], face[(i+1)%len(face)]})
+		}
+	}
+	if len(edges) == 0 {
+		return 0
+	}
+
+	if len(edges) > pointSpacingSamples {
+		rng := rand.New(rand.NewSource(1))
+		rng.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+		edges = edges[:pointSpacingSamples]
+	}
+
+	var total float64
+	for _, edge := range edges {
+		total += math.Sqrt(distanceSquared(vertices[edge[0]], vertices[edge[1]]))
+	}
+	return total / float64(len(edges))
+}
+
+// ComputeMeshVolume computes the enclosed volume of a closed mesh using the
+// divergence theorem, summing signed tetrahedron volumes over fan-triangulated
+// faces. The result is only meaningful for closed, consistently wound meshes.
+func (ma *MeshAnalyzer) ComputeMeshVolume(vertices []Vector3, faces []Face) float64 {
+	var volume float64
+	for _, face := range faces {
+		if len(face) < 3 {
+			continue
+		}
+		v0 := vertices[face[0]]
+		for i := 1; i < len(face)-1; i++ {
+			v1 := vertices[face[i]]
+			v2 := vertices[face[i+1]]
+
+			// v0 · (v1 × v2)
+			cross := Vector3{
+				v1.Y*v2.Z - v1.Z*v2.Y,
+				v1.Z*v2.X - v1.X*v2.Z,
+				v1.X*v2.Y - v1.Y*v2.X,
+			}
+			volume += (v0.X*cross.X + v0.Y*cross.Y + v0.Z*cross.Z) / 6
+		}
+	}
+	return math.Abs(volume)
+}
+
+// ComputeRoofPitch computes the area-weighted average tilt of the group's faces
+// in degrees from horizontal (0 = flat roof). Face indices refer to vertices.
+func (ma *MeshAnalyzer) ComputeRoofPitch(group *OptimizedFaceGroup, vertices []Vector3) float64 {
+	var weightedPitch, totalArea float64
+	for _, face := range group.Faces {
+		if len(face) < 3 {
+			continue
+		}
+
+		// Sum of fan triangle cross products: its length is twice the face area
+		// and its direction is the face normal
+		var normal Vector3
+		v0 := vertices[face[0]]
+		for i := 1; i < len(face)-1; i++ {
+			v1 := vertices[face[i]]
+			v2 := vertices[face[i+1]]
+			edge1 := Vector3{v1.X - v0.X, v1.Y - v0.Y, v1.Z - v0.Z}
+			edge2 := Vector3{v2.X - v0.X, v2.Y - v0.Y, v2.Z - v0.Z}
+			normal.X += edge1.Y*edge2.Z - edge1.Z*edge2.Y
+			normal.Y += edge1.Z*edge2.X - edge1.X*edge2.Z
+			normal.Z += edge1.X*edge2.Y - edge1.Y*edge2.X
+		}
+
+		length := math.Sqrt(normal.X*normal.X + normal.Y*normal.Y + normal.Z*normal.Z)
+		if length == 0 {
+			continue
+		}
+
+		area := length / 2
+		pitch := math.Acos(math.Min(math.Abs(normal.Z)/length, 1)) * 180 / math.Pi
+		weightedPitch += pitch * area
+		totalArea += area
+	}
+
+	if totalArea == 0 {
+		return 0
+	}
+	return weightedPitch / totalArea
+}
+
+// duplicateNormalAngle is the largest angle in degrees between the normals of near-duplicate faces
+const duplicateNormalAngle = 10.0
+
+// FindNearDuplicateFaces returns index pairs (a, b) of faces in facesA and
+// facesB whose centroids lie within distThreshold of each other and whose
+// normals differ by at most 10°
+func (ma *MeshAnalyzer) FindNearDuplicateFaces(facesA, facesB []Face, verticesA, verticesB []Vector3, distThreshold float64) [][2]int {
+	minCos := math.Cos(duplicateNormalAngle * math.Pi / 180)
+	thresholdSq := distThreshold * distThreshold
+
+	centroidsB := make([]Vector3, len(facesB))
+	normalsB := make([]Vector3, len(facesB))
+	for j, face := range facesB {
+		if len(face) < 3 {
+			continue
+		}
+		centroidsB[j] = ma.GetFaceCentroid(verticesB, face)
+		normalsB[j] = ma.faceNormal(verticesB, face)
+	}
+
+	var pairs [][2]int
+	for i, face := range facesA {
+		if len(face) < 3 {
+			continue
+		}
+		centroidA := ma.GetFaceCentroid(verticesA, face)
+		normalA := ma.faceNormal(verticesA, face)
+		for j, centroidB := range centroidsB {
+			if len(facesB[j]) < 3 || distanceSquared(centroidA, centroidB) > thresholdSq {
+				continue
+			}
+			normalB := normalsB[j]
+			if normalA.X*normalB.X+normalA.Y*normalB.Y+normalA.Z*normalB.Z >= minCos {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+// faceNormal returns the unit normal of a face from the sum of its fan
+// triangle cross products, or the zero vector for a degenerate face
+func (ma *MeshAnalyzer) faceNormal(vertices []Vector3, face Face) Vector3 {
+	var normal Vector3
+	v0 := vertices[face[0]]
+	for i := 1; i < len(face)-1; i++ {
+		v1 := vertices[face[i]]
+		v2 := vertices[face[i+1]]
+		edge1 := Vector3{v1.X - v0.X, v1.Y - v0.Y, v1.Z - v0.Z}
+		edge2 := Vector3{v2.X - v0.X, v2.Y - v0.Y, v2.Z - v0.Z}
+		normal.X += edge1.Y*edge2.Z - edge1.Z*edge2.Y
+		normal.Y += edge1.Z*edge2.X - edge1.X*edge2.Z
+		normal.Z += edge1.X*edge2.Y - edge1.Y*edge2.X
+	}
+
+	length := math.Sqrt(normal.X*normal.X + normal.Y*normal.Y + normal.Z*normal.Z)
+	if length == 0 {
+		return Vector3{}
+	}
+	return Vector3{normal.X / length, normal.Y / length, normal.Z / length}
+}
+
+// ComputeHausdorffDistance returns the directed Hausdorff distance from the
+// simplified to the original vertex set: the largest distance from a
+// simplified vertex to its nearest original vertex. It measures how far
+// simplification moved vertices, not how far removed vertices lie from the
+// result.
+func (ma *MeshAnalyzer) ComputeHausdorffDistance(original, simplified []Vector3) float64 {
+	if len(original) == 0 || len(simplified) == 0 {
+		return 0
+	}
+	return directedHausdorff(simplified, original)
+}
+
+// ComputeSymmetricHausdorffDistance returns the symmetric Hausdorff distance
+// between the original and simplified vertex sets: the larger of the directed
+// distances in both directions. Unlike ComputeHausdorffDistance it also
+// counts original vertices that simplification removed.
+func (ma *MeshAnalyzer) ComputeSymmetricHausdorffDistance(original, simplified []Vector3) float64 {
+	if len(original) == 0 || len(simplified) == 0 {
+		return 0
+	}
+	return math.Max(directedHausdorff(simplified, original), directedHausdorff(original, simplified))
+}
+
+// directedHausdorff returns the largest distance from a vertex of from to its
+// nearest vertex of to. Small sets are searched by brute force, larger ones
+// through a kd-tree.
+func directedHausdorff(from, to []Vector3) float64 {
+	var tree *kdNode
+	if len(to) > 64 {
+		points := make([]Vector3, len(to))
+		copy(points, to)
+		tree = buildKDTree(points, 0)
+	}
+
+	maxDistSq := 0.0
+	for _, v := range from {
+		nearestSq := math.Inf(1)
+		if tree != nil {
+			tree.nearest(v, &nearestSq)
+		} else {
+			for _, o := range to {
+				nearestSq = math.Min(nearestSq, distanceSquared(v, o))
+			}
+		}
+		maxDistSq = math.Max(maxDistSq, nearestSq)
+	}
+	return math.Sqrt(maxDistSq)
+}
+
+// kdNode is a node of a 3D kd-tree used for nearest vertex queries
+type kdNode struct {
+	Point       Vector3
+	Axis        int
+	Left, Right *kdNode
+}
+
+// buildKDTree builds a balanced kd-tree, reordering points in place
+func buildKDTree(points []Vector3, depth int) *kdNode {
+	if len(points) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+	sort.Slice(points, func(i, j int) bool {
+		return axisValue(points[i], axis) < axisValue(points[j], axis)
+	})
+
+	median := len(points) / 2
+	return &kdNode{
+		Point: points[median],
+		Axis:  axis,
+		Left:  buildKDTree(points[:median], depth+1),
+		Right: buildKDTree(points[median+1:], depth+1),
+	}
+}
+
+// nearest updates bestSq with the squared distance to the closest point in the subtree
+func (n *kdNode) nearest(target Vector3, bestSq *float64) {
+	if n == nil {
+		return
+	}
+
+	if d := distanceSquared(target, n.Point); d < *bestSq {
+		*bestSq = d
+	}
+
+	diff := axisValue(target, n.Axis) - axisValue(n.Point, n.Axis)
+	near, far := n.Left, n.Right
+	if diff > 0 {
+		near, far = n.Right, n.Left
+	}
+
+	near.nearest(target, bestSq)
+	if diff*diff < *bestSq {
+		far.nearest(target, bestSq)
+	}
+}
+
+// axisValue returns the coordinate of v along axis (0=X, 1=Y, 2=Z)
+func axisValue(v Vector3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// distanceSquared returns the squared Euclidean distance between a and b
+func distanceSquared(a, b Vector3) float64 {
+	dx, dy, dz := a.X-b.X, a.Y-b.Y, a.Z-b.Z
+	return dx*dx + dy*dy + dz*dz
+}
+
 // GeometryValidator handles geometric validation and consistency checks
 type GeometryValidator struct {
 	Tolerance float64
+	InvertZ   bool // Tunnel orientation: ground faces point down and roof faces face downward
 }
 
 // NewGeometryValidator creates a new GeometryValidator
@@ -158,8 +750,11 @@ func (gv *GeometryValidator) ValidateGroundClassification(vertices []Vector3, fa
 		return false
 	}
 
-	// Check if face is horizontal
+	// Check if face is horizontal, and with InvertZ that it faces down
 	normal := gv.GetFaceNormal(vertices, face)
+	if gv.InvertZ && normal.Z >= 0 {
+		return false
+	}
 	return math.Abs(normal.Z) > 0.95
 }
 
@@ -190,118 +785,857 @@ func (gv *GeometryValidator) GetFaceNormal(vertices []Vector3, face Face) Vector
 	return Vector3{normal.X / magnitude, normal.Y / magnitude, normal.Z / magnitude}
 }
 
-// Statistics holds processing statistics
-type Statistics struct {
-	ProcessedFiles        int
-	FailedFiles           []FailedFile
-	ClassificationChanges int
-	SplitFiles            map[string]int         // Track split files per material
-	VertexOptimization    map[string]VertexStats // Track vertex optimization per material
-}
+// ValidateManifold counts the undirected edges of faces used by only one
+// face (open edges) and by more than two faces (non-manifold edges). The mesh
+// is a closed manifold when there are neither. Edges are compared by vertex
+// index, so duplicated vertices at the same position count as separate.
+func (gv *GeometryValidator) ValidateManifold(faces []Face) (isManifold bool, openEdges, nonManifoldEdges int) {
+	edgeFaces := make(map[[2]int]int)
+	for _, face := range faces {
+		for i := range face {
+			a, b := face[i], face[(i+1)%len(face)]
+			if a > b {
+				a, b = b, a
+			}
+			edgeFaces[[2]int{a, b}]++
+		}
+	}
 
-// VertexStats tracks vertex optimization statistics
-type VertexStats struct {
-	OriginalVertices  int
-	OptimizedVertices int
-	ReductionPercent  float64
+	for _, count := range edgeFaces {
+		if count == 1 {
+			openEdges++
+		} else if count > 2 {
+			nonManifoldEdges++
+		}
+	}
+	return openEdges == 0 && nonManifoldEdges == 0, openEdges, nonManifoldEdges
 }
 
-// FailedFile represents a failed file with error message
-type FailedFile struct {
-	Name  string
-	Error string
+// CheckFaceOrientation counts faces whose normal points toward the mesh
+// centroid (inside-out faces). The mesh is consistent when none are inverted.
+func (gv *GeometryValidator) CheckFaceOrientation(vertices []Vector3, faces []Face) (consistent bool, invertedCount int) {
+	invertedCount = len(gv.findInvertedFaces(vertices, faces))
+	return invertedCount == 0, invertedCount
 }
 
-// BuildingColorizer main class
-type BuildingColorizer struct {
-	ObjDir              string
-	OutputDir           string
-	GeoJSONPath         string
-	BuildingOutlines    map[string]Polygon
-	MeshAnalyzer        *MeshAnalyzer
-	GeometryValidator   *GeometryValidator
-	ClassificationCache map[int]string
-	Stats               Statistics
-	StartTime           time.Time
-	Debug               bool
-}
-
-// NewBuildingColorizer creates a new BuildingColorizer
-func NewBuildingColorizer(objDir, outputDir, geoJSONPath string, debug bool) *BuildingColorizer {
-	bc := &BuildingColorizer{
-		ObjDir:              objDir,
-		OutputDir:           outputDir,
-		GeoJSONPath:         geoJSONPath,
-		MeshAnalyzer:        NewMeshAnalyzer(),
-		GeometryValidator:   NewGeometryValidator(0.01),
-		ClassificationCache: make(map[int]string),
-		StartTime:           time.Now(),
-		Debug:               debug,
-		Stats: Statistics{
-			SplitFiles:         make(map[string]int),
-			VertexOptimization: make(map[string]VertexStats),
-		},
+// findInvertedFaces returns the indices of faces whose normal points toward the mesh centroid
+func (gv *GeometryValidator) findInvertedFaces(vertices []Vector3, faces []Face) []int {
+	if len(vertices) == 0 {
+		return nil
 	}
 
-	bc.BuildingOutlines = bc.loadAllBuildingOutlines()
-	return bc
-}
-
-// LoadObjFile loads vertices and faces from OBJ file
-func (bc *BuildingColorizer) LoadObjFile(objPath string) ([]Vector3, []Face, error) {
-	file, err := os.Open(objPath)
-	if err != nil {
-		return nil, nil, err
+	var centroid Vector3
+	for _, v := range vertices {
+		centroid.X += v.X
+		centroid.Y += v.Y
+		centroid.Z += v.Z
 	}
-	defer file.Close()
-
-	var vertices []Vector3
-	var faces []Face
+	count := float64(len(vertices))
+	centroid = Vector3{centroid.X / count, centroid.Y / count, centroid.Z / count}
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "#") || line == "" {
+	var inverted []int
+	for i, face := range faces {
+		if len(face) < 3 {
 			continue
 		}
 
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
-			continue
+		var faceCentroid Vector3
+		for _, idx := range face {
+			faceCentroid.X += vertices[idx].X
+			faceCentroid.Y += vertices[idx].Y
+			faceCentroid.Z += vertices[idx].Z
 		}
+		n := float64(len(face))
+		outward := Vector3{faceCentroid.X/n - centroid.X, faceCentroid.Y/n - centroid.Y, faceCentroid.Z/n - centroid.Z}
 
-		switch parts[0] {
-		case "v":
-			if len(parts) >= 4 {
+		normal := gv.GetFaceNormal(vertices, face)
+		if normal.X*outward.X+normal.Y*outward.Y+normal.Z*outward.Z < -gv.Tolerance {
+			inverted = append(inverted, i)
+		}
+	}
+	return inverted
+}
+
+// UVWarning describes a texture coordinate outside the [0,1] range
+type UVWarning struct {
+	FaceIndex   int
+	VertexIndex int // Corner position within the face
+	U, V        float64
+}
+
+// ValidateUVCoordinates reports every texture coordinate outside [0,1].
+// uvs holds one entry per face with the corner coordinates interleaved as
+// u0, v0, u1, v1, ...
+func (gv *GeometryValidator) ValidateUVCoordinates(uvs [][]float64) []UVWarning {
+	var warnings []UVWarning
+	for faceIdx, faceUVs := range uvs {
+		for i := 0; i+1 < len(faceUVs); i += 2 {
+			u, v := faceUVs[i], faceUVs[i+1]
+			if u < 0 || u > 1 || v < 0 || v > 1 {
+				warnings = append(warnings, UVWarning{faceIdx, i / 2, u, v})
+			}
+		}
+	}
+	return warnings
+}
+
+// ClassifyByNormalClusters groups faces by normal direction using spherical
+// k-means: normals are points on the unit sphere, faces join the centre with
+// the largest dot product and centres are renormalised after each update.
+// Centres are seeded deterministically by farthest-point selection. The
+// result maps each cluster label to the indices of its faces.
+func (gv *GeometryValidator) ClassifyByNormalClusters(vertices []Vector3, faces []Face, k int) map[int][]int {
+	clusters, _ := gv.normalClusters(vertices, faces, k)
+	return clusters
+}
+
+// normalClusters runs the clustering of ClassifyByNormalClusters and also
+// returns the unit normal at the centre of each cluster
+func (gv *GeometryValidator) normalClusters(vertices []Vector3, faces []Face, k int) (map[int][]int, []Vector3) {
+	if len(faces) == 0 || k < 1 {
+		return map[int][]int{}, nil
+	}
+	if k > len(faces) {
+		k = len(faces)
+	}
+
+	normals := make([]Vector3, len(faces))
+	for i, face := range faces {
+		normals[i] = gv.GetFaceNormal(vertices, face)
+	}
+	dot := func(a, b Vector3) float64 { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+
+	// Seed with the first normal, then repeatedly with the normal least
+	// similar to every centre chosen so far
+	centres := []Vector3{normals[0]}
+	for len(centres) < k {
+		farthest, lowest := 0, math.Inf(1)
+		for i, n := range normals {
+			best := math.Inf(-1)
+			for _, c := range centres {
+				best = math.Max(best, dot(n, c))
+			}
+			if best < lowest {
+				farthest, lowest = i, best
+			}
+		}
+		centres = append(centres, normals[farthest])
+	}
+
+	labels := make([]int, len(faces))
+	for iteration := 0; iteration < 100; iteration++ {
+		changed := iteration == 0
+		for i, n := range normals {
+			label, best := 0, math.Inf(-1)
+			for c, centre := range centres {
+				if d := dot(n, centre); d > best {
+					label, best = c, d
+				}
+			}
+			if labels[i] != label {
+				labels[i] = label
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([]Vector3, k)
+		for i, n := range normals {
+			sums[labels[i]] = Vector3{sums[labels[i]].X + n.X, sums[labels[i]].Y + n.Y, sums[labels[i]].Z + n.Z}
+		}
+		for c, sum := range sums {
+			// Empty clusters and clusters of opposing normals keep their centre
+			if length := math.Sqrt(dot(sum, sum)); length > 0 {
+				centres[c] = Vector3{sum.X / length, sum.Y / length, sum.Z / length}
+			}
+		}
+	}
+
+	clusters := make(map[int][]int)
+	for i, label := range labels {
+		clusters[label] = append(clusters[label], i)
+	}
+	return clusters, centres
+}
+
+// normalMaterial returns the material whose canonical normal is closest to
+// normal: up for Roof, horizontal for Wall and down for Ground
+func normalMaterial(normal Vector3) string {
+	z := math.Max(-1, math.Min(1, normal.Z))
+	angles := map[string]float64{
+		"Roof":   math.Acos(z),
+		"Wall":   math.Asin(math.Abs(z)),
+		"Ground": math.Acos(-z),
+	}
+
+	material := "Wall"
+	for _, candidate := range BuiltInMaterials {
+		if angles[candidate] < angles[material] {
+			material = candidate
+		}
+	}
+	return material
+}
+
+// ComputeDihedralAngle returns the angle in degrees between the planes of two
+// adjacent faces. Coplanar faces with consistent winding yield 0. It backs the
+// --dump-dihedral-angles debug output.
+func (gv *GeometryValidator) ComputeDihedralAngle(vertices []Vector3, face1, face2 Face) float64 {
+	n1 := gv.GetFaceNormal(vertices, face1)
+	n2 := gv.GetFaceNormal(vertices, face2)
+
+	dot := n1.X*n2.X + n1.Y*n2.Y + n1.Z*n2.Z
+	dot = math.Max(-1, math.Min(1, dot))
+
+	return math.Acos(dot) * 180 / math.Pi
+}
+
+// Statistics holds processing statistics
+type Statistics struct {
+	ProcessedFiles        int
+	FailedFiles           []FailedFile
+	ClassificationChanges int
+	SplitFiles            map[string]int            // Track split files per material
+	VertexOptimization    map[string]VertexStats    // Track vertex optimization per material
+	FilesIntegrityErrors  int                       // Expected split files missing on disk
+	DefaultMaterial       int                       // Faces that matched no rule and got the default material
+	OutsideOutlines       int                       // Faces classified as Ground for lying outside every outline (UseOutlines)
+	SharedWalls           int                       // Wall faces shared with an adjacent building
+	SharedWallPairs       int                       // Building pairs with at least one shared wall
+	DuplicateFaces        int                       // Near-duplicate face pairs between different buildings
+	InvertedFaces         int                       // Faces whose normal points toward the mesh centroid
+	EmptyMaterialCounts   map[string]int            // Input files that produced no faces per material
+	OutOfRangeUVs         int                       // Face corners with texture coordinates outside [0,1]
+	ClampedVertices       int                       // Vertices whose Z was clamped to ZClampMin/ZClampMax
+	PreservedMaterials    int                       // Faces that kept their usemtl material from the input OBJ
+	DroppedFaces          int                       // Faces classified as a material without a color, left out of every split file
+	CapFaces              map[string]int            // Cap faces added per material by CapOpenEdges
+	BuildingVolumes       map[string]float64        // Mesh volume per input file (with --write-volume)
+	RoofPitches           map[string]float64        // Area-weighted roof pitch in degrees per input file
+	Manifold              map[string]ManifoldStatus // Manifold status per input file (with --validate-manifold)
+	BudgetDroppedFaces    int                       // Faces removed to fit the building that exhausted TotalFaceBudget
+	BudgetSkippedFiles    int                       // Input files not processed because TotalFaceBudget was exhausted
+	SkippedFiles          int                       // Input files not processed because their split files were up to date (SkipExisting)
+	Elapsed               time.Duration             // Processing time accumulated so far
+}
+
+// newStatistics returns empty Statistics with all maps allocated
+func newStatistics() Statistics {
+	return Statistics{
+		SplitFiles:          make(map[string]int),
+		VertexOptimization:  make(map[string]VertexStats),
+		BuildingVolumes:     make(map[string]float64),
+		RoofPitches:         make(map[string]float64),
+		Manifold:            make(map[string]ManifoldStatus),
+		EmptyMaterialCounts: make(map[string]int),
+		CapFaces:            make(map[string]int),
+	}
+}
+
+// Reset zeroes every statistic
+func (s *Statistics) Reset() {
+	*s = newStatistics()
+}
+
+// add accumulates other into s. Per-material vertex optimization statistics
+// of other replace those in s, matching how they are recorded per file.
+func (s *Statistics) add(other Statistics) {
+	s.ProcessedFiles += other.ProcessedFiles
+	s.FailedFiles = append(s.FailedFiles, other.FailedFiles...)
+	s.ClassificationChanges += other.ClassificationChanges
+	s.FilesIntegrityErrors += other.FilesIntegrityErrors
+	s.DefaultMaterial += other.DefaultMaterial
+	s.OutsideOutlines += other.OutsideOutlines
+	s.SharedWalls += other.SharedWalls
+	s.SharedWallPairs += other.SharedWallPairs
+	s.DuplicateFaces += other.DuplicateFaces
+	s.InvertedFaces += other.InvertedFaces
+	s.OutOfRangeUVs += other.OutOfRangeUVs
+	s.ClampedVertices += other.ClampedVertices
+	s.PreservedMaterials += other.PreservedMaterials
+	s.DroppedFaces += other.DroppedFaces
+	s.BudgetDroppedFaces += other.BudgetDroppedFaces
+	s.BudgetSkippedFiles += other.BudgetSkippedFiles
+	s.SkippedFiles += other.SkippedFiles
+	for material, count := range other.SplitFiles {
+		s.SplitFiles[material] += count
+	}
+	for material, count := range other.EmptyMaterialCounts {
+		s.EmptyMaterialCounts[material] += count
+	}
+	for material, count := range other.CapFaces {
+		s.CapFaces[material] += count
+	}
+	for material, stats := range other.VertexOptimization {
+		s.VertexOptimization[material] = stats
+	}
+	for name, volume := range other.BuildingVolumes {
+		s.BuildingVolumes[name] = volume
+	}
+	for name, pitch := range other.RoofPitches {
+		s.RoofPitches[name] = pitch
+	}
+	for name, status := range other.Manifold {
+		s.Manifold[name] = status
+	}
+}
+
+// statisticsJSON is the JSON representation of Statistics, which is also
+// what --stats-output writes. Keys are snake_case and every field is always
+// present so the schema stays stable between runs.
+type statisticsJSON struct {
+	Duration              float64                   `json:"duration"` // Seconds, as printed by PrintSummary
+	ProcessedFiles        int                       `json:"processed_files"`
+	FailedFiles           []FailedFile              `json:"failed_files"`
+	ClassificationChanges int                       `json:"classification_changes"`
+	SplitFiles            map[string]int            `json:"split_files"`
+	VertexOptimization    map[string]VertexStats    `json:"vertex_optimization"`
+	FilesIntegrityErrors  int                       `json:"files_integrity_errors"`
+	DefaultMaterial       int                       `json:"default_material"`
+	OutsideOutlines       int                       `json:"outside_outlines"`
+	SharedWalls           int                       `json:"shared_walls"`
+	SharedWallPairs       int                       `json:"shared_wall_pairs"`
+	DuplicateFaces        int                       `json:"duplicate_faces"`
+	InvertedFaces         int                       `json:"inverted_faces"`
+	EmptyMaterialCounts   map[string]int            `json:"empty_material_counts"`
+	OutOfRangeUVs         int                       `json:"out_of_range_uvs"`
+	ClampedVertices       int                       `json:"clamped_vertices"`
+	PreservedMaterials    int                       `json:"preserved_materials"`
+	DroppedFaces          int                       `json:"dropped_faces"`
+	CapFaces              map[string]int            `json:"cap_faces"`
+	BuildingVolumes       map[string]float64        `json:"building_volumes"`
+	RoofPitches           map[string]float64        `json:"roof_pitches"`
+	Manifold              map[string]ManifoldStatus `json:"manifold"`
+	BudgetDroppedFaces    int                       `json:"budget_dropped_faces"`
+	BudgetSkippedFiles    int                       `json:"budget_skipped_files"`
+	SkippedFiles          int                       `json:"skipped_files"`
+	ElapsedNanos          int64                     `json:"elapsed_nanos"` // Exact Elapsed, restored by UnmarshalJSON
+}
+
+// MarshalJSON encodes Statistics with the duration both in seconds and as
+// nanosecond integers. Nil slices and maps are written as empty arrays and
+// objects.
+func (s Statistics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statisticsJSON{
+		Duration:              s.Elapsed.Seconds(),
+		ProcessedFiles:        s.ProcessedFiles,
+		FailedFiles:           append([]FailedFile{}, s.FailedFiles...),
+		ClassificationChanges: s.ClassificationChanges,
+		SplitFiles:            nonNilMap(s.SplitFiles),
+		VertexOptimization:    nonNilMap(s.VertexOptimization),
+		FilesIntegrityErrors:  s.FilesIntegrityErrors,
+		DefaultMaterial:       s.DefaultMaterial,
+		OutsideOutlines:       s.OutsideOutlines,
+		SharedWalls:           s.SharedWalls,
+		SharedWallPairs:       s.SharedWallPairs,
+		DuplicateFaces:        s.DuplicateFaces,
+		InvertedFaces:         s.InvertedFaces,
+		EmptyMaterialCounts:   nonNilMap(s.EmptyMaterialCounts),
+		OutOfRangeUVs:         s.OutOfRangeUVs,
+		ClampedVertices:       s.ClampedVertices,
+		PreservedMaterials:    s.PreservedMaterials,
+		DroppedFaces:          s.DroppedFaces,
+		CapFaces:              nonNilMap(s.CapFaces),
+		BuildingVolumes:       nonNilMap(s.BuildingVolumes),
+		RoofPitches:           nonNilMap(s.RoofPitches),
+		Manifold:              nonNilMap(s.Manifold),
+		BudgetDroppedFaces:    s.BudgetDroppedFaces,
+		BudgetSkippedFiles:    s.BudgetSkippedFiles,
+		SkippedFiles:          s.SkippedFiles,
+		ElapsedNanos:          int64(s.Elapsed),
+	})
+}
+
+// nonNilMap returns m, or an empty map if m is nil
+func nonNilMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return make(map[K]V)
+	}
+	return m
+}
+
+// UnmarshalJSON decodes Statistics previously written by MarshalJSON
+func (s *Statistics) UnmarshalJSON(data []byte) error {
+	var aux statisticsJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	s.ProcessedFiles = aux.ProcessedFiles
+	s.FailedFiles = aux.FailedFiles
+	s.ClassificationChanges = aux.ClassificationChanges
+	s.SplitFiles = aux.SplitFiles
+	if s.SplitFiles == nil {
+		s.SplitFiles = make(map[string]int)
+	}
+	s.VertexOptimization = aux.VertexOptimization
+	if s.VertexOptimization == nil {
+		s.VertexOptimization = make(map[string]VertexStats)
+	}
+	s.FilesIntegrityErrors = aux.FilesIntegrityErrors
+	s.DefaultMaterial = aux.DefaultMaterial
+	s.OutsideOutlines = aux.OutsideOutlines
+	s.SharedWalls = aux.SharedWalls
+	s.SharedWallPairs = aux.SharedWallPairs
+	s.DuplicateFaces = aux.DuplicateFaces
+	s.InvertedFaces = aux.InvertedFaces
+	s.EmptyMaterialCounts = aux.EmptyMaterialCounts
+	s.OutOfRangeUVs = aux.OutOfRangeUVs
+	s.ClampedVertices = aux.ClampedVertices
+	s.PreservedMaterials = aux.PreservedMaterials
+	s.DroppedFaces = aux.DroppedFaces
+	s.CapFaces = aux.CapFaces
+	if s.CapFaces == nil {
+		s.CapFaces = make(map[string]int)
+	}
+	if s.EmptyMaterialCounts == nil {
+		s.EmptyMaterialCounts = make(map[string]int)
+	}
+	s.BuildingVolumes = aux.BuildingVolumes
+	if s.BuildingVolumes == nil {
+		s.BuildingVolumes = make(map[string]float64)
+	}
+	s.RoofPitches = aux.RoofPitches
+	if s.RoofPitches == nil {
+		s.RoofPitches = make(map[string]float64)
+	}
+	s.Manifold = aux.Manifold
+	if s.Manifold == nil {
+		s.Manifold = make(map[string]ManifoldStatus)
+	}
+	s.BudgetDroppedFaces = aux.BudgetDroppedFaces
+	s.BudgetSkippedFiles = aux.BudgetSkippedFiles
+	s.SkippedFiles = aux.SkippedFiles
+	s.Elapsed = time.Duration(aux.ElapsedNanos)
+	return nil
+}
+
+// ManifoldStatus is the ValidateManifold result for one input file
+type ManifoldStatus struct {
+	IsManifold       bool `json:"is_manifold"`
+	OpenEdges        int  `json:"open_edges"`
+	NonManifoldEdges int  `json:"non_manifold_edges"`
+}
+
+// VertexStats tracks vertex optimization statistics
+type VertexStats struct {
+	OriginalVertices   int
+	OptimizedVertices  int
+	ReductionPercent   float64
+	HausdorffDistance  float64 // Directed Hausdorff distance from the simplified to the original group (with --compute-hausdorff)
+	SymmetricHausdorff float64 // Symmetric Hausdorff distance between the original and simplified group (with --compute-hausdorff)
+}
+
+// vertexStatsJSON is the JSON representation of VertexStats.
+// Non-finite percentages are encoded as null.
+type vertexStatsJSON struct {
+	OriginalVertices   int      `json:"original_vertices"`
+	OptimizedVertices  int      `json:"optimized_vertices"`
+	ReductionPercent   *float64 `json:"reduction_percent"`
+	HausdorffDistance  float64  `json:"hausdorff_distance"`
+	SymmetricHausdorff float64  `json:"symmetric_hausdorff_distance"`
+}
+
+// MarshalJSON encodes VertexStats, writing non-finite values as null
+func (vs VertexStats) MarshalJSON() ([]byte, error) {
+	aux := vertexStatsJSON{
+		OriginalVertices:   vs.OriginalVertices,
+		OptimizedVertices:  vs.OptimizedVertices,
+		HausdorffDistance:  vs.HausdorffDistance,
+		SymmetricHausdorff: vs.SymmetricHausdorff,
+	}
+	if !math.IsInf(vs.ReductionPercent, 0) && !math.IsNaN(vs.ReductionPercent) {
+		aux.ReductionPercent = &vs.ReductionPercent
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON decodes VertexStats, treating a null percentage as zero
+func (vs *VertexStats) UnmarshalJSON(data []byte) error {
+	var aux vertexStatsJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	vs.OriginalVertices = aux.OriginalVertices
+	vs.OptimizedVertices = aux.OptimizedVertices
+	vs.HausdorffDistance = aux.HausdorffDistance
+	vs.SymmetricHausdorff = aux.SymmetricHausdorff
+	vs.ReductionPercent = 0
+	if aux.ReductionPercent != nil {
+		vs.ReductionPercent = *aux.ReductionPercent
+	}
+	return nil
+}
+
+// BuildingResult is the outcome of processing one input file with ProcessDirectory
+type BuildingResult struct {
+	InputPath   string
+	OutputPaths []string   // Split files written for the input
+	Stats       Statistics // Statistics of this input file only
+	Err         error      // Non-nil if the input failed to process
+}
+
+// PlannedFile is a split file that a DryRun would have written
+type PlannedFile struct {
+	Path           string
+	Vertices       int
+	Faces          int
+	EstimatedBytes int // Estimated size, see estimateSplitFileSize
+}
+
+// ManifestEntry lists the split files written for one input file, with the
+// building volume (WriteVolume only) and roof pitch (roofed buildings only)
+type ManifestEntry struct {
+	Source    string           `json:"source"`
+	Volume    *float64         `json:"volume,omitempty"`
+	RoofPitch *float64         `json:"roof_pitch,omitempty"`
+	Outputs   []ManifestOutput `json:"outputs"`
+}
+
+// ManifestOutput is one split file of a ManifestEntry
+type ManifestOutput struct {
+	Path     string `json:"path"`
+	Material string `json:"material"`
+	Vertices int    `json:"vertices"`
+	Faces    int    `json:"faces"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// WorkerUsage records the work done by one ProcessAllBuildings worker
+type WorkerUsage struct {
+	Files int           // Input files processed
+	Busy  time.Duration // Time spent processing them
+}
+
+// FailedFile represents a failed file with error message
+type FailedFile struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// Ground detection methods
+const (
+	GroundDetectionHistogram = "histogram" // Lowest significant peak of the Z histogram
+	GroundDetectionRANSAC    = "ransac"    // Horizontal plane with the most inliers
+)
+
+// Split file formats
+const (
+	OutputFormatOBJ  = "obj"  // OBJ file with a companion MTL file per material group
+	OutputFormatGLTF = "gltf" // Self-contained binary glTF 2.0 (.glb) file per material group
+)
+
+// Repair types recorded in the repair log
+const (
+	RepairFaceRemoval = "face_removal" // Face record dropped at load time
+	RepairWindingFlip = "winding_flip" // Face winding reversed by FixOrientation
+	RepairZClamp      = "z_clamp"      // Vertex Z limited to [ZClampMin, ZClampMax]
+	RepairUVClamp     = "uv_clamp"     // Texture coordinate clamped to [0,1] by ClampUVs
+)
+
+// RepairLogEntry records one corrective action applied to an input mesh.
+// FaceIndex and VertexIndex are -1 when they do not apply. For removed faces
+// FaceIndex is the position of the face record in the input file.
+type RepairLogEntry struct {
+	Building    string `xml:"building,attr"`
+	Type        string `xml:"type,attr"`
+	FaceIndex   int    `xml:"faceIndex,attr"`
+	VertexIndex int    `xml:"vertexIndex,attr"`
+	Before      string `xml:"before"`
+	After       string `xml:"after"`
+}
+
+// BuildingColorizer main class
+type BuildingColorizer struct {
+	ObjDir                 string
+	OutputDir              string
+	GeoJSONPath            string
+	BuildingOutlines       map[string]Polygon
+	MeshAnalyzer           *MeshAnalyzer
+	GeometryValidator      *GeometryValidator
+	ClassificationCache    map[int]string
+	Stats                  Statistics
+	Colors                 map[string]Color // Color per material; every key is a classification target
+	StartTime              time.Time
+	Logger                 *Logger
+	PrefixMaterialName     bool                 // Prefix material names with the input file's base name
+	AxisPermutation        axes.Permutation     // Output axis order applied at write time (identity by default)
+	DumpDihedralAngles     bool                 // Print the dihedral angle of every shared edge
+	WriteVolume            bool                 // Compute and record the mesh volume of each building
+	FaceSort               string               // Face order within each group: area-asc, area-desc, index or none
+	DefaultMaterial        string               // Material for faces that match no classification rule
+	MarkSharedWalls        bool                 // Write shared wall faces to a separate *-shared split file
+	CapOpenEdges           bool                 // Close the open boundary of each face group with cap faces
+	SharedWallEpsilon      float64              // Plane distance tolerance for shared wall detection
+	WallNormalThreshold    float64              // Faces whose normal has |Z| below this are walls
+	ClassificationFunc     ClassificationFunc   // Optional user-defined face classification, replaces the built-in rules
+	ExportFaceAttrs        bool                 // Record per-face material, centroid and normal for ExportFaceAttributes
+	ExportHeightFeats      bool                 // Record per-vertex normalised height above ground for ExportHeightFeatures
+	KeepUVIslands          bool                 // Keep vertices with distinct texture coordinates separate and write vt data
+	SummaryOnly            bool                 // Run all processing steps but write no output files
+	DryRun                 bool                 // With SummaryOnly, record the split files that would be written
+	ObjUnits               string               // Input OBJ units, scaled to metres at load time
+	ComputeHausdorff       bool                 // Record the Hausdorff distance of each optimized group in VertexStats
+	FixOrientation         bool                 // Reverse the winding of faces that point toward the mesh centroid
+	ValidateManifold       bool                 // Check each mesh for open and non-manifold edges before processing
+	StrictManifold         bool                 // Fail files with non-manifold edges instead of warning (requires ValidateManifold)
+	OutputHierarchy        bool                 // Write split files into Roof/, Wall/ and Ground/ subdirectories
+	WarnOnEmptyMaterial    bool                 // Warn for every material group that received no faces
+	ClampUVs               bool                 // Clamp texture coordinates outside [0,1] (requires KeepUVIslands)
+	ZClampMin              float64              // Vertex Z values below this are raised to it (-Inf disables)
+	ZClampMax              float64              // Vertex Z values above this are lowered to it (+Inf disables)
+	GroundFromGeoJSON      bool                 // Use the Z of the containing 3D outline as ground height
+	UseOutlines            bool                 // Classify faces whose centroid lies outside every outline as Ground
+	DetectDuplicates       bool                 // Report near-duplicate faces between different input files
+	DuplicateDistance      float64              // Maximum centroid distance of near-duplicate faces
+	HistogramPathTemplate  string               // Z histogram CSV path per building; {base} is the file basename
+	KMeansMaterials        int                  // Classify faces by k-means clustering of normals into this many clusters (0 disables)
+	Obfuscation            *CoordinateTransform // Applied to output vertices before AxisPermutation and to exported positions, nil to disable
+	RepairLogPath          string               // Write every automated mesh repair to this XML file (empty disables)
+	ObjLinePool            *sync.Pool           // Provides *[]byte buffers for assembling OBJ vertex and face lines
+	PreserveInputMaterials bool                 // Keep usemtl assignments that name a known material instead of classifying
+	MaterialAssignment     []string             // usemtl material active for each face of the last loaded file ("" if none)
+	SplitRoofPlanes        bool                 // Write each roof plane found by ClusterRoofPlanes as a separate OBJ group
+	OrientWalls            bool                 // Write one wall file per compass orientation (-wall-n, -wall-ne, ...)
+	RoofClusterAngle       float64              // Largest angle in degrees between normals of faces in one roof plane
+	Workers                int                  // Number of goroutines processing OBJ files in ProcessAllBuildings
+	AutoTuneTolerances     bool                 // Set GeometryValidator.Tolerance per building to half its estimated point spacing
+	OutputFormat           string               // Split file format: OutputFormatOBJ or OutputFormatGLTF
+	GroundDetection        string               // Ground height method: GroundDetectionHistogram or GroundDetectionRANSAC
+	RANSACIterations       int                  // Planes sampled by GroundDetectionRANSAC
+	EmitEmptyGroups        bool                 // Write a header-only OBJ file and its MTL file for material groups without faces
+	NormaliseFaceIndices   bool                 // Write 0-based face indices (non-standard OBJ) for tools that expect them
+	TotalFaceBudget        int                  // Stop once the split files of all buildings hold this many faces (0 disables)
+	SkipExisting           bool                 // Skip input files whose split files recorded by the last run all exist and are newer than the input
+	ManifestPath           string               // Write the split files created per input file to this JSON file (empty disables)
+
+	wallGroups         map[string]*OptimizedFaceGroup // Wall group per processed building, for shared wall detection (MarkSharedWalls only)
+	faceAttrs          []FaceAttribute                // Recorded face attributes, in processing order
+	heightFeatures     []HeightFeature                // Recorded vertex height features, in processing order
+	meshes             map[string]buildingMesh        // Loaded mesh per processed building (DetectDuplicates only)
+	texCoords          []TexCoord                     // Texture coordinate per vertex of the last loaded file (KeepUVIslands only)
+	faceVertices       [][]FaceVertex                 // Corners of each face of the last loaded file
+	texCoordLines      []string                       // vt lines of the last loaded file, written back unchanged
+	normalLines        []string                       // vn lines of the last loaded file, written back with Obfuscation and AxisPermutation applied
+	repairLog          []RepairLogEntry               // Recorded mesh repairs, in processing order (RepairLogPath only)
+	plannedFiles       []PlannedFile                  // Split files not written by DryRun, in processing order
+	manifest           []ManifestEntry                // Split files written per input file, in processing order (ManifestPath only)
+	workerUsage        []WorkerUsage                  // Files and busy time per worker of the last ProcessAllBuildings run
+	poolElapsed        time.Duration                  // Wall time of the worker pool in the last ProcessAllBuildings run
+	budgetFaces        int                            // Faces counted against TotalFaceBudget so far
+	budgetExhausted    bool                           // A building was simplified to fit TotalFaceBudget; no further files are processed
+	interruptedFiles   int                            // Input files not processed because the run was cancelled
+	ExpectedSplitFiles map[string][]string            // Split file names expected per processed input file
+	previousSplitFiles map[string][]string            // Split file names per input file name from the last run's split index (SkipExisting)
+	splitIndexTime     time.Time                      // Modification time of the loaded split index
+}
+
+// buildingMesh holds the loaded vertices and faces of one input file
+type buildingMesh struct {
+	Vertices []Vector3
+	Faces    []Face
+}
+
+// ClassificationFunc classifies a face and returns its material name, which must
+// be a key of Colors; faces with any other name are dropped. normal is the face
+// normal already computed by the colorizer.
+type ClassificationFunc func(vertices []Vector3, face Face, groundHeight float64, normal Vector3) string
+
+// NewBuildingColorizer creates a new BuildingColorizer that logs messages at or above logLevel.
+// groundTolerance is the GeometryValidator tolerance and wallThreshold the WallNormalThreshold.
+func NewBuildingColorizer(objDir, outputDir, geoJSONPath, colorsPath string, logLevel LogLevel, groundTolerance, wallThreshold float64) *BuildingColorizer {
+	bc := &BuildingColorizer{
+		ObjDir:              objDir,
+		OutputDir:           outputDir,
+		GeoJSONPath:         geoJSONPath,
+		MeshAnalyzer:        NewMeshAnalyzer(),
+		GeometryValidator:   NewGeometryValidator(groundTolerance),
+		ClassificationCache: make(map[int]string),
+		ExpectedSplitFiles:  make(map[string][]string),
+		StartTime:           time.Now(),
+		Logger:              &Logger{Level: logLevel},
+		AxisPermutation:     axes.Identity,
+		ObjUnits:            "m",
+		FaceSort:            "none",
+		DefaultMaterial:     "Roof",
+		SharedWallEpsilon:   0.05,
+		WallNormalThreshold: wallThreshold,
+		ZClampMin:           math.Inf(-1),
+		ZClampMax:           math.Inf(1),
+		DuplicateDistance:   0.1,
+		RoofClusterAngle:    10,
+		Workers:             runtime.NumCPU(),
+		OutputFormat:        OutputFormatOBJ,
+		GroundDetection:     GroundDetectionHistogram,
+		RANSACIterations:    1000,
+		ObjLinePool: &sync.Pool{New: func() interface{} {
+			line := make([]byte, 0, 128)
+			return &line
+		}},
+		wallGroups: make(map[string]*OptimizedFaceGroup),
+		meshes:     make(map[string]buildingMesh),
+		Stats:      newStatistics(),
+	}
+
+	bc.BuildingOutlines = bc.loadAllBuildingOutlines()
+	bc.Colors = Colors
+	if colorsPath != "" {
+		bc.Colors = bc.loadColors(colorsPath)
+	}
+	return bc
+}
+
+// loadColors reads a JSON file mapping material names to {r, g, b, a}
+// colors with components in [0,1]; a defaults to 1. A missing or malformed
+// file yields the built-in Colors with a warning.
+func (bc *BuildingColorizer) loadColors(colorsPath string) map[string]Color {
+	data, err := ioutil.ReadFile(colorsPath)
+	if err != nil {
+		bc.Logger.Log(LogWarn, "Warning: %v; using built-in colors\n", err)
+		return Colors
+	}
+
+	var entries map[string]struct {
+		R, G, B float64
+		A       *float64
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		bc.Logger.Log(LogWarn, "Warning: Invalid colors file %s: %v; using built-in colors\n", colorsPath, err)
+		return Colors
+	}
+	if len(entries) == 0 {
+		bc.Logger.Log(LogWarn, "Warning: Colors file %s defines no materials; using built-in colors\n", colorsPath)
+		return Colors
+	}
+
+	colors := make(map[string]Color, len(entries))
+	for material, entry := range entries {
+		color := Color{R: entry.R, G: entry.G, B: entry.B, A: 1}
+		if entry.A != nil {
+			color.A = *entry.A
+		}
+		for _, component := range []float64{color.R, color.G, color.B, color.A} {
+			if material == "" || component < 0 || component > 1 {
+				bc.Logger.Log(LogWarn, "Warning: Invalid color for material '%s' in %s; using built-in colors\n", material, colorsPath)
+				return Colors
+			}
+		}
+		colors[material] = color
+	}
+
+	// Faces of a built-in material without a color would be dropped
+	for _, material := range BuiltInMaterials {
+		if _, ok := colors[material]; !ok {
+			bc.Logger.Log(LogWarn, "Warning: Colors file %s has no %s color; using the built-in one\n", colorsPath, material)
+			colors[material] = Colors[material]
+		}
+	}
+
+	bc.Logger.Log(LogInfo, "Loaded %d material colors from %s\n", len(colors), colorsPath)
+	return colors
+}
+
+// LoadObjFile loads vertices and faces from OBJ file. labels holds, for each
+// face, the name of the last g or o declaration before it ("" if none).
+func (bc *BuildingColorizer) LoadObjFile(objPath string) (vertices []Vector3, faces []Face, labels []string, err error) {
+	file, err := os.Open(objPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer file.Close()
+
+	return bc.LoadObjFromReader(file, filepath.Base(objPath))
+}
+
+// LoadObjFromReader loads vertices, faces and face labels (see LoadObjFile)
+// from OBJ data read from r. name is only used in warning messages.
+func (bc *BuildingColorizer) LoadObjFromReader(r io.Reader, name string) ([]Vector3, []Face, []string, error) {
+	var vertices []Vector3
+	var faces []Face
+	var labels []string
+
+	// With KeepUVIslands every distinct (v, vt) pair becomes its own vertex
+	var uvs [][2]float64
+	var splitVertices []Vector3
+	uvCorners := make(map[[2]int]int)
+	bc.texCoords = nil
+	bc.faceVertices = nil
+	bc.texCoordLines = nil
+	bc.normalLines = nil
+	bc.MaterialAssignment = nil
+	material := ""
+	label := ""
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	faceRecords := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+
+		switch parts[0] {
+		case "v":
+			if len(parts) >= 4 {
 				x, err1 := strconv.ParseFloat(parts[1], 64)
 				y, err2 := strconv.ParseFloat(parts[2], 64)
 				z, err3 := strconv.ParseFloat(parts[3], 64)
 				if err1 == nil && err2 == nil && err3 == nil {
-					vertices = append(vertices, Vector3{x, y, z})
+					scale := units.Scales[bc.ObjUnits]
+					vertices = append(vertices, Vector3{x * scale, y * scale, z * scale})
 				} else {
-					if bc.Debug {
-						fmt.Printf("Warning: Invalid vertex at line %d in %s: %s\n", lineNum, filepath.Base(objPath), line)
-					}
+					bc.Logger.Log(LogDebug, "Warning: Invalid vertex at line %d in %s: %s\n", lineNum, name, line)
 				}
 			}
+		case "vt":
+			bc.texCoordLines = append(bc.texCoordLines, line)
+			if bc.KeepUVIslands && len(parts) >= 3 {
+				u, err1 := strconv.ParseFloat(parts[1], 64)
+				v, err2 := strconv.ParseFloat(parts[2], 64)
+				if err1 != nil || err2 != nil {
+					u, v = 0, 0
+					bc.Logger.Log(LogDebug, "Warning: Invalid texture coordinate at line %d in %s: %s\n", lineNum, name, line)
+				}
+				// Keep invalid entries so later vt indices stay aligned
+				uvs = append(uvs, [2]float64{u, v})
+			}
+		case "vn":
+			bc.normalLines = append(bc.normalLines, line)
+		case "usemtl":
+			material = strings.TrimSpace(strings.TrimPrefix(line, "usemtl"))
+		case "g", "o":
+			label = strings.Join(parts[1:], " ")
 		case "f":
-			if len(parts) >= 4 {
+			faceRecords++
+			validFace := len(parts) >= 4
+			if validFace {
 				var face Face
-				validFace := true
+				var corners []FaceVertex
 				for i := 1; i < len(parts); i++ {
 					// Handle different face formats (v, v/vt, v/vt/vn)
-					vertexStr := strings.Split(parts[i], "/")[0]
+					indices := strings.Split(parts[i], "/")
+					vertexStr := indices[0]
 					if vertexIdx, err := strconv.Atoi(vertexStr); err == nil {
 						idx := vertexIdx - 1 // OBJ indices start at 1
 						if idx >= 0 && idx < len(vertices) {
+							corners = append(corners, FaceVertex{idx, objAttributeIndex(indices, 1, len(bc.texCoordLines)), objAttributeIndex(indices, 2, len(bc.normalLines))})
+							if bc.KeepUVIslands {
+								idx = bc.splitVertexByUV(idx, indices, vertices, uvs, uvCorners, &splitVertices)
+							}
 							face = append(face, idx)
 						} else {
 							validFace = false
-							if bc.Debug {
-								fmt.Printf("Warning: Invalid vertex index %d at line %d in %s\n", vertexIdx, lineNum, filepath.Base(objPath))
-							}
+							bc.Logger.Log(LogDebug, "Warning: Invalid vertex index %d at line %d in %s\n", vertexIdx, lineNum, name)
 							break
 						}
 					} else {
@@ -311,351 +1645,2814 @@ func (bc *BuildingColorizer) LoadObjFile(objPath string) ([]Vector3, []Face, err
 				}
 				if validFace && len(face) >= 3 {
 					faces = append(faces, face)
+					bc.faceVertices = append(bc.faceVertices, corners)
+					labels = append(labels, label)
+					bc.MaterialAssignment = append(bc.MaterialAssignment, material)
+				}
+			}
+			if !validFace {
+				bc.recordRepair(RepairLogEntry{name, RepairFaceRemoval, faceRecords - 1, -1, strings.Join(parts[1:], " "), ""})
+			}
+		}
+	}
+
+	if bc.KeepUVIslands {
+		vertices = splitVertices
+	}
+
+	if len(vertices) == 0 || len(faces) == 0 {
+		return nil, nil, nil, fmt.Errorf("no valid vertices or faces found")
+	}
+
+	return vertices, faces, labels, nil
+}
+
+// objAttributeIndex returns the 0-based index in component i (1 for vt, 2
+// for vn) of a split face corner "v/vt/vn", or -1 if the component is absent
+// or does not refer to one of the count entries read so far
+func objAttributeIndex(indices []string, i, count int) int {
+	if i >= len(indices) {
+		return -1
+	}
+	idx, err := strconv.Atoi(indices[i])
+	if err != nil || idx < 1 || idx > count {
+		return -1
+	}
+	return idx - 1
+}
+
+// splitVertexByUV returns the index of the vertex for the face corner
+// (idx, vt) in splitVertices, adding a new vertex the first time the pair is
+// seen so that a position used with different UVs stays distinct
+func (bc *BuildingColorizer) splitVertexByUV(idx int, indices []string, vertices []Vector3, uvs [][2]float64, corners map[[2]int]int, splitVertices *[]Vector3) int {
+	uvIdx := -1
+	if len(indices) > 1 {
+		if vt, err := strconv.Atoi(indices[1]); err == nil && vt >= 1 && vt <= len(uvs) {
+			uvIdx = vt - 1
+		}
+	}
+
+	key := [2]int{idx, uvIdx}
+	if corner, exists := corners[key]; exists {
+		return corner
+	}
+
+	corner := len(*splitVertices)
+	corners[key] = corner
+	*splitVertices = append(*splitVertices, vertices[idx])
+
+	texCoord := TexCoord{}
+	if uvIdx >= 0 {
+		texCoord = TexCoord{uvs[uvIdx][0], uvs[uvIdx][1], true}
+	}
+	bc.texCoords = append(bc.texCoords, texCoord)
+
+	return corner
+}
+
+// loadAllBuildingOutlines loads building outlines from GeoJSON
+func (bc *BuildingColorizer) loadAllBuildingOutlines() map[string]Polygon {
+	buildingOutlines := make(map[string]Polygon)
+
+	data, err := ioutil.ReadFile(bc.GeoJSONPath)
+	if err != nil {
+		bc.Logger.Log(LogError, "Error loading GeoJSON: %v\n", err)
+		return buildingOutlines
+	}
+
+	var geoJSON GeoJSON
+	if err := json.Unmarshal(data, &geoJSON); err != nil {
+		bc.Logger.Log(LogError, "Error parsing GeoJSON: %v\n", err)
+		return buildingOutlines
+	}
+
+	for index, feature := range geoJSON.Features {
+		// A Polygon is one part; a MultiPolygon has one part per polygon
+		var parts [][][][]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var rings [][][]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil {
+				bc.Logger.Log(LogWarn, "Skipping GeoJSON feature %d: invalid Polygon coordinates: %v\n", index, err)
+				continue
+			}
+			parts = append(parts, rings)
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &parts); err != nil {
+				bc.Logger.Log(LogWarn, "Skipping GeoJSON feature %d: invalid MultiPolygon coordinates: %v\n", index, err)
+				continue
+			}
+		default:
+			continue
+		}
+
+		id := featureID(feature, index)
+		for p, rings := range parts {
+			if len(rings) == 0 || len(rings[0]) < 3 {
+				continue
+			}
+
+			polygon := Polygon{
+				Polygon: outline.Polygon{Coordinates: rings[0], Holes: rings[1:]},
+				GroundZ: math.Inf(1),
+				MinX:    math.Inf(1),
+				MinY:    math.Inf(1),
+				MaxX:    math.Inf(-1),
+				MaxY:    math.Inf(-1),
+			}
+			for _, coord := range rings[0] {
+				if len(coord) >= 2 {
+					polygon.MinX = math.Min(polygon.MinX, coord[0])
+					polygon.MinY = math.Min(polygon.MinY, coord[1])
+					polygon.MaxX = math.Max(polygon.MaxX, coord[0])
+					polygon.MaxY = math.Max(polygon.MaxY, coord[1])
+				}
+			}
+			for _, ring := range rings {
+				for _, coord := range ring {
+					if len(coord) >= 3 {
+						polygon.GroundZ = math.Min(polygon.GroundZ, coord[2])
+						polygon.HasZ = true
+					}
 				}
 			}
+			if !polygon.HasZ {
+				polygon.GroundZ = 0
+			}
+
+			// Each part of a MultiPolygon is stored as its own outline
+			key := id
+			if len(parts) > 1 {
+				key = fmt.Sprintf("%s_%d", id, p)
+			}
+			if _, exists := buildingOutlines[key]; exists {
+				bc.Logger.Log(LogWarn, "Duplicate GeoJSON outline id %s, storing feature %d as %s_%d\n", key, index, key, index)
+				key = fmt.Sprintf("%s_%d", key, index)
+			}
+			buildingOutlines[key] = polygon
+		}
+	}
+
+	bc.Logger.Log(LogInfo, "Loaded %d valid building outlines\n", len(buildingOutlines))
+	return buildingOutlines
+}
+
+// featureID returns the id of a GeoJSON feature: its "id" member, else its
+// "id" property, else polygon_<index>
+func featureID(feature GeoJSONFeature, index int) string {
+	for _, value := range []interface{}{feature.ID, feature.Properties["id"]} {
+		switch id := value.(type) {
+		case string:
+			if id != "" {
+				return id
+			}
+		case float64:
+			return strconv.FormatFloat(id, 'f', -1, 64)
+		}
+	}
+	return fmt.Sprintf("polygon_%d", index)
+}
+
+// outlineGroundHeight returns the ground Z of the 3D building outline that
+// contains the X/Y centroid of vertices
+func (bc *BuildingColorizer) outlineGroundHeight(vertices []Vector3) (float64, bool) {
+	if len(vertices) == 0 {
+		return 0, false
+	}
+
+	var cx, cy float64
+	for _, v := range vertices {
+		cx += v.X
+		cy += v.Y
+	}
+	cx /= float64(len(vertices))
+	cy /= float64(len(vertices))
+
+	// Sort keys so overlapping outlines resolve the same way on every run
+	keys := make([]string, 0, len(bc.BuildingOutlines))
+	for key := range bc.BuildingOutlines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		polygon := bc.BuildingOutlines[key]
+		if polygon.HasZ && polygon.Contains(cx, cy) {
+			return polygon.GroundZ, true
+		}
+	}
+	return 0, false
+}
+
+// insideAnyOutline reports whether (x, y) lies inside any loaded building outline
+func (bc *BuildingColorizer) insideAnyOutline(x, y float64) bool {
+	for _, polygon := range bc.BuildingOutlines {
+		if x < polygon.MinX || x > polygon.MaxX || y < polygon.MinY || y > polygon.MaxY {
+			continue
+		}
+		if polygon.Contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessMesh processes mesh data and creates optimized face groups.
+// labels are the face labels returned by LoadObjFile, or nil.
+func (bc *BuildingColorizer) ProcessMesh(vertices []Vector3, faces []Face, labels []string) (map[string]*OptimizedFaceGroup, float64) {
+	// Find ground level using distribution analysis
+	zValues := make([]float64, len(vertices))
+	for i, v := range vertices {
+		zValues[i] = v.Z
+	}
+	groundHeight, fromOutline := 0.0, false
+	if bc.GroundFromGeoJSON {
+		groundHeight, fromOutline = bc.outlineGroundHeight(vertices)
+		if !fromOutline {
+			bc.Logger.Log(LogDebug, "  No 3D building outline contains this building, using Z distribution\n")
+		}
+	}
+	if !fromOutline {
+		if bc.GroundDetection == GroundDetectionRANSAC {
+			// Inliers use this building's tolerance, which --auto-tune-tolerances may change
+			analyzer := *bc.MeshAnalyzer
+			analyzer.GroundTolerance = bc.GeometryValidator.Tolerance
+			groundHeight = analyzer.DetectGroundPlaneRANSAC(vertices, faces, bc.RANSACIterations)
+		} else {
+			groundHeight = bc.MeshAnalyzer.AnalyzeZDistribution(zValues)
+		}
+	}
+
+	// Initialize face groups with vertex tracking
+	faceGroups := make(map[string]*OptimizedFaceGroup)
+	for material := range bc.Colors {
+		faceGroups[material] = &OptimizedFaceGroup{
+			Material:      material,
+			Faces:         []Face{},
+			VertexMapping: make(map[int]int),
+		}
+	}
+
+	// Track which vertices are used by each material
+	usedVertices := make(map[string]map[int]bool)
+	for material := range bc.Colors {
+		usedVertices[material] = make(map[int]bool)
+	}
+
+	// With KMeansMaterials, faces take the material of their normal cluster
+	var clusterMaterials []string
+	if bc.KMeansMaterials > 0 {
+		clusterMaterials = bc.clusterFaceMaterials(vertices, faces)
+	}
+
+	// Corner vt/vn references follow the faces into their groups
+	var faceCorners [][]FaceVertex
+	if len(bc.faceVertices) == len(faces) {
+		faceCorners = bc.faceVertices
+	}
+
+	// With PreserveInputMaterials, faces keep a known usemtl material
+	var inputMaterials []string
+	if bc.PreserveInputMaterials && len(bc.MaterialAssignment) == len(faces) {
+		inputMaterials = bc.MaterialAssignment
+	}
+
+	var attrIndices map[string]uint8
+	if bc.ExportFaceAttrs {
+		attrIndices = bc.faceAttrMaterialIndices()
+	}
+
+	// Process each face and group by material; faces classified as a
+	// material without a color have no group and are dropped
+	dropped := make(map[string]int)
+	for i, face := range faces {
+		var material string
+		if _, known := bc.Colors[inputMaterial(inputMaterials, i)]; known {
+			material = inputMaterials[i]
+			bc.Stats.PreservedMaterials++
+		} else if clusterMaterials != nil {
+			material = clusterMaterials[i]
+		} else {
+			material = bc.classifyFaceWithContext(vertices, face, groundHeight, []int{}, inputMaterial(labels, i))
+		}
+		if index, ok := attrIndices[material]; ok {
+			bc.recordFaceAttribute(vertices, face, index)
+		}
+
+		if group, exists := faceGroups[material]; exists {
+			group.Faces = append(group.Faces, face)
+			if faceCorners != nil {
+				group.FaceVertices = append(group.FaceVertices, faceCorners[i])
+			}
+			// Track which vertices are used by this material
+			for _, vertexIdx := range face {
+				usedVertices[material][vertexIdx] = true
+			}
+		} else {
+			dropped[material]++
+			bc.Stats.DroppedFaces++
+		}
+	}
+	for material, count := range dropped {
+		bc.Logger.Log(LogWarn, "  Warning: Dropped %d faces classified as '%s', which has no color\n", count, material)
+	}
+
+	// Optimize vertices for each material group
+	for material, group := range faceGroups {
+		bc.sortFaces(vertices, group)
+		bc.optimizeVerticesForGroup(vertices, bc.texCoords, group, usedVertices[material])
+		group.Adjacency = bc.BuildAdjacency(group)
+		if bc.CapOpenEdges && len(group.Faces) > 0 {
+			capped := bc.StitchOpenEdges(group, bc.GeometryValidator.Tolerance)
+			if added := len(capped.Faces) - len(group.Faces); added > 0 {
+				bc.Stats.CapFaces[material] += added
+				bc.Logger.Log(LogDebug, "    %s: Added %d cap faces\n", material, added)
+			}
+			group = capped
+			faceGroups[material] = group
+		}
+
+		// Record optimization statistics
+		originalCount := len(vertices)
+		optimizedCount := len(group.OptimizedVertices)
+		reductionPercent := 0.0
+		if originalCount > 0 {
+			reductionPercent = float64(originalCount-optimizedCount) / float64(originalCount) * 100
+		}
+
+		stats := VertexStats{
+			OriginalVertices:  originalCount,
+			OptimizedVertices: optimizedCount,
+			ReductionPercent:  reductionPercent,
+		}
+		bc.Stats.VertexOptimization[material] = stats
+	}
+
+	return faceGroups, groundHeight
+}
+
+// inputMaterial returns the input material (or label) of face i, or "" if
+// materials holds none
+func inputMaterial(materials []string, i int) string {
+	if i < len(materials) {
+		return materials[i]
+	}
+	return ""
+}
+
+// clusterFaceMaterials clusters the faces into KMeansMaterials normal
+// clusters and returns the material of each face's cluster
+func (bc *BuildingColorizer) clusterFaceMaterials(vertices []Vector3, faces []Face) []string {
+	clusters, centres := bc.GeometryValidator.normalClusters(vertices, faces, bc.KMeansMaterials)
+	materials := make([]string, len(faces))
+	for label := range centres {
+		faceIndices := clusters[label]
+		material := normalMaterial(centres[label])
+		bc.Logger.Log(LogDebug, "  Normal cluster %d: %d faces, centre (%.3f, %.3f, %.3f) -> %s\n",
+			label, len(faceIndices), centres[label].X, centres[label].Y, centres[label].Z, material)
+		for _, i := range faceIndices {
+			materials[i] = material
+		}
+	}
+	return materials
+}
+
+// sortFaces orders the faces of a group according to bc.FaceSort.
+// Faces are grouped in input order, so "index" and "none" leave them as is.
+func (bc *BuildingColorizer) sortFaces(vertices []Vector3, group *OptimizedFaceGroup) {
+	switch bc.FaceSort {
+	case "area-asc", "area-desc":
+		areas := make([]float64, len(group.Faces))
+		for i, face := range group.Faces {
+			areas[i] = bc.MeshAnalyzer.ComputeFaceArea(vertices, face)
+		}
+
+		order := make([]int, len(group.Faces))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			if bc.FaceSort == "area-desc" {
+				return areas[order[i]] > areas[order[j]]
+			}
+			return areas[order[i]] < areas[order[j]]
+		})
+
+		sorted := make([]Face, len(group.Faces))
+		for i, idx := range order {
+			sorted[i] = group.Faces[idx]
+		}
+		group.Faces = sorted
+
+		if group.FaceVertices != nil {
+			sortedCorners := make([][]FaceVertex, len(order))
+			for i, idx := range order {
+				sortedCorners[i] = group.FaceVertices[idx]
+			}
+			group.FaceVertices = sortedCorners
+		}
+	}
+}
+
+// optimizeVerticesForGroup creates optimized vertex list and mapping for a material group.
+// allUVs holds one texture coordinate per vertex, or is nil when UVs are not kept.
+func (bc *BuildingColorizer) optimizeVerticesForGroup(allVertices []Vector3, allUVs []TexCoord, group *OptimizedFaceGroup, usedVertexIndices map[int]bool) {
+	if len(usedVertexIndices) == 0 {
+		return
+	}
+
+	// Create sorted list of used vertex indices for consistent ordering
+	var sortedIndices []int
+	for idx := range usedVertexIndices {
+		sortedIndices = append(sortedIndices, idx)
+	}
+	sort.Ints(sortedIndices)
+
+	// Create optimized vertex list and mapping
+	group.OptimizedVertices = make([]Vector3, len(sortedIndices))
+	newIndex := 0
+
+	for _, oldIndex := range sortedIndices {
+		group.OptimizedVertices[newIndex] = allVertices[oldIndex]
+		group.VertexMapping[oldIndex] = newIndex
+		newIndex++
+	}
+
+	if len(allUVs) > 0 && len(allUVs) == len(allVertices) {
+		group.OptimizedUVs = make([]TexCoord, len(sortedIndices))
+		for i, oldIndex := range sortedIndices {
+			group.OptimizedUVs[i] = allUVs[oldIndex]
+		}
+	}
+
+	bc.Logger.Log(LogDebug, "    %s: Optimized from %d to %d vertices (%.1f%% reduction)\n",
+		group.Material, len(allVertices), len(group.OptimizedVertices),
+		float64(len(allVertices)-len(group.OptimizedVertices))/float64(len(allVertices))*100)
+}
+
+// BuildAdjacency maps each directed edge (v_a, v_b) of the group's faces to the
+// indices of the faces containing that edge. Vertex indices refer to the
+// original mesh, as stored in the group's faces.
+func (bc *BuildingColorizer) BuildAdjacency(group *OptimizedFaceGroup) map[[2]int][]int {
+	adjacency := make(map[[2]int][]int)
+	for faceIdx, face := range group.Faces {
+		for i := range face {
+			edge := [2]int{face[i], face[(i+1)%len(face)]}
+			adjacency[edge] = append(adjacency[edge], faceIdx)
+		}
+	}
+	return adjacency
+}
+
+// StitchOpenEdges returns a copy of group whose open boundary is closed with
+// triangular cap faces. Boundary edges are edges used by exactly one face;
+// vertices within epsilon of each other are treated as one, so boundary
+// edges that match another boundary edge in the opposite direction (e.g. at
+// UV seams) pair up and are not capped. The remaining edges are chained into
+// loops, each fan-triangulated with the winding opposite to its faces. Cap
+// faces reuse the group's vertices. Edges are counted from the group's
+// Adjacency, which the returned group has rebuilt when caps were added.
+func (bc *BuildingColorizer) StitchOpenEdges(group *OptimizedFaceGroup, epsilon float64) *OptimizedFaceGroup {
+	capped := group.Clone()
+
+	position := func(idx int) Vector3 {
+		return group.OptimizedVertices[group.VertexMapping[idx]]
+	}
+
+	// Merge vertices within epsilon into the first of them
+	var representatives []int
+	canonical := make(map[int]int)
+	for _, face := range group.Faces {
+		for _, idx := range face {
+			if _, seen := canonical[idx]; seen {
+				continue
+			}
+			canonical[idx] = idx
+			p := position(idx)
+			for _, rep := range representatives {
+				q := position(rep)
+				if math.Abs(p.X-q.X) <= epsilon && math.Abs(p.Y-q.Y) <= epsilon && math.Abs(p.Z-q.Z) <= epsilon {
+					canonical[idx] = rep
+					break
+				}
+			}
+			if canonical[idx] == idx {
+				representatives = append(representatives, idx)
+			}
+		}
+	}
+
+	// Count the faces on each directed edge from the group's adjacency; an
+	// edge is open if neither it nor its reverse appears in another face
+	adjacency := group.Adjacency
+	if adjacency == nil {
+		adjacency = bc.BuildAdjacency(group)
+	}
+	edgeCount := make(map[[2]int]int)
+	counted := make(map[[2]int]bool)
+	var edges [][2]int
+	for _, face := range group.Faces {
+		for i := range face {
+			original := [2]int{face[i], face[(i+1)%len(face)]}
+			edge := [2]int{canonical[original[0]], canonical[original[1]]}
+			if edge[0] == edge[1] || counted[original] {
+				continue
+			}
+			counted[original] = true
+			if edgeCount[edge] == 0 {
+				edges = append(edges, edge)
+			}
+			edgeCount[edge] += len(adjacency[original])
+		}
+	}
+
+	// Cap loops run against the winding of the faces: open edge a->b becomes b->a
+	capNext := make(map[int]int)
+	var starts []int
+	for _, edge := range edges {
+		if edgeCount[edge] == 1 && edgeCount[[2]int{edge[1], edge[0]}] == 0 {
+			if _, exists := capNext[edge[1]]; !exists {
+				capNext[edge[1]] = edge[0]
+				starts = append(starts, edge[1])
+			}
+		}
+	}
+
+	visited := make(map[int]bool)
+	for _, start := range starts {
+		if visited[start] {
+			continue
+		}
+
+		loop := []int{start}
+		visited[start] = true
+		closed := false
+		for current := capNext[start]; ; current = capNext[current] {
+			if current == start {
+				closed = true
+				break
+			}
+			if visited[current] {
+				break
+			}
+			if _, ok := capNext[current]; !ok {
+				visited[current] = true
+				break
+			}
+			visited[current] = true
+			loop = append(loop, current)
+		}
+
+		if !closed || len(loop) < 3 {
+			continue
+		}
+		for i := 1; i+1 < len(loop); i++ {
+			capped.Faces = append(capped.Faces, Face{loop[0], loop[i], loop[i+1]})
+			if capped.FaceVertices != nil {
+				// Cap faces have no texture coordinates or normals
+				capped.FaceVertices = append(capped.FaceVertices, []FaceVertex{{loop[0], -1, -1}, {loop[i], -1, -1}, {loop[i+1], -1, -1}})
+			}
+		}
+	}
+	if len(capped.Faces) > len(group.Faces) {
+		capped.Adjacency = bc.BuildAdjacency(capped)
+	}
+
+	return capped
+}
+
+// PrintDihedralAngles prints the dihedral angle for each edge shared by two faces
+func (bc *BuildingColorizer) PrintDihedralAngles(name string, vertices []Vector3, faces []Face) {
+	adjacency := bc.BuildAdjacency(&OptimizedFaceGroup{Faces: faces})
+
+	// Consistently wound neighbours traverse a shared edge in opposite
+	// directions, inconsistent ones in the same direction, so the faces of
+	// both directions are gathered under the (min, max) vertex pair
+	shared := make(map[[2]int][]int)
+	for edge, faceIndices := range adjacency {
+		a, b := edge[0], edge[1]
+		if a > b {
+			a, b = b, a
+		}
+		shared[[2]int{a, b}] = append(shared[[2]int{a, b}], faceIndices...)
+	}
+
+	var edges [][2]int
+	for edge, faceIndices := range shared {
+		sort.Ints(faceIndices)
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+
+	fmt.Printf("  Dihedral angles for %s (edge: faces -> degrees):\n", name)
+	for _, edge := range edges {
+		faceIndices := shared[edge]
+		if len(faceIndices) < 2 {
+			continue
+		}
+		for i := 1; i < len(faceIndices); i++ {
+			angle := bc.GeometryValidator.ComputeDihedralAngle(vertices, faces[faceIndices[0]], faces[faceIndices[i]])
+			fmt.Printf("    %d-%d: f%d/f%d -> %.3f\n", edge[0]+1, edge[1]+1, faceIndices[0]+1, faceIndices[i]+1, angle)
+		}
+	}
+}
+
+// classifyFaceWithContext classifies face considering neighboring geometry.
+// A label naming a material (see labelMaterial) is used instead of the
+// geometric rules.
+func (bc *BuildingColorizer) classifyFaceWithContext(vertices []Vector3, face Face, groundHeight float64, neighbors []int, label string) string {
+	// Get face properties
+	normal := bc.GeometryValidator.GetFaceNormal(vertices, face)
+
+	// User-defined classification replaces the built-in rules
+	if bc.ClassificationFunc != nil {
+		return bc.ClassificationFunc(vertices, face, groundHeight, normal)
+	}
+
+	// g/o labels such as RoofSurface name the material directly
+	if material := bc.labelMaterial(label); material != "" {
+		return material
+	}
+
+	// Faces outside every building outline are debris or courtyard geometry
+	if bc.UseOutlines && len(bc.BuildingOutlines) > 0 {
+		var cx, cy float64
+		for _, idx := range face {
+			cx += vertices[idx].X
+			cy += vertices[idx].Y
+		}
+		cx /= float64(len(face))
+		cy /= float64(len(face))
+		if !bc.insideAnyOutline(cx, cy) {
+			bc.Stats.OutsideOutlines++
+			return "Ground"
+		}
+	}
+
+	// Basic classification; for tunnels (InvertZ) roofs face downward
+	upward := normal.Z
+	if bc.GeometryValidator.InvertZ {
+		upward = -upward
+	}
+
+	var baseClass string
+	if bc.GeometryValidator.ValidateGroundClassification(vertices, face, groundHeight) {
+		baseClass = "Ground"
+	} else if math.Abs(normal.Z) < bc.WallNormalThreshold { // Nearly vertical
+		baseClass = "Wall"
+	} else if upward > 0 { // Facing upward (downward with InvertZ)
+		baseClass = "Roof"
+	} else {
+		// No rule matched (e.g. downward-facing overhangs)
+		baseClass = bc.DefaultMaterial
+		bc.Stats.DefaultMaterial++
+	}
+
+	return baseClass
+}
+
+// labelMaterial returns Roof, Wall or Ground if label contains that name
+// (case-insensitive) and it is one of Colors, or "" otherwise
+func (bc *BuildingColorizer) labelMaterial(label string) string {
+	lower := strings.ToLower(label)
+	for _, material := range []string{"Roof", "Wall", "Ground"} {
+		if _, known := bc.Colors[material]; known && strings.Contains(lower, strings.ToLower(material)) {
+			return material
+		}
+	}
+	return ""
+}
+
+// SetClassificationCallback sets a user-defined face classification function.
+// Passing nil restores the built-in Roof/Wall/Ground rules.
+func (bc *BuildingColorizer) SetClassificationCallback(fn ClassificationFunc) {
+	bc.ClassificationFunc = fn
+}
+
+// CreateSeparateObjFiles creates separate optimized OBJ files for each material
+func (bc *BuildingColorizer) CreateSeparateObjFiles(objPath string, faceGroups map[string]*OptimizedFaceGroup) error {
+	baseName := strings.TrimSuffix(filepath.Base(objPath), ".obj")
+
+	for material, group := range faceGroups {
+		if len(group.Faces) == 0 {
+			bc.Stats.EmptyMaterialCounts[material]++
+			if bc.WarnOnEmptyMaterial {
+				bc.Logger.Log(LogWarn, "[WARN] %s produced zero %s faces\n", filepath.Base(objPath), material)
+			} else if !bc.EmitEmptyGroups {
+				bc.Logger.Log(LogDebug, "  Skipping %s (no faces)\n", material)
+			}
+		}
+	}
+
+	outputs := []ManifestOutput{}
+	for _, output := range bc.splitOutputs(faceGroups) {
+		material, group := output.Material, output.Group
+		if len(group.Faces) == 0 && !bc.EmitEmptyGroups {
+			continue // Skip groups with no faces
+		}
+
+		// Create filename with material suffix
+		suffix := output.Suffix
+
+		outputDir := bc.materialOutputDir(material)
+		outputPath := filepath.Join(outputDir, baseName+suffix+bc.splitFileExt())
+		mtlPath := baseName + suffix + ".mtl"
+
+		// Material name used in usemtl/newmtl, optionally prefixed to avoid
+		// collisions when several buildings are combined into one scene
+		materialName := material
+		if bc.PrefixMaterialName {
+			materialName = baseName + "_" + material
+		}
+
+		// Count the split file without writing it in summary-only mode
+		if bc.SummaryOnly {
+			bc.Stats.SplitFiles[material]++
+			if bc.DryRun {
+				bc.plannedFiles = append(bc.plannedFiles, PlannedFile{outputPath, len(group.OptimizedVertices), len(group.Faces), estimateSplitFileSize(group)})
+			}
+			continue
+		}
+
+		if bc.OutputHierarchy {
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %v", outputDir, err)
+			}
+		}
+
+		// Create the split file, and for OBJ output its MTL file
+		if err := bc.createSplitFile(outputPath, mtlPath, material, materialName, group); err != nil {
+			return fmt.Errorf("failed to create %s: %v", outputPath, err)
+		}
+		if bc.OutputFormat != OutputFormatGLTF {
+			if err := bc.createMtlFile(filepath.Join(outputDir, mtlPath), material, materialName); err != nil {
+				return fmt.Errorf("failed to create %s: %v", mtlPath, err)
+			}
+		}
+
+		bc.Stats.SplitFiles[material]++
+		bc.Logger.Log(LogDebug, "  Created %s with %d vertices and %d faces\n",
+			filepath.Base(outputPath), len(group.OptimizedVertices), len(group.Faces))
+
+		if bc.ManifestPath != "" {
+			var size int64
+			if info, err := os.Stat(outputPath); err == nil {
+				size = info.Size()
+			}
+			outputs = append(outputs, ManifestOutput{outputPath, material, len(group.OptimizedVertices), len(group.Faces), size})
+		}
+	}
+
+	if bc.ManifestPath != "" && !bc.SummaryOnly {
+		entry := ManifestEntry{Source: objPath, Outputs: outputs}
+		if volume, ok := bc.Stats.BuildingVolumes[filepath.Base(objPath)]; ok && bc.WriteVolume {
+			entry.Volume = &volume
+		}
+		if pitch, ok := bc.Stats.RoofPitches[filepath.Base(objPath)]; ok {
+			entry.RoofPitch = &pitch
+		}
+		bc.manifest = append(bc.manifest, entry)
+	}
+	return nil
+}
+
+// estimateSplitFileSize estimates the bytes of the OBJ split file of group
+// as 24 bytes per vertex plus the average face line length per face, where a
+// face line is "f" followed by one space-separated index per corner
+func estimateSplitFileSize(group *OptimizedFaceGroup) int {
+	if len(group.Faces) == 0 {
+		return len(group.OptimizedVertices) * 24
+	}
+
+	corners := 0
+	for _, face := range group.Faces {
+		corners += len(face)
+	}
+	indexDigits := len(strconv.Itoa(len(group.OptimizedVertices)))
+	avgFaceBytes := 2 + float64(corners)/float64(len(group.Faces))*float64(indexDigits+1)
+	return len(group.OptimizedVertices)*24 + int(float64(len(group.Faces))*avgFaceBytes)
+}
+
+// splitOutput is one split file written for a building
+type splitOutput struct {
+	Material string // Material of the file's faces
+	Suffix   string // Filename suffix, e.g. -roof or -wall-ne
+	Group    *OptimizedFaceGroup
+}
+
+// splitOutputs lists the split files of a building's face groups: one per
+// material, and with OrientWalls one per wall orientation instead of a single
+// wall file. Walls whose orientation cannot be determined keep the -wall suffix.
+// Orientations without faces are listed only with EmitEmptyGroups.
+func (bc *BuildingColorizer) splitOutputs(faceGroups map[string]*OptimizedFaceGroup) []splitOutput {
+	var outputs []splitOutput
+	for material, group := range faceGroups {
+		if !bc.OrientWalls || material != "Wall" || len(group.Faces) == 0 {
+			outputs = append(outputs, splitOutput{material, materialSuffix(material), group})
+			continue
+		}
+
+		oriented := bc.OrientWallGroups(group)
+		if unoriented, ok := oriented[""]; ok {
+			outputs = append(outputs, splitOutput{material, materialSuffix(material), unoriented})
+		}
+		for _, orientation := range wallOrientations {
+			sub, ok := oriented[orientation]
+			if !ok {
+				if !bc.EmitEmptyGroups {
+					continue
+				}
+				sub = &OptimizedFaceGroup{Material: material, Faces: []Face{}, VertexMapping: make(map[int]int)}
+			}
+			outputs = append(outputs, splitOutput{material, materialSuffix(material) + "-" + strings.ToLower(orientation), sub})
+		}
+	}
+	return outputs
+}
+
+// OrientWallGroups splits a wall group by the compass orientation of each
+// face's normal, as returned by GetFaceOrientation. The normals are taken from
+// the face winding, so walls must face outward (see FixOrientation).
+func (bc *BuildingColorizer) OrientWallGroups(group *OptimizedFaceGroup) map[string]*OptimizedFaceGroup {
+	faceIndices := make(map[string][]int)
+	for i, face := range group.Faces {
+		remapped := make(Face, len(face))
+		for j, idx := range face {
+			remapped[j] = group.VertexMapping[idx]
+		}
+		orientation := bc.MeshAnalyzer.GetFaceOrientation(bc.MeshAnalyzer.faceNormal(group.OptimizedVertices, remapped))
+		faceIndices[orientation] = append(faceIndices[orientation], i)
+	}
+
+	oriented := make(map[string]*OptimizedFaceGroup)
+	for orientation, indices := range faceIndices {
+		oriented[orientation] = bc.subGroup(group, indices)
+	}
+	return oriented
+}
+
+// subGroup returns a group holding the faces of group at faceIndices and only
+// the optimized vertices (and texture coordinates) those faces use
+func (bc *BuildingColorizer) subGroup(group *OptimizedFaceGroup, faceIndices []int) *OptimizedFaceGroup {
+	sub := &OptimizedFaceGroup{Material: group.Material, VertexMapping: make(map[int]int)}
+	subIndex := make(map[int]int) // group vertex index -> sub vertex index
+	for _, faceIndex := range faceIndices {
+		face := group.Faces[faceIndex]
+		sub.Faces = append(sub.Faces, face)
+		if group.FaceVertices != nil {
+			sub.FaceVertices = append(sub.FaceVertices, group.FaceVertices[faceIndex])
+		}
+		for _, oldIdx := range face {
+			idx := group.VertexMapping[oldIdx]
+			newIdx, ok := subIndex[idx]
+			if !ok {
+				newIdx = len(sub.OptimizedVertices)
+				subIndex[idx] = newIdx
+				sub.OptimizedVertices = append(sub.OptimizedVertices, group.OptimizedVertices[idx])
+				if len(group.OptimizedUVs) > 0 {
+					sub.OptimizedUVs = append(sub.OptimizedUVs, group.OptimizedUVs[idx])
+				}
+			}
+			sub.VertexMapping[oldIdx] = newIdx
+		}
+	}
+	sub.Adjacency = bc.BuildAdjacency(sub)
+	return sub
+}
+
+// createSplitFile writes a group to the split file at outputPath in OutputFormat
+func (bc *BuildingColorizer) createSplitFile(outputPath, mtlPath, material, materialName string, group *OptimizedFaceGroup) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := bc.writeSplitGroup(file, mtlPath, material, materialName, group); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeSplitGroup writes a group to w as OBJ data referencing mtlPath, or as
+// a binary glTF file with OutputFormatGLTF
+func (bc *BuildingColorizer) writeSplitGroup(w io.Writer, mtlPath, material, materialName string, group *OptimizedFaceGroup) error {
+	if bc.OutputFormat == OutputFormatGLTF {
+		glb, err := bc.encodeGlb(material, materialName, group)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(glb)
+		return err
+	}
+	return bc.writeOptimizedObj(w, mtlPath, materialName, group)
+}
+
+// ProcessBuildingToWriter runs the processing pipeline of ProcessMesh on a
+// loaded mesh and writes each material group to the writer of the same name
+// in OutputFormat, without touching the file system. OBJ data references the
+// material library <material>.mtl. Groups without a writer are not written;
+// a writer named after an unknown material is an error.
+func (bc *BuildingColorizer) ProcessBuildingToWriter(vertices []Vector3, faces []Face, writers map[string]io.Writer) error {
+	for material := range writers {
+		if _, known := bc.Colors[material]; !known {
+			return fmt.Errorf("unknown material %q", material)
+		}
+	}
+
+	faceGroups, _ := bc.ProcessMesh(vertices, faces, nil)
+	for material, w := range writers {
+		if err := bc.writeSplitGroup(w, material+".mtl", material, material, faceGroups[material]); err != nil {
+			return fmt.Errorf("failed to write %s: %v", material, err)
+		}
+	}
+	return nil
+}
+
+// materialOutputDir returns the directory split files of a material are written
+// to: the output directory itself, or its material subdirectory with OutputHierarchy.
+// The MTL file is written next to its OBJ file, so mtllib needs no directory.
+func (bc *BuildingColorizer) materialOutputDir(material string) string {
+	if bc.OutputHierarchy {
+		return filepath.Join(bc.OutputDir, material)
+	}
+	return bc.OutputDir
+}
+
+// splitFileExt returns the file extension of split files in OutputFormat
+func (bc *BuildingColorizer) splitFileExt() string {
+	if bc.OutputFormat == OutputFormatGLTF {
+		return ".glb"
+	}
+	return ".obj"
+}
+
+// materialSuffix returns the output filename suffix for a material: the
+// lower-case material name after a hyphen, e.g. -roof, with characters other
+// than letters, digits, '-' and '_' replaced by '_'
+func materialSuffix(material string) string {
+	suffix := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, material)
+	return "-" + suffix
+}
+
+// writeOptimizedObj writes a group as OBJ data referencing the material library mtlPath
+func (bc *BuildingColorizer) writeOptimizedObj(w io.Writer, mtlPath, materialName string, group *OptimizedFaceGroup) error {
+	writer := bufio.NewWriter(w)
+
+	// Write header
+	if bc.NormaliseFaceIndices {
+		writer.WriteString("# Face indices are 0-based (non-standard)\n")
+	}
+	writer.WriteString(fmt.Sprintf("# Generated by Building Colorizer v%s - %s (Optimized)\n", Version, group.Material))
+	writer.WriteString(fmt.Sprintf("# Vertices: %d, Faces: %d\n", len(group.OptimizedVertices), len(group.Faces)))
+	if bc.ObjUnits != "m" {
+		writer.WriteString(fmt.Sprintf("# Input units: %s (scaled to metres by %g)\n", bc.ObjUnits, units.Scales[bc.ObjUnits]))
+	}
+	writer.WriteString(fmt.Sprintf("mtllib %s\n", mtlPath))
+	writer.WriteString("\n")
+
+	// Empty groups (EmitEmptyGroups) get the header only
+	if len(group.Faces) == 0 {
+		return writer.Flush()
+	}
+
+	// Vertex and face lines are assembled in a pooled buffer to avoid one
+	// string allocation per line
+	var line *[]byte
+	if bc.ObjLinePool != nil {
+		line = bc.ObjLinePool.Get().(*[]byte)
+		defer bc.ObjLinePool.Put(line)
+	} else {
+		line = new([]byte)
+	}
+
+	// Write optimized vertices
+	for _, vertex := range group.OptimizedVertices {
+		if bc.Obfuscation != nil {
+			vertex = bc.Obfuscation.Apply(vertex)
+		}
+		vertex.X, vertex.Y, vertex.Z = bc.AxisPermutation.Apply(vertex.X, vertex.Y, vertex.Z)
+		*line = appendObjFloats(append((*line)[:0], 'v'), vertex.X, vertex.Y, vertex.Z)
+		writer.Write(*line)
+	}
+	writer.WriteString("\n")
+
+	// Write one texture coordinate per vertex so vt indices match v indices
+	if len(group.OptimizedUVs) > 0 {
+		for _, uv := range group.OptimizedUVs {
+			*line = appendObjFloats(append((*line)[:0], 'v', 't'), uv.U, uv.V)
+			writer.Write(*line)
+		}
+		writer.WriteString("\n")
+	}
+
+	// Otherwise the input's vt and vn lines are global, so they are written
+	// back (normals transformed like the vertices) and the faces keep their
+	// original vt/vn indices
+	keepCorners := len(group.OptimizedUVs) == 0 && len(group.FaceVertices) == len(group.Faces)
+	if keepCorners {
+		for _, l := range bc.texCoordLines {
+			writer.WriteString(l + "\n")
+		}
+		if len(bc.texCoordLines) > 0 {
+			writer.WriteString("\n")
+		}
+		for _, l := range bc.normalLines {
+			writer.WriteString(bc.outputNormalLine(l) + "\n")
+		}
+		if len(bc.normalLines) > 0 {
+			writer.WriteString("\n")
+		}
+	}
+
+	// Write material usage and faces with remapped indices, with one group
+	// per roof plane when SplitRoofPlanes is set
+	writer.WriteString(fmt.Sprintf("usemtl %s\n", materialName))
+	planeStarts := make(map[int]int)
+	faceOrder := make([]int, 0, len(group.Faces))
+	if bc.SplitRoofPlanes && group.Material == "Roof" {
+		planes := bc.ClusterRoofPlanes(group)
+		for p, plane := range planes {
+			planeStarts[len(faceOrder)] = p + 1
+			faceOrder = append(faceOrder, plane.Faces...)
+		}
+		bc.Logger.Log(LogDebug, "  Found %d roof planes in %s\n", len(planes), materialName)
+	} else {
+		for i := range group.Faces {
+			faceOrder = append(faceOrder, i)
+		}
+	}
+	indexBase := 1 // OBJ indices start at 1
+	if bc.NormaliseFaceIndices {
+		indexBase = 0
+	}
+	mirrored := bc.AxisPermutation.Odd()
+	for i, faceIndex := range faceOrder {
+		if plane, ok := planeStarts[i]; ok {
+			writer.WriteString(fmt.Sprintf("g roof_plane_%d\n", plane))
+		}
+		face := group.Faces[faceIndex]
+		*line = append((*line)[:0], 'f')
+		for k := range face {
+			// An odd permutation mirrors the mesh, so the winding is
+			// reversed to keep the faces pointing outwards
+			c := k
+			if mirrored {
+				c = len(face) - 1 - k
+			}
+			oldIdx := face[c]
+			newIdx := group.VertexMapping[oldIdx]
+			*line = strconv.AppendInt(append(*line, ' '), int64(newIdx+indexBase), 10)
+			if len(group.OptimizedUVs) > 0 && group.OptimizedUVs[newIdx].Valid {
+				*line = strconv.AppendInt(append(*line, '/'), int64(newIdx+indexBase), 10)
+			} else if keepCorners && c < len(group.FaceVertices[faceIndex]) {
+				*line = appendFaceCorner(*line, group.FaceVertices[faceIndex][c], indexBase)
+			}
+		}
+		*line = append(*line, '\n')
+		writer.Write(*line)
+	}
+
+	return writer.Flush()
+}
+
+// outputNormalLine returns an input "vn" line rotated by Obfuscation and with
+// its axes permuted by AxisPermutation, matching the written vertices
+func (bc *BuildingColorizer) outputNormalLine(line string) string {
+	if bc.Obfuscation == nil {
+		return bc.AxisPermutation.NormalLine(line)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[0] != "vn" {
+		return line
+	}
+	var normal Vector3
+	for i, target := range []*float64{&normal.X, &normal.Y, &normal.Z} {
+		value, err := strconv.ParseFloat(fields[1+i], 64)
+		if err != nil {
+			return line
+		}
+		*target = value
+	}
+	normal = bc.Obfuscation.ApplyDirection(normal)
+	normal.X, normal.Y, normal.Z = bc.AxisPermutation.Apply(normal.X, normal.Y, normal.Z)
+	return strings.TrimSuffix(string(appendObjFloats([]byte("vn"), normal.X, normal.Y, normal.Z)), "\n")
+}
+
+// appendFaceCorner appends the "/vt", "/vt/vn" or "//vn" part of a face
+// corner to line; nothing is appended if the corner has neither
+func appendFaceCorner(line []byte, corner FaceVertex, indexBase int) []byte {
+	if corner.TexCoordIdx < 0 && corner.NormalIdx < 0 {
+		return line
+	}
+	line = append(line, '/')
+	if corner.TexCoordIdx >= 0 {
+		line = strconv.AppendInt(line, int64(corner.TexCoordIdx+indexBase), 10)
+	}
+	if corner.NormalIdx >= 0 {
+		line = strconv.AppendInt(append(line, '/'), int64(corner.NormalIdx+indexBase), 10)
+	}
+	return line
+}
+
+// appendObjFloats appends " %.6f" for each value and a newline to line
+func appendObjFloats(line []byte, values ...float64) []byte {
+	for _, value := range values {
+		line = strconv.AppendFloat(append(line, ' '), value, 'f', 6, 64)
+	}
+	return append(line, '\n')
+}
+
+// glTF component types, buffer view targets and GLB chunk types
+const (
+	gltfFloat              = 5126
+	gltfUnsignedInt        = 5125
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+	glbMagic               = 0x46546C67 // "glTF"
+	glbChunkJSON           = 0x4E4F534A // "JSON"
+	glbChunkBIN            = 0x004E4942 // "BIN\0"
+)
+
+// gltfDocument is the JSON part of a glTF 2.0 asset with a single mesh
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   int            `json:"material"`
+}
+
+type gltfMaterial struct {
+	Name                 string                   `json:"name"`
+	PBRMetallicRoughness gltfPBRMetallicRoughness `json:"pbrMetallicRoughness"`
+	AlphaMode            string                   `json:"alphaMode,omitempty"`
+	DoubleSided          bool                     `json:"doubleSided"`
+}
+
+type gltfPBRMetallicRoughness struct {
+	BaseColorFactor [4]float64 `json:"baseColorFactor"`
+	MetallicFactor  float64    `json:"metallicFactor"`
+	RoughnessFactor float64    `json:"roughnessFactor"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+// encodeGlb encodes a group as a binary glTF 2.0 file with one mesh.
+// Vertices are transformed like OBJ output; faces are fan-triangulated and
+// the material's Colors entry becomes its base color factor. Texture
+// coordinates are written as TEXCOORD_0 when the group keeps UV islands.
+func (bc *BuildingColorizer) encodeGlb(material, materialName string, group *OptimizedFaceGroup) ([]byte, error) {
+	var bin []byte
+	appendFloat := func(value float64) {
+		bin = binary.LittleEndian.AppendUint32(bin, math.Float32bits(float32(value)))
+	}
+
+	// Positions, with the bounds glTF requires for the POSITION accessor
+	minPos := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	maxPos := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, vertex := range group.OptimizedVertices {
+		if bc.Obfuscation != nil {
+			vertex = bc.Obfuscation.Apply(vertex)
+		}
+		vertex.X, vertex.Y, vertex.Z = bc.AxisPermutation.Apply(vertex.X, vertex.Y, vertex.Z)
+		for axis, value := range []float64{vertex.X, vertex.Y, vertex.Z} {
+			value = float64(float32(value))
+			minPos[axis] = math.Min(minPos[axis], value)
+			maxPos[axis] = math.Max(maxPos[axis], value)
+			appendFloat(value)
+		}
+	}
+	positionLength := len(bin)
+
+	// glTF texture coordinates have their origin at the top left
+	if len(group.OptimizedUVs) > 0 {
+		for _, uv := range group.OptimizedUVs {
+			appendFloat(uv.U)
+			appendFloat(1 - uv.V)
+		}
+	}
+	uvLength := len(bin) - positionLength
+
+	indexCount := 0
+	mirrored := bc.AxisPermutation.Odd()
+	for _, face := range group.Faces {
+		for i := 1; i < len(face)-1; i++ {
+			triangle := []int{face[0], face[i], face[i+1]}
+			if mirrored {
+				triangle[1], triangle[2] = triangle[2], triangle[1]
+			}
+			for _, oldIdx := range triangle {
+				bin = binary.LittleEndian.AppendUint32(bin, uint32(group.VertexMapping[oldIdx]))
+			}
+			indexCount += 3
+		}
+	}
+	indexOffset := positionLength + uvLength
+
+	color := bc.Colors[material]
+	gltfMat := gltfMaterial{
+		Name: materialName,
+		PBRMetallicRoughness: gltfPBRMetallicRoughness{
+			BaseColorFactor: [4]float64{color.R, color.G, color.B, color.A},
+			RoughnessFactor: 1,
+		},
+		DoubleSided: true,
+	}
+	if color.A < 1 {
+		gltfMat.AlphaMode = "BLEND"
+	}
+
+	doc := gltfDocument{
+		Asset:     gltfAsset{Version: "2.0", Generator: "Building Colorizer v" + Version},
+		Scenes:    []gltfScene{{Nodes: []int{0}}},
+		Nodes:     []gltfNode{{Name: materialName, Mesh: 0}},
+		Materials: []gltfMaterial{gltfMat},
+		Accessors: []gltfAccessor{
+			{BufferView: 0, ComponentType: gltfFloat, Count: len(group.OptimizedVertices), Type: "VEC3", Min: minPos, Max: maxPos},
+			{BufferView: 1, ComponentType: gltfUnsignedInt, Count: indexCount, Type: "SCALAR"},
+		},
+		BufferViews: []gltfBufferView{
+			{Buffer: 0, ByteOffset: 0, ByteLength: positionLength, Target: gltfArrayBuffer},
+			{Buffer: 0, ByteOffset: indexOffset, ByteLength: len(bin) - indexOffset, Target: gltfElementArrayBuffer},
+		},
+		Buffers: []gltfBuffer{{ByteLength: len(bin)}},
+	}
+	primitive := gltfPrimitive{Attributes: map[string]int{"POSITION": 0}, Indices: 1, Material: 0}
+	if uvLength > 0 {
+		doc.Accessors = append(doc.Accessors, gltfAccessor{BufferView: 2, ComponentType: gltfFloat, Count: len(group.OptimizedUVs), Type: "VEC2"})
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{Buffer: 0, ByteOffset: positionLength, ByteLength: uvLength, Target: gltfArrayBuffer})
+		primitive.Attributes["TEXCOORD_0"] = 2
+	}
+	doc.Meshes = []gltfMesh{{Name: materialName, Primitives: []gltfPrimitive{primitive}}}
+
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunks are padded to 4 bytes: JSON with spaces, binary data with zeros
+	for len(jsonData)%4 != 0 {
+		jsonData = append(jsonData, ' ')
+	}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+
+	total := 12 + 8 + len(jsonData) + 8 + len(bin)
+	glb := make([]byte, 0, total)
+	glb = binary.LittleEndian.AppendUint32(glb, glbMagic)
+	glb = binary.LittleEndian.AppendUint32(glb, 2)
+	glb = binary.LittleEndian.AppendUint32(glb, uint32(total))
+	glb = binary.LittleEndian.AppendUint32(glb, uint32(len(jsonData)))
+	glb = binary.LittleEndian.AppendUint32(glb, glbChunkJSON)
+	glb = append(glb, jsonData...)
+	glb = binary.LittleEndian.AppendUint32(glb, uint32(len(bin)))
+	glb = binary.LittleEndian.AppendUint32(glb, glbChunkBIN)
+	glb = append(glb, bin...)
+
+	return glb, nil
+}
+
+// createMtlFile creates a material file for a specific material.
+// materialName is the name written to newmtl and may differ from material
+// when material name prefixing is enabled.
+func (bc *BuildingColorizer) createMtlFile(mtlPath, material, materialName string) error {
+	file, err := os.Create(mtlPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	defer writer.Flush()
+
+	color := bc.Colors[material]
+
+	writer.WriteString(fmt.Sprintf("# Generated by Building Colorizer v%s - %s\n\n", Version, material))
+	writer.WriteString(fmt.Sprintf("newmtl %s\n", materialName))
+	writer.WriteString("Ka 0.000 0.000 0.000\n")
+	writer.WriteString(fmt.Sprintf("Kd %.6f %.6f %.6f\n", color.R, color.G, color.B))
+	writer.WriteString("Ks 0.000 0.000 0.000\n")
+	writer.WriteString(fmt.Sprintf("d %.6f\n", color.A))
+	writer.WriteString("illum 1\n")
+
+	return nil
+}
+
+// clampVertexZ limits vertex Z values to [ZClampMin, ZClampMax] and returns
+// the number of vertices changed
+func (bc *BuildingColorizer) clampVertexZ(name string, vertices []Vector3) int {
+	clamped := 0
+	for i := range vertices {
+		z := math.Max(bc.ZClampMin, math.Min(bc.ZClampMax, vertices[i].Z))
+		if z == vertices[i].Z {
+			continue
+		}
+		bc.recordRepair(RepairLogEntry{name, RepairZClamp, -1, i, fmt.Sprintf("%g", vertices[i].Z), fmt.Sprintf("%g", z)})
+		vertices[i].Z = z
+		clamped++
+	}
+	return clamped
+}
+
+// objFaceIndices formats the vertex indices of face as 1-based OBJ indices
+func objFaceIndices(face Face) string {
+	indices := make([]string, len(face))
+	for i, idx := range face {
+		indices[i] = strconv.Itoa(idx + 1)
+	}
+	return strings.Join(indices, " ")
+}
+
+// WriteManifest writes the split files created for each input file to path as
+// JSON, together with the run timestamp, Version and the input and output
+// paths of the run
+func (bc *BuildingColorizer) WriteManifest(path string) error {
+	files := bc.manifest
+	if files == nil {
+		files = []ManifestEntry{}
+	}
+	doc := struct {
+		Timestamp string          `json:"timestamp"`
+		Version   string          `json:"version"`
+		ObjDir    string          `json:"obj_dir"`
+		Output    string          `json:"output"`
+		GeoJSON   string          `json:"geojson"`
+		Files     []ManifestEntry `json:"files"`
+	}{bc.StartTime.Format(time.RFC3339), Version, bc.ObjDir, bc.OutputDir, bc.GeoJSONPath, files}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+// recordRepair adds entry to the repair log when RepairLogPath is set
+func (bc *BuildingColorizer) recordRepair(entry RepairLogEntry) {
+	if bc.RepairLogPath != "" {
+		bc.repairLog = append(bc.repairLog, entry)
+	}
+}
+
+// WriteRepairLog writes the recorded mesh repairs to path as XML
+func (bc *BuildingColorizer) WriteRepairLog(path string) error {
+	doc := struct {
+		XMLName xml.Name         `xml:"repairLog"`
+		Version string           `xml:"version,attr"`
+		Count   int              `xml:"count,attr"`
+		Repairs []RepairLogEntry `xml:"repair"`
+	}{Version: Version, Count: len(bc.repairLog), Repairs: bc.repairLog}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode repair log: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		return fmt.Errorf("failed to write repair log: %v", err)
+	}
+	return nil
+}
+
+// checkUVCoordinates counts face corners with texture coordinates outside
+// [0,1] and clamps them when ClampUVs is set
+func (bc *BuildingColorizer) checkUVCoordinates(name string, faces []Face) {
+	uvs := make([][]float64, len(faces))
+	for i, face := range faces {
+		for _, idx := range face {
+			if tc := bc.texCoords[idx]; tc.Valid {
+				uvs[i] = append(uvs[i], tc.U, tc.V)
+			}
+		}
+	}
+
+	warnings := bc.GeometryValidator.ValidateUVCoordinates(uvs)
+	if len(warnings) == 0 {
+		return
+	}
+	bc.Stats.OutOfRangeUVs += len(warnings)
+	if bc.Logger.Enabled(LogDebug) {
+		fmt.Printf("  UV coordinates outside [0,1]: %d\n", len(warnings))
+		for _, w := range warnings {
+			fmt.Printf("    face %d, vertex %d: (%.4f, %.4f)\n", w.FaceIndex, w.VertexIndex, w.U, w.V)
+		}
+	}
+
+	if bc.ClampUVs {
+		for i, tc := range bc.texCoords {
+			if !tc.Valid {
+				continue
+			}
+			u, v := math.Max(0, math.Min(1, tc.U)), math.Max(0, math.Min(1, tc.V))
+			if u != tc.U || v != tc.V {
+				bc.recordRepair(RepairLogEntry{name, RepairUVClamp, -1, i, fmt.Sprintf("%g %g", tc.U, tc.V), fmt.Sprintf("%g %g", u, v)})
+			}
+			bc.texCoords[i].U, bc.texCoords[i].V = u, v
+		}
+	}
+}
+
+// ProcessBuilding processes a single building and splits it into optimized separate files
+func (bc *BuildingColorizer) ProcessBuilding(ctx context.Context, objPath string) {
+	if ctx.Err() != nil {
+		bc.interruptedFiles++
+		return
+	}
+
+	if bc.SkipExisting && bc.splitFilesUpToDate(objPath) {
+		bc.Stats.SkippedFiles++
+		bc.ExpectedSplitFiles[objPath] = bc.previousSplitFiles[filepath.Base(objPath)]
+		bc.Logger.Log(LogDebug, "\nSkipping %s: split files are up to date\n", filepath.Base(objPath))
+		return
+	}
+
+	file, err := os.Open(objPath)
+	if err != nil {
+		bc.Logger.Log(LogError, "  Failed to load mesh data for %s: %v\n", filepath.Base(objPath), err)
+		bc.Stats.FailedFiles = append(bc.Stats.FailedFiles, FailedFile{filepath.Base(objPath), err.Error()})
+		return
+	}
+	defer file.Close()
+
+	bc.ProcessBuildingFromReader(file, objPath)
+}
+
+// splitIndexName is the file in OutputDir recording the split files written
+// for each input file, which SkipExisting checks on the next run
+const splitIndexName = "split-files.json"
+
+// splitFilesUpToDate reports whether the split index of the last run lists
+// objPath and every split file it recorded for objPath exists and was
+// modified after objPath. An input that produced no split files is up to
+// date if the index itself is newer.
+func (bc *BuildingColorizer) splitFilesUpToDate(objPath string) bool {
+	source, err := os.Stat(objPath)
+	if err != nil {
+		return false
+	}
+
+	names, ok := bc.previousSplitFiles[filepath.Base(objPath)]
+	if !ok {
+		return false
+	}
+	if len(names) == 0 {
+		return bc.splitIndexTime.After(source.ModTime())
+	}
+	for _, name := range names {
+		output, err := os.Stat(filepath.Join(bc.OutputDir, name))
+		if err != nil || !output.ModTime().After(source.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadSplitIndex reads the split index of the last run from OutputDir. A
+// missing or unreadable index leaves every input to be processed.
+func (bc *BuildingColorizer) loadSplitIndex() {
+	bc.previousSplitFiles = nil
+	indexPath := filepath.Join(bc.OutputDir, splitIndexName)
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		bc.Logger.Log(LogWarn, "Warning: failed to read %s: %v\n", indexPath, err)
+		return
+	}
+	if err := json.Unmarshal(data, &bc.previousSplitFiles); err != nil {
+		bc.Logger.Log(LogWarn, "Warning: ignoring invalid %s: %v\n", indexPath, err)
+		bc.previousSplitFiles = nil
+		return
+	}
+	bc.splitIndexTime = info.ModTime()
+}
+
+// writeSplitIndex records the split files of every input processed or
+// skipped in this run, and keeps the entries of the last run for inputs not
+// reached, in the split index of OutputDir
+func (bc *BuildingColorizer) writeSplitIndex() error {
+	index := make(map[string][]string, len(bc.previousSplitFiles)+len(bc.ExpectedSplitFiles))
+	for name, files := range bc.previousSplitFiles {
+		index[name] = files
+	}
+	for objPath, files := range bc.ExpectedSplitFiles {
+		if files == nil {
+			files = []string{}
+		}
+		index[filepath.Base(objPath)] = files
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode split index: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(bc.OutputDir, splitIndexName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write split index: %v", err)
+	}
+	return nil
+}
+
+// fixInvertedFaces reverses the winding of the given faces, and of their
+// loaded vt/vn corners, so that their normals point away from the centroid
+func (bc *BuildingColorizer) fixInvertedFaces(name string, faces []Face, inverted []int) {
+	for _, i := range inverted {
+		face := faces[i]
+		before := objFaceIndices(face)
+		for a, b := 0, len(face)-1; a < b; a, b = a+1, b-1 {
+			face[a], face[b] = face[b], face[a]
+		}
+		if i < len(bc.faceVertices) {
+			corners := bc.faceVertices[i]
+			for a, b := 0, len(corners)-1; a < b; a, b = a+1, b-1 {
+				corners[a], corners[b] = corners[b], corners[a]
+			}
+		}
+		bc.recordRepair(RepairLogEntry{name, RepairWindingFlip, i, -1, before, objFaceIndices(face)})
+	}
+}
+
+// ProcessBuildingFromReader processes OBJ data read from r. name is the
+// input file name; its base name determines the names of the split files.
+func (bc *BuildingColorizer) ProcessBuildingFromReader(r io.Reader, name string) {
+	bc.Logger.Log(LogDebug, "\nProcessing: %s\n", filepath.Base(name))
+
+	// Load mesh data
+	bc.Logger.Log(LogDebug, "  Loading mesh data...\n")
+	vertices, faces, labels, err := bc.LoadObjFromReader(r, filepath.Base(name))
+	if err != nil {
+		bc.Logger.Log(LogError, "  Failed to load mesh data for %s: %v\n", filepath.Base(name), err)
+		bc.Stats.FailedFiles = append(bc.Stats.FailedFiles, FailedFile{filepath.Base(name), err.Error()})
+		return
+	}
+
+	bc.Logger.Log(LogDebug, "  Loaded %d vertices and %d faces\n", len(vertices), len(faces))
+
+	// Tune the tolerance to this building's resolution, restoring the
+	// configured validator afterwards
+	if bc.AutoTuneTolerances {
+		if spacing := bc.MeshAnalyzer.EstimatePointSpacing(vertices, faces); spacing > 0 {
+			configured := bc.GeometryValidator
+			tuned := *configured
+			tuned.Tolerance = 0.5 * spacing
+			bc.GeometryValidator = &tuned
+			defer func() { bc.GeometryValidator = configured }()
+			bc.Logger.Log(LogDebug, "  Point spacing: %.4f, tolerance set to %.4f\n", spacing, tuned.Tolerance)
+		}
+	}
+
+	if clamped := bc.clampVertexZ(filepath.Base(name), vertices); clamped > 0 {
+		bc.Stats.ClampedVertices += clamped
+		bc.Logger.Log(LogDebug, "  Clamped Z of %d vertices to [%g, %g]\n", clamped, bc.ZClampMin, bc.ZClampMax)
+	}
+
+	if bc.ValidateManifold {
+		isManifold, openEdges, nonManifoldEdges := bc.GeometryValidator.ValidateManifold(faces)
+		bc.Stats.Manifold[filepath.Base(name)] = ManifoldStatus{isManifold, openEdges, nonManifoldEdges}
+		bc.Logger.Log(LogDebug, "  Manifold: %t (%d open edges, %d non-manifold edges)\n", isManifold, openEdges, nonManifoldEdges)
+		if nonManifoldEdges > 0 {
+			if bc.StrictManifold {
+				bc.Logger.Log(LogError, "  Skipping %s: %d non-manifold edges\n", filepath.Base(name), nonManifoldEdges)
+				bc.Stats.FailedFiles = append(bc.Stats.FailedFiles, FailedFile{filepath.Base(name), fmt.Sprintf("Non-manifold mesh: %d edges shared by more than two faces", nonManifoldEdges)})
+				return
+			}
+			bc.Logger.Log(LogWarn, "  Warning: %s has %d non-manifold edges\n", filepath.Base(name), nonManifoldEdges)
+		}
+	}
+
+	inverted := bc.GeometryValidator.findInvertedFaces(vertices, faces)
+	if len(inverted) > 0 {
+		bc.Stats.InvertedFaces += len(inverted)
+		bc.Logger.Log(LogDebug, "  Inverted faces: %d\n", len(inverted))
+		if bc.FixOrientation {
+			bc.fixInvertedFaces(filepath.Base(name), faces, inverted)
+		}
+	}
+
+	if len(bc.texCoords) > 0 {
+		bc.checkUVCoordinates(filepath.Base(name), faces)
+	}
+
+	if bc.DetectDuplicates {
+		bc.meshes[strings.TrimSuffix(filepath.Base(name), ".obj")] = buildingMesh{vertices, faces}
+	}
+
+	if bc.WriteVolume {
+		volume := bc.MeshAnalyzer.ComputeMeshVolume(vertices, faces)
+		bc.Stats.BuildingVolumes[filepath.Base(name)] = volume
+		bc.Logger.Log(LogDebug, "  Building volume: %.3f m³\n", volume)
+	}
+
+	if bc.DumpDihedralAngles {
+		bc.PrintDihedralAngles(filepath.Base(name), vertices, faces)
+	}
+
+	// Process mesh and create optimized face groups
+	bc.Logger.Log(LogDebug, "  Processing mesh and optimizing vertices...\n")
+	faceGroups, groundHeight := bc.ProcessMesh(vertices, faces, labels)
+	bc.Logger.Log(LogDebug, "  Ground height detected: %.2f\n", groundHeight)
+
+	if bc.ExportHeightFeats {
+		bc.recordHeightFeatures(strings.TrimSuffix(filepath.Base(name), ".obj"), vertices, groundHeight)
+	}
+
+	if bc.HistogramPathTemplate != "" && !bc.SummaryOnly {
+		zValues := make([]float64, len(vertices))
+		for i, v := range vertices {
+			zValues[i] = v.Z
+		}
+		histPath := strings.ReplaceAll(bc.HistogramPathTemplate, "{base}", strings.TrimSuffix(filepath.Base(name), ".obj"))
+		if err := bc.MeshAnalyzer.ExportZHistogram(histPath, zValues); err != nil {
+			bc.Logger.Log(LogWarn, "  Warning: %v\n", err)
+		} else {
+			bc.Logger.Log(LogDebug, "  Z histogram written to: %s\n", histPath)
+		}
+	}
+
+	// Print face and vertex distribution
+	for material, group := range faceGroups {
+		if len(group.Faces) > 0 {
+			bc.Logger.Log(LogDebug, "  %s: %d faces, %d vertices\n", material, len(group.Faces), len(group.OptimizedVertices))
+		}
+	}
+
+	// Keep each group's vertices to measure how far simplification moves the output
+	var unsimplified map[string][]Vector3
+	if bc.ComputeHausdorff {
+		unsimplified = make(map[string][]Vector3, len(faceGroups))
+		for material, group := range faceGroups {
+			unsimplified[material] = group.OptimizedVertices
+		}
+	}
+
+	if bc.TotalFaceBudget > 0 {
+		bc.applyFaceBudget(filepath.Base(name), vertices, faceGroups)
+	}
+
+	if bc.ComputeHausdorff {
+		bc.recordHausdorffDistances(unsimplified, faceGroups)
+	}
+
+	// The roof pitch is recorded before the split files so the manifest can list it
+	if roof := faceGroups["Roof"]; roof != nil && len(roof.Faces) > 0 {
+		pitch := bc.MeshAnalyzer.ComputeRoofPitch(roof, vertices)
+		bc.Stats.RoofPitches[filepath.Base(name)] = pitch
+		bc.Logger.Log(LogDebug, "  Roof pitch: %.1f°\n", pitch)
+	}
+
+	// Create separate optimized OBJ files for each material
+	bc.Logger.Log(LogDebug, "  Creating optimized OBJ files...\n")
+	if err := bc.CreateSeparateObjFiles(name, faceGroups); err != nil {
+		bc.Stats.FailedFiles = append(bc.Stats.FailedFiles, FailedFile{filepath.Base(name), fmt.Sprintf("File splitting failed: %v", err)})
+		return
+	}
+
+	// Remember which split files this input should have produced
+	baseName := strings.TrimSuffix(filepath.Base(name), ".obj")
+	var expected []string
+	for _, output := range bc.splitOutputs(faceGroups) {
+		if len(output.Group.Faces) > 0 || bc.EmitEmptyGroups {
+			splitName := baseName + output.Suffix + bc.splitFileExt()
+			if bc.OutputHierarchy {
+				splitName = filepath.Join(output.Material, splitName)
+			}
+			expected = append(expected, splitName)
 		}
 	}
+	sort.Strings(expected)
+	bc.ExpectedSplitFiles[name] = expected
 
-	if len(vertices) == 0 || len(faces) == 0 {
-		return nil, nil, fmt.Errorf("no valid vertices or faces found")
+	// Wall groups are only kept for the all-pairs shared wall comparison
+	if wall := faceGroups["Wall"]; bc.MarkSharedWalls && wall != nil && len(wall.Faces) > 0 {
+		bc.wallGroups[baseName] = wall
 	}
 
-	return vertices, faces, nil
+	bc.Stats.ProcessedFiles++
+	bc.Logger.Log(LogDebug, "  Successfully processed and optimized %s\n", filepath.Base(name))
 }
 
-// loadAllBuildingOutlines loads building outlines from GeoJSON
-func (bc *BuildingColorizer) loadAllBuildingOutlines() map[string]Polygon {
-	buildingOutlines := make(map[string]Polygon)
+// applyFaceBudget counts the faces of a building against TotalFaceBudget. If
+// they exceed the remaining budget, the building is simplified to the
+// remaining faces and no further files are processed.
+func (bc *BuildingColorizer) applyFaceBudget(name string, vertices []Vector3, faceGroups map[string]*OptimizedFaceGroup) {
+	total := 0
+	for _, group := range faceGroups {
+		total += len(group.Faces)
+	}
 
-	data, err := ioutil.ReadFile(bc.GeoJSONPath)
-	if err != nil {
-		fmt.Printf("Error loading GeoJSON: %v\n", err)
-		return buildingOutlines
+	remaining := bc.TotalFaceBudget - bc.budgetFaces
+	if total <= remaining {
+		bc.budgetFaces += total
+		return
 	}
 
-	var geoJSON GeoJSON
-	if err := json.Unmarshal(data, &geoJSON); err != nil {
-		fmt.Printf("Error parsing GeoJSON: %v\n", err)
-		return buildingOutlines
+	bc.Logger.Log(LogWarn, "[WARN] %s has %d faces but only %d remain in the face budget of %d; simplifying it and skipping the remaining files\n",
+		name, total, remaining, bc.TotalFaceBudget)
+	bc.SimplifyToFaceCount(vertices, faceGroups, remaining)
+	bc.Stats.BudgetDroppedFaces += total - remaining
+	bc.budgetFaces += remaining
+	bc.budgetExhausted = true
+}
+
+// recordHausdorffDistances stores in VertexOptimization the directed and
+// symmetric Hausdorff distances between each group's vertices before
+// simplification and the vertices it is written with
+func (bc *BuildingColorizer) recordHausdorffDistances(unsimplified map[string][]Vector3, faceGroups map[string]*OptimizedFaceGroup) {
+	for material, group := range faceGroups {
+		stats, exists := bc.Stats.VertexOptimization[material]
+		if !exists {
+			continue
+		}
+		stats.HausdorffDistance = bc.MeshAnalyzer.ComputeHausdorffDistance(unsimplified[material], group.OptimizedVertices)
+		stats.SymmetricHausdorff = bc.MeshAnalyzer.ComputeSymmetricHausdorffDistance(unsimplified[material], group.OptimizedVertices)
+		bc.Stats.VertexOptimization[material] = stats
+	}
+}
+
+// SimplifyToFaceCount reduces the face groups of a building to at most
+// maxFaces faces in total by dropping the faces with the smallest area.
+// vertices are the building's vertices the faces index into. Kept faces stay
+// in their order and each group keeps only the vertices its faces use.
+func (bc *BuildingColorizer) SimplifyToFaceCount(vertices []Vector3, faceGroups map[string]*OptimizedFaceGroup, maxFaces int) {
+	type candidate struct {
+		material string
+		index    int
+		area     float64
+	}
+
+	materials := make([]string, 0, len(faceGroups))
+	for material := range faceGroups {
+		materials = append(materials, material)
 	}
+	sort.Strings(materials)
 
-	for _, feature := range geoJSON.Features {
-		if feature.Geometry.Type == "Polygon" || feature.Geometry.Type == "MultiPolygon" {
-			// Simplified polygon handling
-			key := fmt.Sprintf("polygon_%d", len(buildingOutlines))
-			buildingOutlines[key] = Polygon{}
+	var candidates []candidate
+	for _, material := range materials {
+		for i, face := range faceGroups[material].Faces {
+			candidates = append(candidates, candidate{material, i, bc.MeshAnalyzer.ComputeFaceArea(vertices, face)})
 		}
 	}
+	if len(candidates) <= maxFaces {
+		return
+	}
 
-	fmt.Printf("Loaded %d valid building outlines\n", len(buildingOutlines))
-	return buildingOutlines
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].area > candidates[j].area })
+	kept := make(map[string][]int)
+	for _, c := range candidates[:maxFaces] {
+		kept[c.material] = append(kept[c.material], c.index)
+	}
+
+	for _, material := range materials {
+		indices := kept[material]
+		sort.Ints(indices)
+		faceGroups[material] = bc.subGroup(faceGroups[material], indices)
+	}
 }
 
-// ProcessMesh processes mesh data and creates optimized face groups
-func (bc *BuildingColorizer) ProcessMesh(vertices []Vector3, faces []Face) (map[string]*OptimizedFaceGroup, float64) {
-	// Find ground level using distribution analysis
-	zValues := make([]float64, len(vertices))
-	for i, v := range vertices {
-		zValues[i] = v.Z
+// ValidateOutputFiles checks that every successfully processed input produced
+// one split file per non-empty material group (per material group with
+// EmitEmptyGroups) and reports any missing file
+func (bc *BuildingColorizer) ValidateOutputFiles() {
+	var inputs []string
+	for objPath := range bc.ExpectedSplitFiles {
+		inputs = append(inputs, objPath)
 	}
-	groundHeight := bc.MeshAnalyzer.AnalyzeZDistribution(zValues)
+	sort.Strings(inputs)
 
-	// Initialize face groups with vertex tracking
-	faceGroups := make(map[string]*OptimizedFaceGroup)
-	for material := range Colors {
-		faceGroups[material] = &OptimizedFaceGroup{
-			Material:      material,
-			Faces:         []Face{},
-			VertexMapping: make(map[int]int),
+	for _, objPath := range inputs {
+		for _, name := range bc.ExpectedSplitFiles[objPath] {
+			if _, err := os.Stat(filepath.Join(bc.OutputDir, name)); err != nil {
+				bc.Logger.Log(LogWarn, "MISSING: %s\n", name)
+				bc.Stats.FilesIntegrityErrors++
+			}
 		}
 	}
+}
 
-	// Track which vertices are used by each material
-	usedVertices := make(map[string]map[int]bool)
-	for material := range Colors {
-		usedVertices[material] = make(map[int]bool)
+// facePlane describes the supporting plane and bounding box of a face
+type facePlane struct {
+	Normal   Vector3
+	Offset   float64
+	Min, Max Vector3
+}
+
+// groupFacePlane computes the plane of a group face stored with original vertex indices
+func (bc *BuildingColorizer) groupFacePlane(group *OptimizedFaceGroup, face Face) facePlane {
+	remapped := make(Face, len(face))
+	for i, idx := range face {
+		remapped[i] = group.VertexMapping[idx]
 	}
 
-	// Process each face and group by material
-	for _, face := range faces {
-		material := bc.classifyFaceWithContext(vertices, face, groundHeight, []int{})
+	normal := bc.GeometryValidator.GetFaceNormal(group.OptimizedVertices, remapped)
+	first := group.OptimizedVertices[remapped[0]]
+	plane := facePlane{
+		Normal: normal,
+		Offset: normal.X*first.X + normal.Y*first.Y + normal.Z*first.Z,
+		Min:    first,
+		Max:    first,
+	}
+	for _, idx := range remapped[1:] {
+		v := group.OptimizedVertices[idx]
+		plane.Min = Vector3{math.Min(plane.Min.X, v.X), math.Min(plane.Min.Y, v.Y), math.Min(plane.Min.Z, v.Z)}
+		plane.Max = Vector3{math.Max(plane.Max.X, v.X), math.Max(plane.Max.Y, v.Y), math.Max(plane.Max.Z, v.Z)}
+	}
+	return plane
+}
 
-		if group, exists := faceGroups[material]; exists {
-			group.Faces = append(group.Faces, face)
-			// Track which vertices are used by this material
-			for _, vertexIdx := range face {
-				usedVertices[material][vertexIdx] = true
+// ClusterRoofPlanes groups the faces of a roof group into planar segments by
+// agglomerative clustering of their normals: starting from one cluster per
+// face, the two clusters with the smallest angle between their area-weighted
+// normals are merged until no pair is within RoofClusterAngle. Faces without
+// area join the first plane. Planes are ordered by their first face.
+//
+// Faces whose normals agree to within roofNormalBucket start in one cluster,
+// since they would be merged first anyway; a dense planar roof therefore
+// starts with a handful of clusters. Each cluster then caches its nearest
+// neighbour, and a heap of these pairs yields the next merge, so a merge only
+// rescans clusters whose neighbour moved away rather than every pair.
+func (bc *BuildingColorizer) ClusterRoofPlanes(group *OptimizedFaceGroup) []RoofPlane {
+	type cluster struct {
+		faces    []int
+		normal   Vector3 // Sum of face area vectors
+		area     float64 // Sum of face areas
+		unit     Vector3 // normal scaled to unit length
+		centroid Vector3 // Sum of face centroids weighted by area
+		nearest  int     // Index of the cluster with the closest normal, -1 if none
+		cos      float64 // Cosine of the angle to nearest
+		version  int     // Bumped whenever nearest changes, to discard stale heap entries
+		merged   bool    // Absorbed into another cluster
+	}
+
+	length := func(v Vector3) float64 { return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z) }
+	unit := func(v Vector3) Vector3 {
+		l := length(v)
+		return Vector3{v.X / l, v.Y / l, v.Z / l}
+	}
+	dot := func(a, b Vector3) float64 { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+
+	var clusters []*cluster
+	var degenerate []int
+	buckets := make(map[[3]int64]*cluster)
+	for i, face := range group.Faces {
+		remapped := make(Face, len(face))
+		for j, idx := range face {
+			remapped[j] = group.VertexMapping[idx]
+		}
+		area := bc.MeshAnalyzer.ComputeFaceArea(group.OptimizedVertices, remapped)
+		if area == 0 {
+			degenerate = append(degenerate, i)
+			continue
+		}
+		normal := bc.GeometryValidator.GetFaceNormal(group.OptimizedVertices, remapped)
+		centroid := bc.MeshAnalyzer.GetFaceCentroid(group.OptimizedVertices, remapped)
+		key := [3]int64{
+			int64(math.Round(normal.X / roofNormalBucket)),
+			int64(math.Round(normal.Y / roofNormalBucket)),
+			int64(math.Round(normal.Z / roofNormalBucket)),
+		}
+		c, exists := buckets[key]
+		if !exists {
+			c = &cluster{nearest: -1}
+			buckets[key] = c
+			clusters = append(clusters, c)
+		}
+		c.faces = append(c.faces, i)
+		c.normal = Vector3{c.normal.X + normal.X*area, c.normal.Y + normal.Y*area, c.normal.Z + normal.Z*area}
+		c.area += area
+		c.centroid = Vector3{c.centroid.X + centroid.X*area, c.centroid.Y + centroid.Y*area, c.centroid.Z + centroid.Z*area}
+	}
+	for _, c := range clusters {
+		c.unit = unit(c.normal)
+	}
+
+	// live holds the indices of the clusters not merged away, in face order
+	live := make([]int, len(clusters))
+	for i := range live {
+		live[i] = i
+	}
+	pairs := &roofPairHeap{}
+	findNearest := func(a int) {
+		c := clusters[a]
+		c.nearest, c.cos = -1, math.Inf(-1)
+		for _, b := range live {
+			if b == a {
+				continue
 			}
+			if cos := dot(c.unit, clusters[b].unit); cos > c.cos {
+				c.nearest, c.cos = b, cos
+			}
+		}
+		c.version++
+		if c.nearest >= 0 {
+			heap.Push(pairs, roofPair{a, c.nearest, c.cos, c.version})
 		}
 	}
+	for _, a := range live {
+		findNearest(a)
+	}
 
-	// Optimize vertices for each material group
-	for material, group := range faceGroups {
-		bc.optimizeVerticesForGroup(vertices, group, usedVertices[material])
+	minCos := math.Cos(bc.RoofClusterAngle * math.Pi / 180)
+	for pairs.Len() > 0 {
+		pair := heap.Pop(pairs).(roofPair)
+		a, b := clusters[pair.a], clusters[pair.b]
+		if a.merged || b.merged || pair.version != a.version {
+			continue
+		}
+		if pair.cos < minCos {
+			break
+		}
 
-		// Record optimization statistics
-		originalCount := len(vertices)
-		optimizedCount := len(group.OptimizedVertices)
-		reductionPercent := 0.0
-		if originalCount > 0 {
-			reductionPercent = float64(originalCount-optimizedCount) / float64(originalCount) * 100
+		// Merge b into a
+		a.faces = append(a.faces, b.faces...)
+		a.normal = Vector3{a.normal.X + b.normal.X, a.normal.Y + b.normal.Y, a.normal.Z + b.normal.Z}
+		a.unit = unit(a.normal)
+		a.area += b.area
+		a.centroid = Vector3{a.centroid.X + b.centroid.X, a.centroid.Y + b.centroid.Y, a.centroid.Z + b.centroid.Z}
+		b.merged = true
+		for i, idx := range live {
+			if idx == pair.b {
+				live = append(live[:i], live[i+1:]...)
+				break
+			}
 		}
 
-		bc.Stats.VertexOptimization[material] = VertexStats{
-			OriginalVertices:  originalCount,
-			OptimizedVertices: optimizedCount,
-			ReductionPercent:  reductionPercent,
+		// Only clusters that pointed at b, or at a if it moved away from
+		// them, need a full rescan; the others keep their neighbour unless
+		// the merged cluster is now closer
+		findNearest(pair.a)
+		for _, idx := range live {
+			c := clusters[idx]
+			switch {
+			case idx == pair.a:
+			case c.nearest == pair.b || (c.nearest == pair.a && dot(c.unit, a.unit) < c.cos):
+				findNearest(idx)
+			default:
+				if cos := dot(c.unit, a.unit); cos > c.cos || c.nearest == pair.a {
+					c.nearest, c.cos = pair.a, cos
+					c.version++
+					heap.Push(pairs, roofPair{idx, pair.a, cos, c.version})
+				}
+			}
 		}
 	}
 
-	return faceGroups, groundHeight
+	if len(live) == 0 && len(degenerate) > 0 {
+		return []RoofPlane{{Faces: degenerate, Normal: Vector3{0, 0, 1}}}
+	}
+
+	planes := make([]RoofPlane, len(live))
+	for i, idx := range live {
+		c := clusters[idx]
+		sort.Ints(c.faces)
+		planes[i] = RoofPlane{
+			Faces:    c.faces,
+			Normal:   unit(c.normal),
+			Centroid: Vector3{c.centroid.X / c.area, c.centroid.Y / c.area, c.centroid.Z / c.area},
+		}
+	}
+	sort.Slice(planes, func(i, j int) bool { return planes[i].Faces[0] < planes[j].Faces[0] })
+	if len(planes) > 0 && len(degenerate) > 0 {
+		planes[0].Faces = append(planes[0].Faces, degenerate...)
+		sort.Ints(planes[0].Faces)
+	}
+	return planes
 }
 
-// optimizeVerticesForGroup creates optimized vertex list and mapping for a material group
-func (bc *BuildingColorizer) optimizeVerticesForGroup(allVertices []Vector3, group *OptimizedFaceGroup, usedVertexIndices map[int]bool) {
-	if len(usedVertexIndices) == 0 {
-		return
+// roofNormalBucket is the unit normal component step below which
+// ClusterRoofPlanes treats face normals as equal
+const roofNormalBucket = 1e-6
+
+// roofPair is a ClusterRoofPlanes candidate merge of cluster a with its
+// nearest cluster b, valid while a's version is unchanged
+type roofPair struct {
+	a, b    int
+	cos     float64 // Cosine of the angle between the cluster normals
+	version int
+}
+
+// roofPairHeap is a max-heap of roofPairs by cosine, so the pair with the
+// smallest angle is popped first
+type roofPairHeap []roofPair
+
+func (h roofPairHeap) Len() int { return len(h) }
+func (h roofPairHeap) Less(i, j int) bool {
+	if h[i].cos != h[j].cos {
+		return h[i].cos > h[j].cos
 	}
+	// Break ties by cluster index so the result does not depend on heap order
+	if h[i].a != h[j].a {
+		return h[i].a < h[j].a
+	}
+	return h[i].b < h[j].b
+}
+func (h roofPairHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *roofPairHeap) Push(x interface{}) { *h = append(*h, x.(roofPair)) }
+func (h *roofPairHeap) Pop() interface{} {
+	old := *h
+	pair := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return pair
+}
 
-	// Create sorted list of used vertex indices for consistent ordering
-	var sortedIndices []int
-	for idx := range usedVertexIndices {
-		sortedIndices = append(sortedIndices, idx)
+// extentsOverlap reports whether two bounding boxes overlap within epsilon
+func extentsOverlap(minA, maxA, minB, maxB Vector3, epsilon float64) bool {
+	return minA.X <= maxB.X+epsilon && minB.X <= maxA.X+epsilon &&
+		minA.Y <= maxB.Y+epsilon && minB.Y <= maxA.Y+epsilon &&
+		minA.Z <= maxB.Z+epsilon && minB.Z <= maxA.Z+epsilon
+}
+
+// extentsShareArea reports whether two coplanar faces' bounding boxes overlap
+// by more than epsilon along at least two axes, so faces that merely touch
+// along an edge are not considered overlapping
+func extentsShareArea(minA, maxA, minB, maxB Vector3, epsilon float64) bool {
+	overlaps := []float64{
+		math.Min(maxA.X, maxB.X) - math.Max(minA.X, minB.X),
+		math.Min(maxA.Y, maxB.Y) - math.Max(minA.Y, minB.Y),
+		math.Min(maxA.Z, maxB.Z) - math.Max(minA.Z, minB.Z),
 	}
-	sort.Ints(sortedIndices)
 
-	// Create optimized vertex list and mapping
-	group.OptimizedVertices = make([]Vector3, len(sortedIndices))
-	newIndex := 0
+	axes := 0
+	for _, overlap := range overlaps {
+		if overlap < -epsilon {
+			return false
+		}
+		if overlap > epsilon {
+			axes++
+		}
+	}
+	return axes >= 2
+}
 
-	for _, oldIndex := range sortedIndices {
-		group.OptimizedVertices[newIndex] = allVertices[oldIndex]
-		group.VertexMapping[oldIndex] = newIndex
-		newIndex++
+// DetectSharedWalls returns the faces of buildingA whose supporting plane
+// (normal and offset) lies within epsilon of a face of buildingB and whose
+// extents overlap it. Opposite-facing planes are treated as the same plane,
+// since adjoining walls usually face away from each other.
+func (bc *BuildingColorizer) DetectSharedWalls(buildingA, buildingB *OptimizedFaceGroup, epsilon float64) []Face {
+	planesB := make([]facePlane, len(buildingB.Faces))
+	minB, maxB := Vector3{math.Inf(1), math.Inf(1), math.Inf(1)}, Vector3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i, face := range buildingB.Faces {
+		planesB[i] = bc.groupFacePlane(buildingB, face)
+		minB = Vector3{math.Min(minB.X, planesB[i].Min.X), math.Min(minB.Y, planesB[i].Min.Y), math.Min(minB.Z, planesB[i].Min.Z)}
+		maxB = Vector3{math.Max(maxB.X, planesB[i].Max.X), math.Max(maxB.Y, planesB[i].Max.Y), math.Max(maxB.Z, planesB[i].Max.Z)}
 	}
 
-	if bc.Debug {
-		fmt.Printf("    %s: Optimized from %d to %d vertices (%.1f%% reduction)\n",
-			group.Material, len(allVertices), len(group.OptimizedVertices),
-			float64(len(allVertices)-len(group.OptimizedVertices))/float64(len(allVertices))*100)
+	var shared []Face
+	for _, face := range buildingA.Faces {
+		planeA := bc.groupFacePlane(buildingA, face)
+		if !extentsOverlap(planeA.Min, planeA.Max, minB, maxB, epsilon) {
+			continue
+		}
+
+		for _, planeB := range planesB {
+			dot := planeA.Normal.X*planeB.Normal.X + planeA.Normal.Y*planeB.Normal.Y + planeA.Normal.Z*planeB.Normal.Z
+			if math.Abs(math.Abs(dot)-1) > epsilon {
+				continue
+			}
+			offsetB := planeB.Offset
+			if dot < 0 {
+				offsetB = -offsetB
+			}
+			if math.Abs(planeA.Offset-offsetB) > epsilon {
+				continue
+			}
+			if extentsShareArea(planeA.Min, planeA.Max, planeB.Min, planeB.Max, epsilon) {
+				shared = append(shared, face)
+				break
+			}
+		}
 	}
+
+	return shared
 }
 
-// classifyFaceWithContext classifies face considering neighboring geometry
-func (bc *BuildingColorizer) classifyFaceWithContext(vertices []Vector3, face Face, groundHeight float64, neighbors []int) string {
-	// Get face properties
-	normal := bc.GeometryValidator.GetFaceNormal(vertices, face)
+// DetectAllSharedWalls compares the wall groups of every pair of processed
+// buildings, records shared walls in Statistics and writes each building's
+// shared faces to a separate *-shared split file. Wall groups are only recorded
+// with MarkSharedWalls.
+func (bc *BuildingColorizer) DetectAllSharedWalls() {
+	var names []string
+	for name := range bc.wallGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	// Basic classification
-	var baseClass string
-	if bc.GeometryValidator.ValidateGroundClassification(vertices, face, groundHeight) {
-		baseClass = "Ground"
-	} else if math.Abs(normal.Z) < 0.1 { // Nearly vertical
-		baseClass = "Wall"
-	} else {
-		baseClass = "Roof"
+	sharedFaces := make(map[string][]Face)
+	for i := 0; i < len(names); i++ {
+		for j := i + 1; j < len(names); j++ {
+			groupA, groupB := bc.wallGroups[names[i]], bc.wallGroups[names[j]]
+			sharedA := bc.DetectSharedWalls(groupA, groupB, bc.SharedWallEpsilon)
+			sharedB := bc.DetectSharedWalls(groupB, groupA, bc.SharedWallEpsilon)
+			if len(sharedA) == 0 && len(sharedB) == 0 {
+				continue
+			}
+
+			bc.Stats.SharedWallPairs++
+			sharedFaces[names[i]] = append(sharedFaces[names[i]], sharedA...)
+			sharedFaces[names[j]] = append(sharedFaces[names[j]], sharedB...)
+			bc.Logger.Log(LogDebug, "  Shared wall: %s (%d faces) <-> %s (%d faces)\n", names[i], len(sharedA), names[j], len(sharedB))
+		}
 	}
 
-	return baseClass
+	for _, name := range names {
+		faces := uniqueFaces(sharedFaces[name])
+		if len(faces) == 0 {
+			continue
+		}
+		bc.Stats.SharedWalls += len(faces)
+
+		if !bc.SummaryOnly {
+			if err := bc.writeSharedWallFile(name, faces); err != nil {
+				bc.Logger.Log(LogError, "  Failed to write shared walls for %s: %v\n", name, err)
+			}
+		}
+	}
 }
 
-// CreateSeparateObjFiles creates separate optimized OBJ files for each material
-func (bc *BuildingColorizer) CreateSeparateObjFiles(objPath string, faceGroups map[string]*OptimizedFaceGroup) error {
-	baseName := strings.TrimSuffix(filepath.Base(objPath), ".obj")
+// DetectAllDuplicateFaces compares the meshes of every pair of processed
+// buildings with overlapping extents and records near-duplicate faces
+func (bc *BuildingColorizer) DetectAllDuplicateFaces() {
+	var names []string
+	for name := range bc.meshes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	for material, group := range faceGroups {
-		if len(group.Faces) == 0 {
-			if bc.Debug {
-				fmt.Printf("  Skipping %s (no faces)\n", material)
+	minB := make([]Vector3, len(names))
+	maxB := make([]Vector3, len(names))
+	for i, name := range names {
+		minB[i], maxB[i] = Vector3{math.Inf(1), math.Inf(1), math.Inf(1)}, Vector3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+		for _, v := range bc.meshes[name].Vertices {
+			minB[i] = Vector3{math.Min(minB[i].X, v.X), math.Min(minB[i].Y, v.Y), math.Min(minB[i].Z, v.Z)}
+			maxB[i] = Vector3{math.Max(maxB[i].X, v.X), math.Max(maxB[i].Y, v.Y), math.Max(maxB[i].Z, v.Z)}
+		}
+	}
+
+	for i := 0; i < len(names); i++ {
+		for j := i + 1; j < len(names); j++ {
+			if !extentsOverlap(minB[i], maxB[i], minB[j], maxB[j], bc.DuplicateDistance) {
+				continue
+			}
+
+			meshA, meshB := bc.meshes[names[i]], bc.meshes[names[j]]
+			pairs := bc.MeshAnalyzer.FindNearDuplicateFaces(meshA.Faces, meshB.Faces, meshA.Vertices, meshB.Vertices, bc.DuplicateDistance)
+			if len(pairs) == 0 {
+				continue
 			}
-			continue // Skip materials with no faces
+
+			bc.Stats.DuplicateFaces += len(pairs)
+			bc.Logger.Log(LogInfo, "  Near-duplicate faces: %s <-> %s (%d pairs)\n", names[i], names[j], len(pairs))
+			if bc.Logger.Enabled(LogDebug) {
+				for _, pair := range pairs {
+					fmt.Printf("    face %d <-> face %d\n", pair[0], pair[1])
+				}
+			}
+		}
+	}
+}
+
+// uniqueFaces removes duplicate faces, keeping the first occurrence
+func uniqueFaces(faces []Face) []Face {
+	seen := make(map[string]bool)
+	var unique []Face
+	for _, face := range faces {
+		key := fmt.Sprint([]int(face))
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, face)
 		}
+	}
+	return unique
+}
 
-		// Create filename with material suffix
-		var suffix string
-		switch material {
-		case "Ground":
-			suffix = "-ground"
-		case "Wall":
-			suffix = "-wall"
-		case "Roof":
-			suffix = "-roof"
+// writeSharedWallFile writes the shared wall faces of a building to
+// baseName-shared in OutputFormat, like the other split files
+func (bc *BuildingColorizer) writeSharedWallFile(baseName string, faces []Face) error {
+	wall := bc.wallGroups[baseName]
+
+	// Rebuild an optimized group over the wall's vertices for just the shared faces
+	group := &OptimizedFaceGroup{
+		Material:      "Wall",
+		VertexMapping: make(map[int]int),
+	}
+	used := make(map[int]bool)
+	for _, face := range faces {
+		remapped := make(Face, len(face))
+		for i, idx := range face {
+			remapped[i] = wall.VertexMapping[idx]
+			used[remapped[i]] = true
 		}
+		group.Faces = append(group.Faces, remapped)
+	}
+	bc.optimizeVerticesForGroup(wall.OptimizedVertices, wall.OptimizedUVs, group, used)
 
-		outputPath := filepath.Join(bc.OutputDir, baseName+suffix+".obj")
-		mtlPath := baseName + suffix + ".mtl"
+	outputDir := bc.materialOutputDir("Wall")
+	outputPath := filepath.Join(outputDir, baseName+"-shared"+bc.splitFileExt())
+	mtlPath := baseName + "-shared.mtl"
 
-		// Create optimized OBJ file
-		if err := bc.createOptimizedObjFile(outputPath, mtlPath, group); err != nil {
-			return fmt.Errorf("failed to create %s: %v", outputPath, err)
+	materialName := "Wall"
+	if bc.PrefixMaterialName {
+		materialName = baseName + "_Wall"
+	}
+
+	if err := bc.createSplitFile(outputPath, mtlPath, "Wall", materialName, group); err != nil {
+		return err
+	}
+	if bc.OutputFormat == OutputFormatGLTF {
+		return nil
+	}
+	return bc.createMtlFile(filepath.Join(outputDir, mtlPath), "Wall", materialName)
+}
+
+// BuiltInMaterials are the materials assigned by the built-in classifier.
+// Colors loaded from a --colors file always include them.
+var BuiltInMaterials = []string{"Roof", "Wall", "Ground"}
+
+// Materials returns the materials of Colors: BuiltInMaterials first, then the
+// others by name. The position of a material is its material_index in the
+// face attribute export.
+func (bc *BuildingColorizer) Materials() []string {
+	var materials, others []string
+	builtIn := make(map[string]bool)
+	for _, material := range BuiltInMaterials {
+		builtIn[material] = true
+		if _, ok := bc.Colors[material]; ok {
+			materials = append(materials, material)
+		}
+	}
+	for material := range bc.Colors {
+		if !builtIn[material] {
+			others = append(others, material)
 		}
+	}
+	sort.Strings(others)
+	return append(materials, others...)
+}
+
+// HeightFeature is one row of the height feature CSV export
+type HeightFeature struct {
+	Building    string
+	VertexIndex int
+	Position    Vector3
+	Height      float64 // Normalised height above ground in [0,1]
+}
+
+// FaceAttribute is one record of the binary face attribute export
+type FaceAttribute struct {
+	MaterialIndex uint8
+	Centroid      [3]float32
+	Normal        [3]float32
+}
 
-		// Create MTL file
-		if err := bc.createMtlFile(filepath.Join(bc.OutputDir, mtlPath), material); err != nil {
-			return fmt.Errorf("failed to create %s: %v", mtlPath, err)
+// faceAttrMaterialIndices returns the material_index of every material that
+// fits in the uint8 of a face attribute record
+func (bc *BuildingColorizer) faceAttrMaterialIndices() map[string]uint8 {
+	indices := make(map[string]uint8)
+	for i, material := range bc.Materials() {
+		if i > math.MaxUint8 {
+			break
 		}
+		indices[material] = uint8(i)
+	}
+	return indices
+}
 
-		bc.Stats.SplitFiles[material]++
-		if bc.Debug {
-			fmt.Printf("  Created %s with %d vertices and %d faces\n",
-				filepath.Base(outputPath), len(group.OptimizedVertices), len(group.Faces))
+// recordFaceAttribute appends the centroid and normal of a face classified as
+// the material with the given material_index to the face attribute export.
+// Both are obfuscated like the output vertices.
+func (bc *BuildingColorizer) recordFaceAttribute(vertices []Vector3, face Face, index uint8) {
+	centroid := bc.MeshAnalyzer.GetFaceCentroid(vertices, face)
+	normal := bc.GeometryValidator.GetFaceNormal(vertices, face)
+	if bc.Obfuscation != nil {
+		centroid = bc.Obfuscation.Apply(centroid)
+		normal = bc.Obfuscation.ApplyDirection(normal)
+	}
+	bc.faceAttrs = append(bc.faceAttrs, FaceAttribute{
+		MaterialIndex: index,
+		Centroid:      [3]float32{float32(centroid.X), float32(centroid.Y), float32(centroid.Z)},
+		Normal:        [3]float32{float32(normal.X), float32(normal.Y), float32(normal.Z)},
+	})
+}
+
+// recordHeightFeatures appends the normalised height above groundHeight of
+// every vertex to the height feature export, with the vertex position
+// obfuscated like the output vertices
+func (bc *BuildingColorizer) recordHeightFeatures(building string, vertices []Vector3, groundHeight float64) {
+	maxHeight := math.Inf(-1)
+	for _, v := range vertices {
+		maxHeight = math.Max(maxHeight, v.Z)
+	}
+
+	heights := bc.MeshAnalyzer.ComputeNormalisedHeightAboveGround(vertices, groundHeight, maxHeight)
+	for i, v := range vertices {
+		// The transform only rotates about Z and shifts, so heights are unchanged
+		if bc.Obfuscation != nil {
+			v = bc.Obfuscation.Apply(v)
+		}
+		bc.heightFeatures = append(bc.heightFeatures, HeightFeature{building, i, v, heights[i]})
+	}
+}
+
+// WriteExports writes the recorded face attributes to faceAttrsPath and the
+// height features to heightFeaturesPath, skipping empty paths. Nothing is
+// written in SummaryOnly (and so DryRun) mode.
+func (bc *BuildingColorizer) WriteExports(faceAttrsPath, heightFeaturesPath string) error {
+	if bc.SummaryOnly {
+		return nil
+	}
+	if faceAttrsPath != "" {
+		if err := bc.ExportFaceAttributes(faceAttrsPath); err != nil {
+			return fmt.Errorf("exporting face attributes: %v", err)
 		}
 	}
+	if heightFeaturesPath != "" {
+		if err := bc.ExportHeightFeatures(heightFeaturesPath); err != nil {
+			return fmt.Errorf("exporting height features: %v", err)
+		}
+	}
+	return nil
+}
+
+// ExportHeightFeatures writes the recorded height features to a CSV file with
+// building, vertex_index, x, y, z and height_above_ground columns
+func (bc *BuildingColorizer) ExportHeightFeatures(csvPath string) error {
+	file, err := os.Create(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to create height feature CSV: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"building", "vertex_index", "x", "y", "z", "height_above_ground"})
+	for _, f := range bc.heightFeatures {
+		writer.Write([]string{
+			f.Building,
+			strconv.Itoa(f.VertexIndex),
+			strconv.FormatFloat(f.Position.X, 'f', 6, 64),
+			strconv.FormatFloat(f.Position.Y, 'f', 6, 64),
+			strconv.FormatFloat(f.Position.Z, 'f', 6, 64),
+			strconv.FormatFloat(f.Height, 'f', 4, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write height feature CSV: %v", err)
+	}
 
+	bc.Logger.Log(LogInfo, "Exported height features of %d vertices to %s\n", len(bc.heightFeatures), csvPath)
 	return nil
 }
 
-// createOptimizedObjFile creates an individual optimized OBJ file for a specific material
-func (bc *BuildingColorizer) createOptimizedObjFile(objPath, mtlPath string, group *OptimizedFaceGroup) error {
-	file, err := os.Create(objPath)
+// ExportFaceAttributes writes the recorded face attributes to path as
+// little-endian records of [material_index uint8, centroid float32 x3, normal float32 x3]
+func (bc *BuildingColorizer) ExportFaceAttributes(path string) error {
+	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
 	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+	for _, attr := range bc.faceAttrs {
+		if err := binary.Write(writer, binary.LittleEndian, attr); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
 
-	// Write header
-	writer.WriteString(fmt.Sprintf("# Generated by Building Colorizer v%s - %s (Optimized)\n", Version, group.Material))
-	writer.WriteString(fmt.Sprintf("# Vertices: %d, Faces: %d\n", len(group.OptimizedVertices), len(group.Faces)))
-	writer.WriteString(fmt.Sprintf("mtllib %s\n", mtlPath))
-	writer.WriteString("\n")
+	bc.Logger.Log(LogInfo, "Exported attributes of %d faces to %s\n", len(bc.faceAttrs), path)
+	return nil
+}
 
-	// Write optimized vertices
-	for _, vertex := range group.OptimizedVertices {
-		writer.WriteString(fmt.Sprintf("v %.6f %.6f %.6f\n", vertex.X, vertex.Y, vertex.Z))
+// ReadFaceAttributes reads a file written by ExportFaceAttributes
+func ReadFaceAttributes(path string) ([]FaceAttribute, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	writer.WriteString("\n")
+	defer file.Close()
 
-	// Write material usage and faces with remapped indices
-	writer.WriteString(fmt.Sprintf("usemtl %s\n", group.Material))
-	for _, face := range group.Faces {
-		writer.WriteString("f")
-		for _, oldIdx := range face {
-			newIdx := group.VertexMapping[oldIdx] + 1 // OBJ indices start at 1
-			writer.WriteString(fmt.Sprintf(" %d", newIdx))
+	reader := bufio.NewReader(file)
+	var attrs []FaceAttribute
+	for {
+		var attr FaceAttribute
+		err := binary.Read(reader, binary.LittleEndian, &attr)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("truncated face attribute record %d: %v", len(attrs), err)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// ProcessAllBuildings processes all buildings in directory
+func (bc *BuildingColorizer) ProcessAllBuildings(ctx context.Context) {
+	// Ensure output directory exists
+	if !bc.SummaryOnly {
+		if err := os.MkdirAll(bc.OutputDir, 0755); err != nil {
+			log.Fatalf("Error creating output directory: %v", err)
 		}
-		writer.WriteString("\n")
+	}
+	if bc.SkipExisting {
+		bc.loadSplitIndex()
 	}
 
-	return nil
-}
-
-// createMtlFile creates a material file for a specific material
-func (bc *BuildingColorizer) createMtlFile(mtlPath, material string) error {
-	file, err := os.Create(mtlPath)
+	pattern := filepath.Join(bc.ObjDir, "*.obj")
+	matches, err := filepath.Glob(pattern)
 	if err != nil {
-		return err
+		log.Fatalf("Error finding OBJ files: %v", err)
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+	if len(matches) == 0 {
+		bc.Logger.Log(LogWarn, "No OBJ files found in directory: %s\n", bc.ObjDir)
+		return
+	}
 
-	color := Colors[material]
+	bc.Logger.Log(LogInfo, "Found %d OBJ files to process\n", len(matches))
+	if bc.DryRun {
+		bc.Logger.Log(LogInfo, "Dry run: no output files will be written to %s\n", bc.OutputDir)
+	} else if bc.SummaryOnly {
+		bc.Logger.Log(LogInfo, "Summary-only mode: no output files will be written\n")
+	} else {
+		bc.Logger.Log(LogInfo, "Output directory: %s\n", bc.OutputDir)
+	}
 
-	writer.WriteString(fmt.Sprintf("# Generated by Building Colorizer v%s - %s\n\n", Version, material))
-	writer.WriteString(fmt.Sprintf("newmtl %s\n", material))
-	writer.WriteString("Ka 0.000 0.000 0.000\n")
-	writer.WriteString(fmt.Sprintf("Kd %.6f %.6f %.6f\n", color.R, color.G, color.B))
-	writer.WriteString("Ks 0.000 0.000 0.000\n")
-	writer.WriteString(fmt.Sprintf("d %.6f\n", color.A))
-	writer.WriteString("illum 1\n")
+	// Debug output already reports every file, so the bar is only drawn without it
+	bar := progress.New(len(matches), !bc.Logger.Enabled(LogDebug))
+	bc.processInParallel(ctx, matches, bar)
+	bar.Finish()
 
-	return nil
+	bc.finishProcessing(ctx)
+	bc.PrintSummary()
 }
 
-// ProcessBuilding processes a single building and splits it into optimized separate files
-func (bc *BuildingColorizer) ProcessBuilding(objPath string) {
-	if bc.Debug {
-		fmt.Printf("\nProcessing: %s\n", filepath.Base(objPath))
+// processInParallel processes objPaths on Workers goroutines. Each file is
+// processed by its own copy of the colorizer, and the copies are merged back
+// into bc by this goroutine in input order, so Stats, FailedFiles and the
+// other recorded results match a sequential run. Once ctx is cancelled no
+// further files are started; the files in progress complete. The
+// bar advances as each file completes, in completion order.
+func (bc *BuildingColorizer) processInParallel(ctx context.Context, objPaths []string, bar *progress.Bar) {
+	if bc.TotalFaceBudget > 0 {
+		bc.processWithinBudget(ctx, objPaths, bar)
+		return
 	}
 
-	// Load mesh data
-	if bc.Debug {
-		fmt.Println("  Loading mesh data...")
+	workers := bc.Workers
+	if workers < 1 {
+		workers = 1
 	}
-	vertices, faces, err := bc.LoadObjFile(objPath)
-	if err != nil {
-		fmt.Printf("  Failed to load mesh data for %s: %v\n", filepath.Base(objPath), err)
-		bc.Stats.FailedFiles = append(bc.Stats.FailedFiles, FailedFile{filepath.Base(objPath), err.Error()})
-		return
+	if workers > len(objPaths) {
+		workers = len(objPaths)
 	}
 
-	if bc.Debug {
-		fmt.Printf("  Loaded %d vertices and %d faces\n", len(vertices), len(faces))
+	type fileResult struct {
+		index  int
+		worker *BuildingColorizer
 	}
 
-	// Process mesh and create optimized face groups
-	if bc.Debug {
-		fmt.Println("  Processing mesh and optimizing vertices...")
-	}
-	faceGroups, groundHeight := bc.ProcessMesh(vertices, faces)
-	if bc.Debug {
-		fmt.Printf("  Ground height detected: %.2f\n", groundHeight)
+	jobs := make(chan int)
+	results := make(chan fileResult)
+	bc.workerUsage = make([]WorkerUsage, workers)
+	start := time.Now()
+
+	// Workers copy the configuration from a snapshot, since bc itself is
+	// updated while they run
+	template := bc.fileWorker()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(usage *WorkerUsage) {
+			defer wg.Done()
+			for index := range jobs {
+				fileStart := time.Now()
+				worker := template.fileWorker()
+				worker.ProcessBuilding(ctx, objPaths[index])
+				usage.Files++
+				usage.Busy += time.Since(fileStart)
+				results <- fileResult{index, worker}
+			}
+		}(&bc.workerUsage[w])
 	}
 
-	// Print face and vertex distribution
-	for material, group := range faceGroups {
-		if len(group.Faces) > 0 {
-			if bc.Debug {
-				fmt.Printf("  %s: %d faces, %d vertices\n", material, len(group.Faces), len(group.OptimizedVertices))
+	dispatched := 0
+	go func() {
+	dispatch:
+		for index := range objPaths {
+			select {
+			case jobs <- index:
+				dispatched++
+			case <-ctx.Done():
+				break dispatch
 			}
 		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]*BuildingColorizer)
+	next := 0
+	for result := range results {
+		bar.Increment()
+		pending[result.index] = result.worker
+		for worker, ok := pending[next]; ok; worker, ok = pending[next] {
+			bc.mergeFileWorker(worker)
+			delete(pending, next)
+			next++
+		}
 	}
+	bc.interruptedFiles += len(objPaths) - dispatched
+	bc.poolElapsed = time.Since(start)
+}
 
-	// Create separate optimized OBJ files for each material
-	if bc.Debug {
-		fmt.Println("  Creating optimized OBJ files...")
+// processWithinBudget processes objPaths one at a time in input order, since
+// each building's share of TotalFaceBudget depends on the buildings before it,
+// and skips the files left once the budget is exhausted or ctx is cancelled
+func (bc *BuildingColorizer) processWithinBudget(ctx context.Context, objPaths []string, bar *progress.Bar) {
+	for i, objPath := range objPaths {
+		if ctx.Err() != nil {
+			bc.interruptedFiles += len(objPaths) - i
+			return
+		}
+		if bc.budgetExhausted {
+			bc.skipOverBudget(len(objPaths) - i)
+			return
+		}
+		worker := bc.fileWorker()
+		worker.ProcessBuilding(ctx, objPath)
+		bc.mergeFileWorker(worker)
+		bar.Increment()
+		bc.budgetFaces = worker.budgetFaces
+		bc.budgetExhausted = worker.budgetExhausted
 	}
-	if err := bc.CreateSeparateObjFiles(objPath, faceGroups); err != nil {
-		bc.Stats.FailedFiles = append(bc.Stats.FailedFiles, FailedFile{filepath.Base(objPath), fmt.Sprintf("File splitting failed: %v", err)})
-		return
+}
+
+// skipOverBudget records count input files left unprocessed by an exhausted TotalFaceBudget
+func (bc *BuildingColorizer) skipOverBudget(count int) {
+	bc.Stats.BudgetSkippedFiles += count
+	bc.Logger.Log(LogWarn, "[WARN] Face budget of %d exhausted; skipping %d remaining files\n", bc.TotalFaceBudget, count)
+}
+
+// Reset returns a new BuildingColorizer with the configuration of bc but none
+// of its results, for processing another independent batch in the same
+// process. Statistics are zeroed, the timer restarts and the building outlines
+// are reloaded from GeoJSONPath. ObjDir and OutputDir may be changed on the
+// result before processing the next batch; bc itself is left unchanged.
+func (bc *BuildingColorizer) Reset() *BuildingColorizer {
+	fresh := bc.fileWorker()
+	fresh.StartTime = time.Now()
+	fresh.poolElapsed = 0
+	fresh.budgetFaces = 0
+	fresh.budgetExhausted = false
+
+	// Copy the helpers so tuning one batch does not affect the other
+	analyzer := *bc.MeshAnalyzer
+	fresh.MeshAnalyzer = &analyzer
+	validator := *bc.GeometryValidator
+	fresh.GeometryValidator = &validator
+
+	fresh.BuildingOutlines = fresh.loadAllBuildingOutlines()
+	return fresh
+}
+
+// fileWorker returns a copy of bc that shares its configuration but records
+// statistics and per-building results separately, for processing one file
+func (bc *BuildingColorizer) fileWorker() *BuildingColorizer {
+	worker := *bc
+	worker.Stats = newStatistics()
+	worker.ClassificationCache = make(map[int]string)
+	worker.ExpectedSplitFiles = make(map[string][]string)
+	worker.wallGroups = make(map[string]*OptimizedFaceGroup)
+	worker.meshes = make(map[string]buildingMesh)
+	worker.faceAttrs = nil
+	worker.heightFeatures = nil
+	worker.repairLog = nil
+	worker.plannedFiles = nil
+	worker.manifest = nil
+	worker.texCoords = nil
+	worker.faceVertices = nil
+	worker.texCoordLines = nil
+	worker.normalLines = nil
+	worker.MaterialAssignment = nil
+	worker.workerUsage = nil
+	worker.interruptedFiles = 0
+	return &worker
+}
+
+// mergeFileWorker adds the statistics and per-building results recorded by a
+// fileWorker to bc
+func (bc *BuildingColorizer) mergeFileWorker(worker *BuildingColorizer) {
+	bc.Stats.add(worker.Stats)
+	for name, expected := range worker.ExpectedSplitFiles {
+		bc.ExpectedSplitFiles[name] = expected
+	}
+	for name, group := range worker.wallGroups {
+		bc.wallGroups[name] = group
+	}
+	for name, mesh := range worker.meshes {
+		bc.meshes[name] = mesh
 	}
+	bc.faceAttrs = append(bc.faceAttrs, worker.faceAttrs...)
+	bc.heightFeatures = append(bc.heightFeatures, worker.heightFeatures...)
+	bc.repairLog = append(bc.repairLog, worker.repairLog...)
+	bc.plannedFiles = append(bc.plannedFiles, worker.plannedFiles...)
+	bc.manifest = append(bc.manifest, worker.manifest...)
+	bc.interruptedFiles += worker.interruptedFiles
+}
 
-	bc.Stats.ProcessedFiles++
-	if bc.Debug {
-		fmt.Printf("  Successfully processed and optimized %s\n", filepath.Base(objPath))
+// finishProcessing runs the steps that compare buildings once every input
+// file has been processed and writes the run-level output files. A cancelled
+// run skips the comparisons and validation but still writes the output files
+// for the files processed so far.
+func (bc *BuildingColorizer) finishProcessing(ctx context.Context) {
+	if ctx.Err() == nil {
+		if bc.MarkSharedWalls {
+			bc.DetectAllSharedWalls()
+		}
+		if bc.DetectDuplicates {
+			bc.DetectAllDuplicateFaces()
+		}
+		if !bc.SummaryOnly {
+			bc.ValidateOutputFiles()
+		}
+	}
+	if !bc.SummaryOnly {
+		if err := bc.writeSplitIndex(); err != nil {
+			bc.Logger.Log(LogError, "Error: %v\n", err)
+		}
+		if bc.RepairLogPath != "" {
+			if err := bc.WriteRepairLog(bc.RepairLogPath); err != nil {
+				bc.Logger.Log(LogError, "Error: %v\n", err)
+			} else {
+				bc.Logger.Log(LogInfo, "Repair log with %d entries written to: %s\n", len(bc.repairLog), bc.RepairLogPath)
+			}
+		}
+		if bc.ManifestPath != "" {
+			if err := bc.WriteManifest(bc.ManifestPath); err != nil {
+				bc.Logger.Log(LogError, "Error: %v\n", err)
+			} else {
+				bc.Logger.Log(LogInfo, "Manifest with %d input files written to: %s\n", len(bc.manifest), bc.ManifestPath)
+			}
+		}
+		if bc.Obfuscation != nil {
+			transformPath := filepath.Join(bc.OutputDir, "obfuscation.transform.json")
+			if err := bc.Obfuscation.WriteJSON(transformPath); err != nil {
+				bc.Logger.Log(LogError, "Error: %v\n", err)
+			} else {
+				bc.Logger.Log(LogInfo, "Coordinate transform written to: %s\n", transformPath)
+			}
+		}
 	}
 }
 
-// ProcessAllBuildings processes all buildings in directory
-func (bc *BuildingColorizer) ProcessAllBuildings() {
-	// Ensure output directory exists
-	if err := os.MkdirAll(bc.OutputDir, 0755); err != nil {
-		log.Fatalf("Error creating output directory: %v", err)
+// ProcessDirectory processes the OBJ files in ObjDir on a worker goroutine and
+// sends one BuildingResult per file on the returned channel, which is closed
+// after the cross-building steps of ProcessAllBuildings have run. Cancelling
+// ctx stops the worker before the next file; the file in progress completes,
+// the cross-building steps are skipped and the run-level files are still
+// written. The colorizer must not be used
+// elsewhere until the channel is closed.
+func (bc *BuildingColorizer) ProcessDirectory(ctx context.Context) (<-chan BuildingResult, error) {
+	if !bc.SummaryOnly {
+		if err := os.MkdirAll(bc.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating output directory: %v", err)
+		}
+	}
+	if bc.SkipExisting {
+		bc.loadSplitIndex()
 	}
 
-	pattern := filepath.Join(bc.ObjDir, "*.obj")
-	matches, err := filepath.Glob(pattern)
+	matches, err := filepath.Glob(filepath.Join(bc.ObjDir, "*.obj"))
 	if err != nil {
-		log.Fatalf("Error finding OBJ files: %v", err)
+		return nil, fmt.Errorf("error finding OBJ files: %v", err)
 	}
 
-	if len(matches) == 0 {
-		fmt.Printf("No OBJ files found in directory: %s\n", bc.ObjDir)
-		return
-	}
+	results := make(chan BuildingResult)
+	go func() {
+		defer close(results)
+	files:
+		for i, objPath := range matches {
+			if ctx.Err() != nil {
+				break
+			}
+			if bc.budgetExhausted {
+				bc.skipOverBudget(len(matches) - i)
+				break
+			}
+			select {
+			case results <- bc.processBuildingResult(ctx, objPath):
+			case <-ctx.Done():
+				break files
+			}
+		}
+		bc.finishProcessing(ctx)
+	}()
 
-	fmt.Printf("Found %d OBJ files to process\n", len(matches))
-	fmt.Printf("Output directory: %s\n", bc.OutputDir)
+	return results, nil
+}
 
-	for _, objPath := range matches {
-		bc.ProcessBuilding(objPath)
-	}
+// processBuildingResult processes objPath with Stats collected separately for
+// the result and then added to the run totals
+func (bc *BuildingColorizer) processBuildingResult(ctx context.Context, objPath string) BuildingResult {
+	total := bc.Stats
+	bc.Stats = newStatistics()
+	bc.ProcessBuilding(ctx, objPath)
+	fileStats := bc.Stats
+	bc.Stats = total
+	bc.Stats.add(fileStats)
 
-	bc.PrintSummary()
+	result := BuildingResult{InputPath: objPath, Stats: fileStats}
+	if len(fileStats.FailedFiles) > 0 {
+		result.Err = errors.New(fileStats.FailedFiles[0].Error)
+		return result
+	}
+	if !bc.SummaryOnly {
+		for _, splitName := range bc.ExpectedSplitFiles[objPath] {
+			result.OutputPaths = append(result.OutputPaths, filepath.Join(bc.OutputDir, splitName))
+		}
+	}
+	return result
 }
 
 // PrintSummary prints detailed processing summary
 func (bc *BuildingColorizer) PrintSummary() {
-	endTime := time.Now()
-	duration := endTime.Sub(bc.StartTime).Seconds()
+	bc.Stats.Elapsed = time.Since(bc.StartTime)
+	duration := bc.Stats.Elapsed.Seconds()
 
 	fmt.Println("\n=== Building Colorizer v2.0.0 Summary ===")
 	fmt.Printf("Processing completed in %.2f seconds\n", duration)
 	fmt.Printf("Original files processed: %d\n", bc.Stats.ProcessedFiles)
+	if bc.interruptedFiles > 0 {
+		fmt.Printf("Interrupted: %d files not processed\n", bc.interruptedFiles)
+	}
 	fmt.Printf("Output directory: %s\n", bc.OutputDir)
 
 	fmt.Println("\nSplit files created:")
@@ -671,10 +4468,118 @@ func (bc *BuildingColorizer) PrintSummary() {
 		if bc.Stats.SplitFiles[material] > 0 {
 			fmt.Printf("  %s: %d → %d vertices (%.1f%% reduction)\n",
 				material, stats.OriginalVertices, stats.OptimizedVertices, stats.ReductionPercent)
+			if bc.ComputeHausdorff {
+				fmt.Printf("    Hausdorff distance: %.6f m (symmetric: %.6f m)\n", stats.HausdorffDistance, stats.SymmetricHausdorff)
+			}
+		}
+	}
+
+	if bc.WriteVolume && len(bc.Stats.BuildingVolumes) > 0 {
+		totalVolume := 0.0
+		for _, volume := range bc.Stats.BuildingVolumes {
+			totalVolume += volume
+		}
+		fmt.Printf("\nTotal building volume: %.3f m³ (%d buildings)\n", totalVolume, len(bc.Stats.BuildingVolumes))
+	}
+
+	if len(bc.Stats.RoofPitches) > 0 {
+		totalPitch := 0.0
+		for _, pitch := range bc.Stats.RoofPitches {
+			totalPitch += pitch
+		}
+		fmt.Printf("\nAverage roof pitch: %.1f° (%d buildings)\n", totalPitch/float64(len(bc.Stats.RoofPitches)), len(bc.Stats.RoofPitches))
+	}
+
+	if len(bc.Stats.Manifold) > 0 {
+		closed, open := 0, 0
+		var nonManifold []string
+		for name, status := range bc.Stats.Manifold {
+			switch {
+			case status.NonManifoldEdges > 0:
+				nonManifold = append(nonManifold, name)
+			case status.IsManifold:
+				closed++
+			default:
+				open++
+			}
+		}
+		sort.Strings(nonManifold)
+
+		fmt.Println("\nManifold status:")
+		fmt.Printf("  Closed manifold: %d\n", closed)
+		fmt.Printf("  Open (boundary edges only): %d\n", open)
+		fmt.Printf("  Non-manifold: %d\n", len(nonManifold))
+		for _, name := range nonManifold {
+			fmt.Printf("    %s: %d non-manifold edges\n", name, bc.Stats.Manifold[name].NonManifoldEdges)
+		}
+	}
+
+	if bc.WarnOnEmptyMaterial && len(bc.Stats.EmptyMaterialCounts) > 0 {
+		fmt.Println("\nEmpty material groups:")
+		for _, material := range bc.Materials() {
+			if count := bc.Stats.EmptyMaterialCounts[material]; count > 0 {
+				fmt.Printf("  %s: %d files\n", material, count)
+			}
+		}
+	}
+
+	if len(bc.workerUsage) > 1 && bc.poolElapsed > 0 {
+		fmt.Printf("\nWorker utilization (%d workers):\n", len(bc.workerUsage))
+		for i, usage := range bc.workerUsage {
+			fmt.Printf("  Worker %d: %d files, busy %.2fs (%.1f%%)\n", i+1, usage.Files,
+				usage.Busy.Seconds(), 100*usage.Busy.Seconds()/bc.poolElapsed.Seconds())
 		}
 	}
 
 	fmt.Printf("\nClassification adjustments: %d\n", bc.Stats.ClassificationChanges)
+	fmt.Printf("Faces assigned default material (%s): %d\n", bc.DefaultMaterial, bc.Stats.DefaultMaterial)
+	if bc.UseOutlines {
+		fmt.Printf("Faces outside building outlines (Ground): %d\n", bc.Stats.OutsideOutlines)
+	}
+	if bc.MarkSharedWalls {
+		fmt.Printf("Shared wall faces: %d (%d building pairs)\n", bc.Stats.SharedWalls, bc.Stats.SharedWallPairs)
+	}
+	if bc.DetectDuplicates {
+		fmt.Printf("Near-duplicate face pairs: %d\n", bc.Stats.DuplicateFaces)
+	}
+	if bc.CapOpenEdges {
+		fmt.Println("Cap faces added:")
+		for _, material := range bc.Materials() {
+			fmt.Printf("  %s: %d\n", material, bc.Stats.CapFaces[material])
+		}
+	}
+	if bc.FixOrientation {
+		fmt.Printf("Inverted faces: %d (fixed)\n", bc.Stats.InvertedFaces)
+	} else {
+		fmt.Printf("Inverted faces: %d\n", bc.Stats.InvertedFaces)
+	}
+	if bc.KeepUVIslands {
+		if bc.ClampUVs {
+			fmt.Printf("UV coordinates outside [0,1]: %d (clamped)\n", bc.Stats.OutOfRangeUVs)
+		} else {
+			fmt.Printf("UV coordinates outside [0,1]: %d\n", bc.Stats.OutOfRangeUVs)
+		}
+	}
+	if bc.PreserveInputMaterials {
+		fmt.Printf("Faces with preserved input material: %d\n", bc.Stats.PreservedMaterials)
+	}
+	if bc.Stats.DroppedFaces > 0 {
+		fmt.Printf("Dropped faces (material without a color): %d\n", bc.Stats.DroppedFaces)
+	}
+	if bc.TotalFaceBudget > 0 {
+		fmt.Printf("Face budget: %d of %d faces used (%.1f%%)\n", bc.budgetFaces, bc.TotalFaceBudget,
+			100*float64(bc.budgetFaces)/float64(bc.TotalFaceBudget))
+		if bc.budgetExhausted {
+			fmt.Printf("  Budget exhausted: %d faces dropped, %d files skipped\n", bc.Stats.BudgetDroppedFaces, bc.Stats.BudgetSkippedFiles)
+		}
+	}
+	if bc.Stats.ClampedVertices > 0 {
+		fmt.Printf("Z-clamped vertices: %d\n", bc.Stats.ClampedVertices)
+	}
+	if bc.SkipExisting {
+		fmt.Printf("Skipped up-to-date files: %d\n", bc.Stats.SkippedFiles)
+	}
+	fmt.Printf("Missing split files: %d\n", bc.Stats.FilesIntegrityErrors)
 	fmt.Printf("Failed files: %d\n", len(bc.Stats.FailedFiles))
 
 	if len(bc.Stats.FailedFiles) > 0 {
@@ -683,17 +4588,250 @@ func (bc *BuildingColorizer) PrintSummary() {
 			fmt.Printf("- %s: %s\n", failed.Name, failed.Error)
 		}
 	}
+
+	if bc.DryRun {
+		planned := append([]PlannedFile(nil), bc.plannedFiles...)
+		sort.Slice(planned, func(i, j int) bool { return planned[i].Path < planned[j].Path })
+
+		fmt.Println("\nFiles that would be created:")
+		totalBytes := 0
+		for _, file := range planned {
+			fmt.Printf("  %s: %d vertices, %d faces\n", file.Path, file.Vertices, file.Faces)
+			totalBytes += file.EstimatedBytes
+		}
+		fmt.Printf("Estimated disk space: %d bytes (%.2f MB) in %d files\n", totalBytes, float64(totalBytes)/(1024*1024), len(planned))
+	}
 	fmt.Println("=====================================")
 }
 
+// WriteStatistics writes the processing statistics to path as JSON
+func (bc *BuildingColorizer) WriteStatistics(path string) error {
+	data, err := json.MarshalIndent(bc.Stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode statistics: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write statistics file: %v", err)
+	}
+	return nil
+}
+
+// autoOutputDirName returns {objDir basename}_output_{YYYYMMDD_HHMMSS} for --auto-output-dir
+func autoOutputDirName(objDir string, now time.Time) string {
+	return filepath.Base(filepath.Clean(objDir)) + "_output_" + now.Format("20060102_150405")
+}
+
+// CoordinateTransform is an affine transform in homogeneous coordinates
+// applied to output vertices. Inverse maps transformed vertices back.
+type CoordinateTransform struct {
+	Seed    int64         `json:"seed"`
+	Matrix  [4][4]float64 `json:"matrix"`
+	Inverse [4][4]float64 `json:"inverse"`
+}
+
+// NewObfuscationTransform returns a rotation about the vertical axis followed
+// by a translation, both derived deterministically from seed. Rotating only
+// about Z keeps the roof, wall and ground classification of every face.
+func NewObfuscationTransform(seed int64) *CoordinateTransform {
+	rng := rand.New(rand.NewSource(seed))
+	angle := rng.Float64() * 2 * math.Pi
+	tx := (rng.Float64()*2 - 1) * 1e5
+	ty := (rng.Float64()*2 - 1) * 1e5
+	tz := (rng.Float64()*2 - 1) * 1e3
+	c, s := math.Cos(angle), math.Sin(angle)
+
+	return &CoordinateTransform{
+		Seed: seed,
+		Matrix: [4][4]float64{
+			{c, -s, 0, tx},
+			{s, c, 0, ty},
+			{0, 0, 1, tz},
+			{0, 0, 0, 1},
+		},
+		// Transpose of the rotation, with the translation rotated back and negated
+		Inverse: [4][4]float64{
+			{c, s, 0, -(c*tx + s*ty)},
+			{-s, c, 0, -(-s*tx + c*ty)},
+			{0, 0, 1, -tz},
+			{0, 0, 0, 1},
+		},
+	}
+}
+
+// Apply returns v transformed by the matrix
+func (t *CoordinateTransform) Apply(v Vector3) Vector3 {
+	m := t.Matrix
+	return Vector3{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z + m[0][3],
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z + m[1][3],
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z + m[2][3],
+	}
+}
+
+// ApplyDirection returns the direction v, such as a normal, transformed by the
+// matrix without its translation
+func (t *CoordinateTransform) ApplyDirection(v Vector3) Vector3 {
+	m := t.Matrix
+	return Vector3{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// WriteJSON writes the seed, matrix and inverse matrix to path
+func (t *CoordinateTransform) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode transform: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write transform file: %v", err)
+	}
+	return nil
+}
+
+// Config holds the options of a --config file. Its keys are the long flag
+// names with underscores, e.g. obj_dir for --obj-dir.
+type Config struct {
+	ObjDir                 string  `yaml:"obj_dir"`
+	Output                 string  `yaml:"output"`
+	AutoOutputDir          bool    `yaml:"auto_output_dir"`
+	GeoJSON                string  `yaml:"geojson"`
+	Colors                 string  `yaml:"colors"`
+	LogLevel               string  `yaml:"log_level"`
+	Debug                  bool    `yaml:"debug"`
+	DumpDihedralAngles     bool    `yaml:"dump_dihedral_angles"`
+	WriteVolume            bool    `yaml:"write_volume"`
+	FaceSort               string  `yaml:"face_sort"`
+	DefaultMaterial        string  `yaml:"default_material"`
+	MarkSharedWalls        bool    `yaml:"mark_shared_walls"`
+	StitchOpenEdges        bool    `yaml:"stitch_open_edges"`
+	SharedWallEpsilon      float64 `yaml:"shared_wall_epsilon"`
+	EmitStatsFaceHistogram string  `yaml:"emit_stats_face_histogram"`
+	OrientWalls            bool    `yaml:"orient_walls"`
+	SplitRoofPlanes        bool    `yaml:"split_roof_planes"`
+	RoofClusterAngle       float64 `yaml:"roof_cluster_angle"`
+	KMeansMaterials        int     `yaml:"k_means_materials"`
+	DetectDuplicates       bool    `yaml:"detect_duplicates"`
+	DuplicateDistance      float64 `yaml:"duplicate_distance"`
+	ObfuscateCoordinates   int64   `yaml:"obfuscate_coordinates"`
+	MeshRepairLog          string  `yaml:"mesh_repair_log"`
+	Manifest               string  `yaml:"manifest"`
+	XYZSwap                string  `yaml:"xyz_swap"`
+	PreserveInputMaterials bool    `yaml:"preserve_input_materials"`
+	UseOutlines            bool    `yaml:"use_outlines"`
+	GroundFromGeoJSON      bool    `yaml:"ground_from_geojson"`
+	ZClampMin              float64 `yaml:"z_clamp_min"`
+	ZClampMax              float64 `yaml:"z_clamp_max"`
+	ClampUVs               bool    `yaml:"clamp_uvs"`
+	NormaliseFaceIndices   bool    `yaml:"normalise_face_indices"`
+	SkipExisting           bool    `yaml:"skip_existing"`
+	TotalFaceBudget        int     `yaml:"total_face_budget"`
+	EmitEmptyGroups        bool    `yaml:"emit_empty_groups"`
+	WarnOnEmptyMaterial    bool    `yaml:"warn_on_empty_material"`
+	OutputHierarchy        bool    `yaml:"output_hierarchy"`
+	ValidateManifold       bool    `yaml:"validate_manifold"`
+	StrictManifold         bool    `yaml:"strict_manifold"`
+	InvertZClassification  bool    `yaml:"invert_z_classification"`
+	FixOrientation         bool    `yaml:"fix_orientation"`
+	ComputeHausdorff       bool    `yaml:"compute_hausdorff"`
+	PeakThreshold          float64 `yaml:"peak_threshold"`
+	GroundTolerance        float64 `yaml:"ground_tolerance"`
+	WallThreshold          float64 `yaml:"wall_threshold"`
+	ObjUnits               string  `yaml:"obj_units"`
+	PrefixMaterialName     bool    `yaml:"prefix_material_name"`
+	SummaryOnly            bool    `yaml:"summary_only"`
+	DryRun                 bool    `yaml:"dry_run"`
+	KeepUVIslands          bool    `yaml:"keep_uv_islands"`
+	ExportFaceAttrs        string  `yaml:"export_face_attrs"`
+	StatsOutput            string  `yaml:"stats_output"`
+	ExportHeightFeatures   string  `yaml:"export_height_features"`
+	GroundDetection        string  `yaml:"ground_detection"`
+	RansacIterations       int     `yaml:"ransac_iterations"`
+	AutoTuneTolerances     bool    `yaml:"auto_tune_tolerances"`
+	Format                 string  `yaml:"format"`
+	Workers                int     `yaml:"workers"`
+	VersionCheck           bool    `yaml:"version_check"`
+}
+
 func main() {
 	var objDir = flag.String("obj-dir", "", "Directory containing OBJ files (required)")
-	var outputDir = flag.String("output", "", "Output directory for split files (required)")
+	var outputDir = flag.String("output", "", "Output directory for split files (required unless --summary-only is set)")
+	var autoOutputDir = flag.Bool("auto-output-dir", false, "Derive the output directory from the obj-dir name and the current time")
 	var geoJSON = flag.String("geojson", "", "Path to GeoJSON building outlines (required)")
-	var debug = flag.Bool("debug", false, "Enable debug output")
+	var colorsPath = flag.String("colors", "", "JSON file mapping material names to {r, g, b, a} colors; Roof, Wall and Ground keep their built-in color unless given")
+	var logLevelName = flag.String("log-level", "INFO", "Minimum level of log messages: DEBUG, INFO, WARN or ERROR")
+	var debug = flag.Bool("debug", false, "Deprecated: same as --log-level DEBUG")
+	var dumpDihedral = flag.Bool("dump-dihedral-angles", false, "Print the dihedral angle of every shared edge")
+	var writeVolume = flag.Bool("write-volume", false, "Compute the enclosed mesh volume of each building")
+	var faceSort = flag.String("face-sort", "none", "Face order in output files: area-asc, area-desc, index or none")
+	var defaultMaterial = flag.String("default-material", "Roof", "Material for faces that match no classification rule (Roof, Wall or Ground)")
+	var markSharedWalls = flag.Bool("mark-shared-walls", false, "Write wall faces shared with adjacent buildings to *-shared split files")
+	var stitchOpenEdges = flag.Bool("stitch-open-edges", false, "Close the open boundary edges of each split group with cap faces")
+	var sharedWallEpsilon = flag.Float64("shared-wall-epsilon", 0.05, "Plane distance tolerance for shared wall detection")
+	var histogramTemplate = flag.String("emit-stats-face-histogram", "", "Write each building's Z histogram to this CSV path; {base} is replaced by the file name")
+	var orientWalls = flag.Bool("orient-walls", false, "Split wall faces into one file per compass orientation of their normal")
+	var splitRoofPlanes = flag.Bool("split-roof-planes", false, "Write each planar roof segment as a separate OBJ group in the roof file")
+	var roofClusterAngle = flag.Float64("roof-cluster-angle", 10, "Largest normal angle in degrees between faces of one roof plane")
+	var kMeansMaterials = flag.Int("k-means-materials", 0, "Classify faces by clustering their normals into N clusters mapped to Roof, Wall or Ground")
+	var detectDuplicates = flag.Bool("detect-duplicates", false, "Report near-duplicate faces between different OBJ files")
+	var duplicateDistance = flag.Float64("duplicate-distance", 0.1, "Maximum face centroid distance for --detect-duplicates")
+	var obfuscateSeed = flag.Int64("obfuscate-coordinates", 0, "Rotate and translate output vertices using this non-zero seed to anonymise them")
+	var repairLog = flag.String("mesh-repair-log", "", "Write every automated repair of input meshes to this XML file")
+	var manifest = flag.String("manifest", "", "Write a JSON manifest of the split files created for each input file")
+	var xyzSwap = flag.String("xyz-swap", "XYZ", "Reorder output vertex axes (e.g. XZY, YZX)")
+	var preserveInputMaterials = flag.Bool("preserve-input-materials", false, "Keep usemtl assignments of the input OBJ that name Roof, Wall or Ground")
+	var useOutlines = flag.Bool("use-outlines", false, "Classify faces outside every GeoJSON outline as Ground")
+	var groundFromGeoJSON = flag.Bool("ground-from-geojson", false, "Use the minimum Z of the containing GeoJSON outline as ground height")
+	var zClampMin = flag.Float64("z-clamp-min", math.Inf(-1), "Raise vertex Z values below this elevation before processing")
+	var zClampMax = flag.Float64("z-clamp-max", math.Inf(1), "Lower vertex Z values above this elevation before processing")
+	var clampUVs = flag.Bool("clamp-uvs", false, "Clamp texture coordinates outside [0,1] (requires --keep-uv-islands)")
+	var normaliseFaceIndices = flag.Bool("normalise-face-indices", false, "Write 0-based face indices instead of standard 1-based OBJ indices")
+	var skipExisting = flag.Bool("skip-existing", false, "Skip OBJ files whose split files recorded by the last run all exist and are newer than the OBJ file")
+	var totalFaceBudget = flag.Int("total-face-budget", 0, "Stop processing once the split files of all buildings hold this many faces (0 disables)")
+	var emitEmptyGroups = flag.Bool("emit-empty-groups", false, "Write a header-only OBJ file for materials with no faces")
+	var warnOnEmptyMaterial = flag.Bool("warn-on-empty-material", false, "Warn when an input file produces no faces for a material")
+	var outputHierarchy = flag.Bool("output-hierarchy", false, "Write split files into material-named subdirectories of the output directory")
+	var validateManifold = flag.Bool("validate-manifold", false, "Report open and non-manifold edges of each mesh before processing")
+	var strictManifold = flag.Bool("strict-manifold", false, "Fail meshes with non-manifold edges (requires --validate-manifold)")
+	var invertZClassification = flag.Bool("invert-z-classification", false, "Classify tunnel meshes: ground faces point down and roofs face downward")
+	var fixOrientation = flag.Bool("fix-orientation", false, "Reverse the winding of faces that point into the mesh")
+	var computeHausdorff = flag.Bool("compute-hausdorff", false, "Report the Hausdorff distance each group moved through face budget simplification")
+	var peakThreshold = flag.Float64("peak-threshold", 0.1, "Fraction of the largest Z histogram bin required for a ground peak (0-1)")
+	var groundTolerance = flag.Float64("ground-tolerance", 0.01, "Largest Z distance of a ground face's average height from the detected ground height")
+	var wallThreshold = flag.Float64("wall-threshold", 0.1, "Faces whose normal has an absolute Z component below this are walls (0-1)")
+	var objUnits = flag.String("obj-units", "m", "Units of the input OBJ coordinates: mm, cm, m, ft or in")
+	var prefixMaterialName = flag.Bool("prefix-material-name", false, "Prefix material names with the input file's base name")
+	var summaryOnly = flag.Bool("summary-only", false, "Process all files and print statistics without writing output")
+	var dryRun = flag.Bool("dry-run", false, "Process all files without writing output and list the split files that would be created")
+	var keepUVIslands = flag.Bool("keep-uv-islands", false, "Keep vertices with different texture coordinates distinct and write vt data")
+	var exportFaceAttrs = flag.String("export-face-attrs", "", "Write per-face material, centroid and normal to a binary file")
+	var statsOutput = flag.String("stats-output", "", "Write the processing statistics to this JSON file")
+	var exportHeightFeatures = flag.String("export-height-features", "", "Write each vertex's normalised height above ground (0-1) to a CSV file")
+	var groundDetection = flag.String("ground-detection", GroundDetectionHistogram, "Ground height detection: histogram or ransac")
+	var ransacIterations = flag.Int("ransac-iterations", 1000, "Planes sampled by --ground-detection ransac")
+	var autoTuneTolerances = flag.Bool("auto-tune-tolerances", false, "Set the geometry tolerance of each building to half its estimated point spacing")
+	var outputFormat = flag.String("format", OutputFormatOBJ, "Split file format: obj (OBJ+MTL) or gltf (binary .glb)")
+	var workers = flag.Int("workers", runtime.NumCPU(), "Number of OBJ files processed in parallel")
+	var versionCheck = flag.Bool("version-check", false, "Check GitHub for a newer release")
+	var configPath = flag.String("config", "", "JSON or YAML file with default values for the long flag names")
 	var help = flag.Bool("help", false, "Show help message")
 	flag.Parse()
 
+	// Options from the config file apply to the flags not given on the command line
+	if *configPath != "" {
+		var cfg Config
+		present, err := config.Load(*configPath, &cfg)
+		if err == nil {
+			err = config.Apply(flag.CommandLine, &cfg, present)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if *help {
 		fmt.Println("Building Colorizer v2.0.0 - Optimized File Splitter")
 		fmt.Println("Splits OBJ files into optimized separate files for each material type")
@@ -704,7 +4842,152 @@ func main() {
 		fmt.Println("  --output     Output directory for split and optimized files")
 		fmt.Println("  --geojson    Path to GeoJSON file with building outlines")
 		fmt.Println("\nOptional arguments:")
-		fmt.Println("  --debug      Enable debug output with detailed vertex optimization info")
+		fmt.Println("  --colors     JSON file {\"Roof\": {\"r\": 0.66, \"g\": 0.26, \"b\": 0.09, \"a\": 1}, ...} replacing the")
+		fmt.Println("               built-in material colors; every material in it is a classification target with")
+		fmt.Println("               split files named after it (e.g. -balcony); invalid files fall back to the defaults,")
+		fmt.Println("               and Roof, Wall or Ground missing from the file keep their built-in color")
+		fmt.Println("  --auto-output-dir")
+		fmt.Println("               Instead of --output, write to {obj-dir name}_output_{YYYYMMDD_HHMMSS} in the")
+		fmt.Println("               current directory")
+		fmt.Println("  --log-level  DEBUG, INFO, WARN or ERROR (default: INFO); DEBUG adds per-file")
+		fmt.Println("               vertex optimization details")
+		fmt.Println("  --debug      Deprecated alias for --log-level DEBUG")
+		fmt.Println("  --prefix-material-name")
+		fmt.Println("               Prefix material names with the input file name (e.g. building_42_Wall)")
+		fmt.Println("  --dump-dihedral-angles")
+		fmt.Println("               Print the dihedral angle between faces at every shared edge, e.g. to check")
+		fmt.Println("               how sharply roof planes meet or to find faces with inconsistent winding")
+		fmt.Println("  --write-volume")
+		fmt.Println("               Compute each building's mesh volume (closed meshes only)")
+		fmt.Println("  --default-material")
+		fmt.Println("               Material for faces matching no classification rule (default: Roof)")
+		fmt.Println("  --preserve-input-materials")
+		fmt.Println("               Faces following a usemtl directive naming a known material (Roof, Wall, Ground or")
+		fmt.Println("               a --colors material) keep that material; all other faces are classified as usual")
+		fmt.Println("  --use-outlines")
+		fmt.Println("               Classify faces whose X/Y centroid lies outside every GeoJSON outline as Ground,")
+		fmt.Println("               e.g. debris or courtyard geometry, regardless of their normal")
+		fmt.Println("  --ground-from-geojson")
+		fmt.Println("               Use the lowest Z of the 3D GeoJSON outline containing each building as its")
+		fmt.Println("               ground height (falls back to Z distribution analysis)")
+		fmt.Println("  --z-clamp-min")
+		fmt.Println("               Clamp vertex Z values below this elevation (meters) before processing")
+		fmt.Println("  --z-clamp-max")
+		fmt.Println("               Clamp vertex Z values above this elevation (meters) before processing")
+		fmt.Println("  --clamp-uvs  Clamp UVs outside [0,1] (requires --keep-uv-islands)")
+		fmt.Println("  --warn-on-empty-material")
+		fmt.Println("               Print a [WARN] line for each input file with no faces of a material")
+		fmt.Println("  --normalise-face-indices")
+		fmt.Println("               Write 0-based face indices (f 0 1 2) for tools that expect them; the files start with")
+		fmt.Println("               a comment warning that they are non-standard OBJ")
+		fmt.Println("  --emit-empty-groups")
+		fmt.Println("               Also write split files for materials with no faces: an OBJ with only the header")
+		fmt.Println("               and mtllib line, plus its MTL file, so every input yields one file per material")
+		fmt.Println("  --output-hierarchy")
+		fmt.Println("               Write split files into output/Roof/, output/Wall/ and output/Ground/")
+		fmt.Println("  --validate-manifold")
+		fmt.Println("               Count open edges (one face) and non-manifold edges (more than two faces) per mesh;")
+		fmt.Println("               meshes with non-manifold edges get a warning")
+		fmt.Println("  --strict-manifold")
+		fmt.Println("               With --validate-manifold, skip meshes with non-manifold edges as failed files")
+		fmt.Println("  --invert-z-classification")
+		fmt.Println("               For tunnels: horizontal faces at ground level count as Ground only when they face")
+		fmt.Println("               down, and faces pointing downward (instead of upward) are classified as Roof")
+		fmt.Println("  --fix-orientation")
+		fmt.Println("               Reverse faces whose normal points toward the mesh centroid before classification")
+		fmt.Println("  --compute-hausdorff")
+		fmt.Println("               Report the Hausdorff distance from each material group after --total-face-budget")
+		fmt.Println("               simplification to the group before it, and the symmetric distance that also")
+		fmt.Println("               counts removed vertices (both 0 when no simplification was needed)")
+		fmt.Println("  --peak-threshold")
+		fmt.Println("               Fraction of the largest Z histogram bin a ground peak must exceed (default: 0.1)")
+		fmt.Println("               Raise it, e.g. to 0.5, when roofs are detected as ground")
+		fmt.Println("  --ground-tolerance")
+		fmt.Println("               Largest vertical distance, in metres, between a horizontal face's average Z and the")
+		fmt.Println("               detected ground height for it to be Ground (default: 0.01)")
+		fmt.Println("  --wall-threshold")
+		fmt.Println("               Largest absolute Z component of a unit face normal for the face to be a Wall;")
+		fmt.Println("               0.1 accepts faces within about 5.7 degrees of vertical (default: 0.1)")
+		fmt.Println("  --face-sort  Face order in output: area-asc, area-desc, index or none (default: none)")
+		fmt.Println("  --mark-shared-walls")
+		fmt.Println("               Compare the walls of every pair of buildings and write wall faces shared with")
+		fmt.Println("               adjacent buildings to *-shared (off by default: memory and time grow with N²)")
+		fmt.Println("  --stitch-open-edges")
+		fmt.Println("               Close holes along the edges where groups were split (e.g. the top and bottom of")
+		fmt.Println("               the walls) with triangular cap faces; vertices within --ground-tolerance are treated as one")
+		fmt.Println("  --shared-wall-epsilon")
+		fmt.Println("               Plane distance tolerance for shared wall detection (default: 0.05)")
+		fmt.Println("  --emit-stats-face-histogram")
+		fmt.Println("               Write the Z histogram used for ground detection to a CSV per building,")
+		fmt.Println("               e.g. hist/{base}.csv ({base} is replaced by the OBJ file name)")
+		fmt.Println("  --orient-walls")
+		fmt.Println("               Write wall faces to one file per facade orientation (-wall-n, -wall-ne, -wall-e, ...,")
+		fmt.Println("               -wall-nw) from their outward normal, with +Y as north")
+		fmt.Println("  --split-roof-planes")
+		fmt.Println("               Cluster roof faces into planar segments and write each as a roof_plane_N group")
+		fmt.Println("               of the roof file")
+		fmt.Println("  --roof-cluster-angle")
+		fmt.Println("               Largest angle between face normals within one roof plane in degrees (default: 10)")
+		fmt.Println("  --k-means-materials")
+		fmt.Println("               Cluster face normals into N groups (k-means) and give each group the material")
+		fmt.Println("               whose direction is closest: up = Roof, horizontal = Wall, down = Ground")
+		fmt.Println("  --detect-duplicates")
+		fmt.Println("               Report faces of different OBJ files with nearby centroids and normals within 10°")
+		fmt.Println("  --duplicate-distance")
+		fmt.Println("               Maximum centroid distance for --detect-duplicates (default: 0.1)")
+		fmt.Println("  --obfuscate-coordinates")
+		fmt.Println("               Rotate output vertices about Z and translate them, seeded by this non-zero value;")
+		fmt.Println("               the inverse matrix (before --xyz-swap) is written to obfuscation.transform.json.")
+		fmt.Println("               --export-face-attrs centroids and normals and --export-height-features")
+		fmt.Println("               positions are transformed too")
+		fmt.Println("  --mesh-repair-log")
+		fmt.Println("               Write an XML log of every automated repair (dropped invalid faces, --fix-orientation")
+		fmt.Println("               winding flips, --z-clamp-min/max and --clamp-uvs changes) with before/after values")
+		fmt.Println("  --manifest   Write a JSON manifest listing, per input OBJ, the split files created with their")
+		fmt.Println("               material, vertex and face counts and size, the building's roof pitch and, with")
+		fmt.Println("               --write-volume, its volume, plus the run timestamp, version and --obj-dir,")
+		fmt.Println("               --output and --geojson values")
+		fmt.Println("  --xyz-swap   Reorder output vertex axes, e.g. XZY or YZX (default: XYZ); orders that mirror")
+		fmt.Println("               the mesh (XZY, YXZ, ZYX) also reverse the face winding")
+		fmt.Println("  --obj-units  Input OBJ units mm, cm, m, ft or in; coordinates are scaled to metres (default: m)")
+		fmt.Println("  --summary-only")
+		fmt.Println("               Run all processing and print the summary without writing any files")
+		fmt.Println("  --dry-run    Like --summary-only, and also list each split file that would be created")
+		fmt.Println("               (path, vertex and face count) with an estimate of the disk space needed")
+		fmt.Println("  --stats-output")
+		fmt.Println("               Write the statistics of the summary to a JSON file")
+		fmt.Println("  --keep-uv-islands")
+		fmt.Println("               Keep texture coordinates; vertices shared by faces with different UVs stay distinct")
+		fmt.Println("  --export-face-attrs")
+		fmt.Println("               Write little-endian face records [material_index uint8, centroid, normal float32 x3]")
+		fmt.Println("               (material_index: 0=Roof, 1=Wall, 2=Ground, then other --colors materials by name)")
+		fmt.Println("  --export-height-features")
+		fmt.Println("               Write building, vertex_index, x, y, z, height_above_ground rows to a CSV file, where")
+		fmt.Println("               height_above_ground is (z - ground) / (max z - ground) clamped to [0,1]")
+		fmt.Println("  --ground-detection")
+		fmt.Println("               histogram (lowest significant peak of a 50-bin Z histogram, default) or ransac")
+		fmt.Println("               (horizontal plane with the most horizontal-face vertices within the tolerance)")
+		fmt.Println("  --ransac-iterations")
+		fmt.Println("               Planes sampled per building with --ground-detection ransac (default: 1000)")
+		fmt.Println("  --auto-tune-tolerances")
+		fmt.Println("               Estimate each building's point spacing (mean length of 100 sampled edges) and use")
+		fmt.Println("               half of it as the tolerance for ground classification and --stitch-open-edges")
+		fmt.Println("  --format     Split file format: obj (OBJ with MTL, default) or gltf (self-contained binary")
+		fmt.Println("               glTF 2.0 .glb with the material color as baseColorFactor), also used for the")
+		fmt.Println("               *-shared files of --mark-shared-walls")
+		fmt.Println("  --workers    Number of OBJ files processed in parallel (default: number of CPUs)")
+		fmt.Println("  --total-face-budget")
+		fmt.Println("               Limit the faces written across all buildings; the building that would exceed it")
+		fmt.Println("               keeps only its largest faces and the remaining files are skipped. Files are")
+		fmt.Println("               processed one at a time in name order (--workers is ignored)")
+		fmt.Println("  --skip-existing")
+		fmt.Println("               Skip OBJ files whose split files from the last run all exist and are newer than")
+		fmt.Println("               the OBJ file, for incremental updates of a large output directory. Every run")
+		fmt.Println("               records the split files written per OBJ file in split-files.json in --output")
+		fmt.Println("  --version-check")
+		fmt.Println("               Check GitHub for a newer release (3 second timeout)")
+		fmt.Println("  --config     JSON or YAML (.yaml/.yml) file whose keys match flag names, written with")
+		fmt.Println("               dashes or underscores (e.g. obj_dir); command-line flags take precedence")
 		fmt.Println("  --help       Show this help message")
 		fmt.Println("\nExample:")
 		fmt.Printf("  %s --obj-dir ./input --output ./output --geojson ./outlines.geojson\n", os.Args[0])
@@ -721,8 +5004,33 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *objDir == "" || *outputDir == "" || *geoJSON == "" {
-		fmt.Println("Error: --obj-dir, --output, and --geojson arguments are all required")
+	if *autoOutputDir {
+		if *outputDir != "" {
+			fmt.Println("Error: --auto-output-dir cannot be combined with --output")
+			os.Exit(1)
+		}
+		if *objDir != "" {
+			*outputDir = autoOutputDirName(*objDir, time.Now())
+			fmt.Printf("Output directory: %s\n", *outputDir)
+		}
+	}
+
+	if *objDir == "" || (*outputDir == "" && !*summaryOnly) || *geoJSON == "" {
+		if *configPath != "" {
+			var missing []string
+			if *objDir == "" {
+				missing = append(missing, "obj-dir")
+			}
+			if *outputDir == "" && !*summaryOnly {
+				missing = append(missing, "output")
+			}
+			if *geoJSON == "" {
+				missing = append(missing, "geojson")
+			}
+			fmt.Printf("Error: %v\n", config.MissingError(*configPath, missing...))
+		} else {
+			fmt.Println("Error: --obj-dir, --output, and --geojson arguments are all required")
+		}
 		fmt.Println("Use --help for usage information")
 		os.Exit(1)
 	}
@@ -742,6 +5050,109 @@ func main() {
 		os.Exit(1)
 	}
 
+	axisPermutation, err := axes.Parse(*xyzSwap)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, ok := units.Scales[*objUnits]; !ok {
+		fmt.Printf("Error: Invalid --obj-units '%s' (expected mm, cm, m, ft or in)\n", *objUnits)
+		os.Exit(1)
+	}
+
+	if *histogramTemplate != "" && !strings.Contains(*histogramTemplate, "{base}") {
+		fmt.Println("Error: --emit-stats-face-histogram path must contain {base}")
+		os.Exit(1)
+	}
+
+	if *roofClusterAngle < 0 || *roofClusterAngle > 180 {
+		fmt.Printf("Error: Invalid --roof-cluster-angle %g (expected 0-180 degrees)\n", *roofClusterAngle)
+		os.Exit(1)
+	}
+
+	if *groundDetection != GroundDetectionHistogram && *groundDetection != GroundDetectionRANSAC {
+		fmt.Printf("Error: Invalid --ground-detection '%s' (expected histogram or ransac)\n", *groundDetection)
+		os.Exit(1)
+	}
+	if *ransacIterations < 1 {
+		fmt.Printf("Error: Invalid --ransac-iterations %d (expected a positive count)\n", *ransacIterations)
+		os.Exit(1)
+	}
+
+	if *outputFormat != OutputFormatOBJ && *outputFormat != OutputFormatGLTF {
+		fmt.Printf("Error: Invalid --format '%s' (expected obj or gltf)\n", *outputFormat)
+		os.Exit(1)
+	}
+	if *outputFormat == OutputFormatGLTF && *splitRoofPlanes {
+		fmt.Println("Error: --split-roof-planes writes OBJ groups and cannot be combined with --format gltf")
+		os.Exit(1)
+	}
+	if *outputFormat == OutputFormatGLTF && *normaliseFaceIndices {
+		fmt.Println("Error: --normalise-face-indices changes OBJ face lines and cannot be combined with --format gltf")
+		os.Exit(1)
+	}
+	if *outputFormat == OutputFormatGLTF && *emitEmptyGroups {
+		fmt.Println("Error: --emit-empty-groups writes empty OBJ files and cannot be combined with --format gltf")
+		os.Exit(1)
+	}
+
+	if *totalFaceBudget < 0 {
+		fmt.Printf("Error: Invalid --total-face-budget %d (expected 0 or a positive face count)\n", *totalFaceBudget)
+		os.Exit(1)
+	}
+
+	if *workers < 1 {
+		fmt.Printf("Error: Invalid --workers %d (expected at least 1)\n", *workers)
+		os.Exit(1)
+	}
+
+	if *kMeansMaterials < 0 {
+		fmt.Printf("Error: Invalid --k-means-materials %d (expected a positive cluster count)\n", *kMeansMaterials)
+		os.Exit(1)
+	}
+
+	if *detectDuplicates && *duplicateDistance <= 0 {
+		fmt.Printf("Error: Invalid --duplicate-distance %g (expected a positive distance)\n", *duplicateDistance)
+		os.Exit(1)
+	}
+
+	if *zClampMin > *zClampMax {
+		fmt.Printf("Error: --z-clamp-min %g is greater than --z-clamp-max %g\n", *zClampMin, *zClampMax)
+		os.Exit(1)
+	}
+
+	if *clampUVs && !*keepUVIslands {
+		fmt.Println("Error: --clamp-uvs requires --keep-uv-islands")
+		os.Exit(1)
+	}
+	if *strictManifold && !*validateManifold {
+		fmt.Println("Error: --strict-manifold requires --validate-manifold")
+		os.Exit(1)
+	}
+
+	if *peakThreshold <= 0 || *peakThreshold >= 1 {
+		fmt.Printf("Error: Invalid --peak-threshold %g (expected a fraction between 0 and 1)\n", *peakThreshold)
+		os.Exit(1)
+	}
+
+	if *groundTolerance < 0 {
+		fmt.Printf("Error: Invalid --ground-tolerance %g (expected a non-negative distance)\n", *groundTolerance)
+		os.Exit(1)
+	}
+
+	if *wallThreshold <= 0 || *wallThreshold >= 1 {
+		fmt.Printf("Error: Invalid --wall-threshold %g (expected a value between 0 and 1)\n", *wallThreshold)
+		os.Exit(1)
+	}
+
+	switch *faceSort {
+	case "area-asc", "area-desc", "index", "none":
+	default:
+		fmt.Printf("Error: Invalid --face-sort '%s' (expected area-asc, area-desc, index or none)\n", *faceSort)
+		os.Exit(1)
+	}
+
 	// Convert output directory to absolute path
 	absOutputDir, err := filepath.Abs(*outputDir)
 	if err != nil {
@@ -749,16 +5160,122 @@ func main() {
 		os.Exit(1)
 	}
 
+	logLevel, err := ParseLogLevel(*logLevelName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 	if *debug {
+		logLevel = LogDebug
+	}
+
+	if logLevel == LogDebug {
 		fmt.Println("Debug mode enabled")
 		fmt.Printf("Input Directory: %s\n", *objDir)
 		fmt.Printf("Output Directory: %s\n", absOutputDir)
 		fmt.Printf("GeoJSON File: %s\n", *geoJSON)
+		fmt.Printf("OBJ units: %s (scale factor %g)\n", *objUnits, units.Scales[*objUnits])
 	}
 
 	fmt.Println("Building Colorizer v2.0.0 - Optimized File Splitter")
 	fmt.Println("===================================================")
 
-	colorizer := NewBuildingColorizer(*objDir, absOutputDir, *geoJSON, *debug)
-	colorizer.ProcessAllBuildings()
+	// Check for a newer release in the background so processing is not blocked
+	var versionResult chan string
+	if *versionCheck {
+		versionResult = make(chan string, 1)
+		go func() { versionResult <- version.CheckForUpdate(Version) }()
+	}
+
+	colorizer := NewBuildingColorizer(*objDir, absOutputDir, *geoJSON, *colorsPath, logLevel, *groundTolerance, *wallThreshold)
+
+	if _, ok := colorizer.Colors[*defaultMaterial]; !ok {
+		var materials []string
+		for material := range colorizer.Colors {
+			materials = append(materials, material)
+		}
+		sort.Strings(materials)
+		fmt.Printf("Error: Invalid --default-material '%s' (expected one of %s)\n", *defaultMaterial, strings.Join(materials, ", "))
+		os.Exit(1)
+	}
+
+	colorizer.PrefixMaterialName = *prefixMaterialName
+	colorizer.AxisPermutation = axisPermutation
+	colorizer.DumpDihedralAngles = *dumpDihedral
+	colorizer.WriteVolume = *writeVolume
+	colorizer.FaceSort = *faceSort
+	colorizer.DefaultMaterial = *defaultMaterial
+	colorizer.MarkSharedWalls = *markSharedWalls
+	colorizer.CapOpenEdges = *stitchOpenEdges
+	colorizer.SharedWallEpsilon = *sharedWallEpsilon
+	colorizer.DetectDuplicates = *detectDuplicates
+	colorizer.DuplicateDistance = *duplicateDistance
+	colorizer.HistogramPathTemplate = *histogramTemplate
+	colorizer.KMeansMaterials = *kMeansMaterials
+	colorizer.SplitRoofPlanes = *splitRoofPlanes
+	colorizer.OrientWalls = *orientWalls
+	colorizer.RoofClusterAngle = *roofClusterAngle
+	if *obfuscateSeed != 0 {
+		colorizer.Obfuscation = NewObfuscationTransform(*obfuscateSeed)
+	}
+	colorizer.RepairLogPath = *repairLog
+	colorizer.ManifestPath = *manifest
+	colorizer.ExportFaceAttrs = *exportFaceAttrs != ""
+	colorizer.ExportHeightFeats = *exportHeightFeatures != ""
+	colorizer.KeepUVIslands = *keepUVIslands
+	colorizer.SummaryOnly = *summaryOnly || *dryRun
+	colorizer.DryRun = *dryRun
+	colorizer.ObjUnits = *objUnits
+	colorizer.MeshAnalyzer.PeakThreshold = *peakThreshold
+	colorizer.ComputeHausdorff = *computeHausdorff
+	colorizer.Workers = *workers
+	colorizer.AutoTuneTolerances = *autoTuneTolerances
+	colorizer.GroundDetection = *groundDetection
+	colorizer.RANSACIterations = *ransacIterations
+	colorizer.OutputFormat = *outputFormat
+	colorizer.FixOrientation = *fixOrientation
+	colorizer.GeometryValidator.InvertZ = *invertZClassification
+	colorizer.ValidateManifold = *validateManifold
+	colorizer.StrictManifold = *strictManifold
+	colorizer.OutputHierarchy = *outputHierarchy
+	colorizer.WarnOnEmptyMaterial = *warnOnEmptyMaterial
+	colorizer.EmitEmptyGroups = *emitEmptyGroups
+	colorizer.NormaliseFaceIndices = *normaliseFaceIndices
+	colorizer.TotalFaceBudget = *totalFaceBudget
+	colorizer.SkipExisting = *skipExisting
+	colorizer.ClampUVs = *clampUVs
+	colorizer.ZClampMin = *zClampMin
+	colorizer.ZClampMax = *zClampMax
+	colorizer.GroundFromGeoJSON = *groundFromGeoJSON
+	colorizer.UseOutlines = *useOutlines
+	colorizer.PreserveInputMaterials = *preserveInputMaterials
+
+	// On SIGINT or SIGTERM, finish the files in progress and print the summary
+	// of the partial run; a second signal terminates immediately
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	colorizer.ProcessAllBuildings(ctx)
+
+	// An interrupted run still writes its exports and statistics for the
+	// files processed so far before exiting with status 2
+	if err := colorizer.WriteExports(*exportFaceAttrs, *exportHeightFeatures); err != nil {
+		fmt.Printf("Error %v\n", err)
+		os.Exit(1)
+	}
+	if *statsOutput != "" {
+		if err := colorizer.WriteStatistics(*statsOutput); err != nil {
+			fmt.Printf("Error writing statistics: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if ctx.Err() != nil {
+		os.Exit(2)
+	}
+	if versionResult != nil {
+		fmt.Println(<-versionResult)
+	}
 }
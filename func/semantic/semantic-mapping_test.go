@@ -0,0 +1,729 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"citygml-gen/internal/axes"
+)
+
+// newTestColorizer returns a colorizer with the command-line defaults that
+// reads no building outlines and only logs errors
+func newTestColorizer(t testing.TB) *BuildingColorizer {
+	dir := t.TempDir()
+	geoJSONPath := filepath.Join(dir, "outlines.geojson")
+	if err := os.WriteFile(geoJSONPath, []byte(`{"type":"FeatureCollection","features":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewBuildingColorizer(dir, dir, geoJSONPath, "", LogError, 0.01, 0.1)
+}
+
+// unitCube returns the vertices and outward-facing quads of the unit cube
+func unitCube() ([]Vector3, []Face) {
+	vertices := []Vector3{
+		{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0},
+		{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1},
+	}
+	faces := []Face{
+		{0, 3, 2, 1}, // bottom
+		{4, 5, 6, 7}, // top
+		{0, 1, 5, 4}, // front
+		{3, 7, 6, 2}, // back
+		{0, 4, 7, 3}, // left
+		{1, 2, 6, 5}, // right
+	}
+	return vertices, faces
+}
+
+// cubeGroup returns an optimized face group holding the whole unit cube
+func cubeGroup(bc *BuildingColorizer) *OptimizedFaceGroup {
+	vertices, faces := unitCube()
+	group := &OptimizedFaceGroup{Material: "Wall", Faces: faces, VertexMapping: make(map[int]int)}
+	used := make(map[int]bool)
+	for _, face := range faces {
+		for _, idx := range face {
+			used[idx] = true
+		}
+	}
+	bc.optimizeVerticesForGroup(vertices, nil, group, used)
+	return group
+}
+
+func TestEncodeGlbRoundTrip(t *testing.T) {
+	bc := newTestColorizer(t)
+	group := cubeGroup(bc)
+
+	glb, err := bc.encodeGlb("Wall", "Wall", group)
+	if err != nil {
+		t.Fatalf("encodeGlb: %v", err)
+	}
+
+	// Header: magic, version and total length
+	if len(glb) < 12 {
+		t.Fatalf("GLB too short: %d bytes", len(glb))
+	}
+	if magic := binary.LittleEndian.Uint32(glb[0:]); magic != glbMagic {
+		t.Fatalf("magic = %#x, want %#x", magic, glbMagic)
+	}
+	if version := binary.LittleEndian.Uint32(glb[4:]); version != 2 {
+		t.Fatalf("version = %d, want 2", version)
+	}
+	if length := binary.LittleEndian.Uint32(glb[8:]); int(length) != len(glb) {
+		t.Fatalf("header length = %d, file has %d bytes", length, len(glb))
+	}
+
+	// Chunks: JSON first, then BIN, each 4-byte aligned
+	chunks := make(map[uint32][]byte)
+	var order []uint32
+	for offset := 12; offset < len(glb); {
+		if offset+8 > len(glb) {
+			t.Fatalf("truncated chunk header at %d", offset)
+		}
+		chunkLength := int(binary.LittleEndian.Uint32(glb[offset:]))
+		chunkType := binary.LittleEndian.Uint32(glb[offset+4:])
+		if chunkLength%4 != 0 {
+			t.Errorf("chunk %#x length %d is not 4-byte aligned", chunkType, chunkLength)
+		}
+		offset += 8
+		if offset+chunkLength > len(glb) {
+			t.Fatalf("chunk %#x overruns the file", chunkType)
+		}
+		chunks[chunkType] = glb[offset : offset+chunkLength]
+		order = append(order, chunkType)
+		offset += chunkLength
+	}
+	if len(order) != 2 || order[0] != glbChunkJSON || order[1] != glbChunkBIN {
+		t.Fatalf("chunk types = %#x, want JSON then BIN", order)
+	}
+
+	var doc gltfDocument
+	if err := json.Unmarshal(chunks[glbChunkJSON], &doc); err != nil {
+		t.Fatalf("decoding JSON chunk: %v", err)
+	}
+	bin := chunks[glbChunkBIN]
+	if len(doc.Buffers) != 1 || doc.Buffers[0].ByteLength > len(bin) {
+		t.Fatalf("buffers %+v do not fit the %d-byte BIN chunk", doc.Buffers, len(bin))
+	}
+	if len(doc.Meshes) != 1 || len(doc.Meshes[0].Primitives) != 1 {
+		t.Fatalf("want one mesh with one primitive, got %+v", doc.Meshes)
+	}
+	primitive := doc.Meshes[0].Primitives[0]
+
+	// accessorData returns the bytes of an accessor's buffer view
+	accessorData := func(index int) (gltfAccessor, []byte) {
+		accessor := doc.Accessors[index]
+		view := doc.BufferViews[accessor.BufferView]
+		if view.ByteOffset+view.ByteLength > len(bin) {
+			t.Fatalf("buffer view %d overruns the BIN chunk", accessor.BufferView)
+		}
+		return accessor, bin[view.ByteOffset : view.ByteOffset+view.ByteLength]
+	}
+
+	positions, positionData := accessorData(primitive.Attributes["POSITION"])
+	if positions.ComponentType != gltfFloat || positions.Type != "VEC3" {
+		t.Fatalf("POSITION accessor is %d/%s, want float VEC3", positions.ComponentType, positions.Type)
+	}
+	if positions.Count != len(group.OptimizedVertices) || len(positionData) != positions.Count*12 {
+		t.Fatalf("POSITION count = %d (%d bytes), want %d vertices", positions.Count, len(positionData), len(group.OptimizedVertices))
+	}
+	for i, vertex := range group.OptimizedVertices {
+		for axis, want := range []float64{vertex.X, vertex.Y, vertex.Z} {
+			got := math.Float32frombits(binary.LittleEndian.Uint32(positionData[i*12+axis*4:]))
+			if float64(got) != want {
+				t.Errorf("vertex %d axis %d = %g, want %g", i, axis, got, want)
+			}
+		}
+	}
+
+	indices, indexData := accessorData(primitive.Indices)
+	if indices.ComponentType != gltfUnsignedInt || indices.Type != "SCALAR" {
+		t.Fatalf("index accessor is %d/%s, want unsigned int SCALAR", indices.ComponentType, indices.Type)
+	}
+	wantTriangles := 0
+	for _, face := range group.Faces {
+		wantTriangles += len(face) - 2
+	}
+	if indices.Count != wantTriangles*3 || len(indexData) != indices.Count*4 {
+		t.Fatalf("index count = %d (%d bytes), want %d triangles", indices.Count, len(indexData), wantTriangles)
+	}
+	for i := 0; i < indices.Count; i++ {
+		if index := binary.LittleEndian.Uint32(indexData[i*4:]); int(index) >= positions.Count {
+			t.Errorf("index %d = %d is out of range", i, index)
+		}
+	}
+}
+
+// TestCloneConcurrentPasses runs three passes at once, each on its own clone
+// of one group; run with -race to check that clones share no memory
+func TestCloneConcurrentPasses(t *testing.T) {
+	bc := newTestColorizer(t)
+	group := cubeGroup(bc)
+	group.Faces = group.Faces[:5] // Open the top so StitchOpenEdges has work to do
+	group.Adjacency = bc.BuildAdjacency(group)
+	original := group.Clone()
+
+	var wg sync.WaitGroup
+	results := make([]*OptimizedFaceGroup, 3)
+	passes := []func(g *OptimizedFaceGroup) *OptimizedFaceGroup{
+		func(g *OptimizedFaceGroup) *OptimizedFaceGroup {
+			for _, face := range g.Faces {
+				face[0], face[1] = face[1], face[0]
+			}
+			g.Adjacency = bc.BuildAdjacency(g)
+			return g
+		},
+		func(g *OptimizedFaceGroup) *OptimizedFaceGroup {
+			return bc.StitchOpenEdges(g, 1e-6)
+		},
+		func(g *OptimizedFaceGroup) *OptimizedFaceGroup {
+			for i := range g.OptimizedVertices {
+				g.OptimizedVertices[i].Z += 1
+			}
+			for oldIdx := range g.VertexMapping {
+				g.VertexMapping[oldIdx]++
+			}
+			for edge := range g.Adjacency {
+				g.Adjacency[edge] = append(g.Adjacency[edge], -1)
+			}
+			return g
+		},
+	}
+	for i, pass := range passes {
+		wg.Add(1)
+		go func(i int, pass func(g *OptimizedFaceGroup) *OptimizedFaceGroup) {
+			defer wg.Done()
+			results[i] = pass(group.Clone())
+		}(i, pass)
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(group, original) {
+		t.Errorf("passes on clones modified the original group")
+	}
+	if len(results[1].Faces) <= len(group.Faces) {
+		t.Errorf("StitchOpenEdges added no cap faces: %d faces", len(results[1].Faces))
+	}
+	if !reflect.DeepEqual(results[1].Adjacency, bc.BuildAdjacency(results[1])) {
+		t.Errorf("StitchOpenEdges left the adjacency of the capped group stale")
+	}
+	if results[0].Faces[0][0] != group.Faces[0][1] {
+		t.Errorf("first pass did not reverse its own copy")
+	}
+}
+
+func TestFixOrientationUnitCube(t *testing.T) {
+	bc := newTestColorizer(t)
+	vertices, faces := unitCube()
+
+	// Invert the bottom, back and right faces
+	for _, i := range []int{0, 3, 5} {
+		face := faces[i]
+		for a, b := 0, len(face)-1; a < b; a, b = a+1, b-1 {
+			face[a], face[b] = face[b], face[a]
+		}
+	}
+
+	consistent, invertedCount := bc.GeometryValidator.CheckFaceOrientation(vertices, faces)
+	if consistent || invertedCount != 3 {
+		t.Fatalf("CheckFaceOrientation = (%t, %d), want (false, 3)", consistent, invertedCount)
+	}
+
+	bc.fixInvertedFaces("cube.obj", faces, bc.GeometryValidator.findInvertedFaces(vertices, faces))
+
+	if consistent, invertedCount := bc.GeometryValidator.CheckFaceOrientation(vertices, faces); !consistent || invertedCount != 0 {
+		t.Errorf("after fixing, CheckFaceOrientation = (%t, %d), want (true, 0)", consistent, invertedCount)
+	}
+	center := Vector3{0.5, 0.5, 0.5}
+	for i, face := range faces {
+		normal := bc.GeometryValidator.GetFaceNormal(vertices, face)
+		var faceCenter Vector3
+		for _, idx := range face {
+			faceCenter.X += vertices[idx].X / float64(len(face))
+			faceCenter.Y += vertices[idx].Y / float64(len(face))
+			faceCenter.Z += vertices[idx].Z / float64(len(face))
+		}
+		outward := Vector3{faceCenter.X - center.X, faceCenter.Y - center.Y, faceCenter.Z - center.Z}
+		if dot := normal.X*outward.X + normal.Y*outward.Y + normal.Z*outward.Z; dot <= 0 {
+			t.Errorf("face %d normal %+v points inward after fixing", i, normal)
+		}
+	}
+}
+
+func TestWriteOptimizedObjMirroredAxes(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.AxisPermutation = axes.Permutation{0, 2, 1}
+	group := cubeGroup(bc)
+
+	var out bytes.Buffer
+	if err := bc.writeOptimizedObj(&out, "Wall.mtl", "Wall", group); err != nil {
+		t.Fatalf("writeOptimizedObj: %v", err)
+	}
+
+	// Read the written mesh back and check that every face still points
+	// away from the cube's center
+	var vertices []Vector3
+	var faces []Face
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			var v Vector3
+			fmt.Sscan(strings.Join(fields[1:], " "), &v.X, &v.Y, &v.Z)
+			vertices = append(vertices, v)
+		case "f":
+			var face Face
+			for _, corner := range fields[1:] {
+				idx, _ := strconv.Atoi(corner)
+				face = append(face, idx-1)
+			}
+			faces = append(faces, face)
+		}
+	}
+	if len(vertices) != 8 || len(faces) != 6 {
+		t.Fatalf("read back %d vertices and %d faces, want 8 and 6", len(vertices), len(faces))
+	}
+	if consistent, invertedCount := bc.GeometryValidator.CheckFaceOrientation(vertices, faces); !consistent || invertedCount != 0 {
+		t.Errorf("CheckFaceOrientation = (%t, %d) after XZY, want (true, 0)", consistent, invertedCount)
+	}
+}
+
+func TestPrintDihedralAnglesInconsistentWinding(t *testing.T) {
+	bc := newTestColorizer(t)
+	vertices := []Vector3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}}
+	// Both faces traverse the shared edge from vertex 3 to vertex 2
+	faces := []Face{{2, 1, 0}, {2, 1, 3}}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	bc.PrintDihedralAngles("flat.obj", vertices, faces)
+	os.Stdout = stdout
+	w.Close()
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(output), "2-3: f1/f2 -> ") {
+		t.Errorf("shared edge 2-3 missing from output:\n%s", output)
+	}
+}
+
+func TestObfuscatedExports(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.Obfuscation = NewObfuscationTransform(42)
+	vertices, faces := unitCube()
+
+	bc.recordFaceAttribute(vertices, faces[1], 0)
+	bc.recordHeightFeatures("cube", vertices, 0)
+
+	wantCentroid := bc.Obfuscation.Apply(Vector3{0.5, 0.5, 1})
+	centroid := bc.faceAttrs[0].Centroid
+	if math.Abs(float64(centroid[0])-wantCentroid.X) > 0.01 || math.Abs(float64(centroid[1])-wantCentroid.Y) > 0.01 || math.Abs(float64(centroid[2])-wantCentroid.Z) > 0.01 {
+		t.Errorf("top face centroid = %v, want the obfuscated %+v", centroid, wantCentroid)
+	}
+	if normal := bc.faceAttrs[0].Normal; math.Abs(float64(normal[2])-1) > 1e-6 {
+		t.Errorf("top face normal = %v, want it to stay vertical", normal)
+	}
+
+	for i, f := range bc.heightFeatures {
+		if want := bc.Obfuscation.Apply(vertices[i]); f.Position != want {
+			t.Errorf("height feature %d position = %+v, want the obfuscated %+v", i, f.Position, want)
+		}
+	}
+	if top := bc.heightFeatures[6]; top.Height != 1 {
+		t.Errorf("top vertex height = %g, want 1", top.Height)
+	}
+
+	// Passed-through vn lines are rotated with the vertices
+	obj := "v 0 0 0\nv 1 0 0\nv 1 0 1\nv 0 0 1\nvn 0 -1 0\nf 1//1 2//1 3//1 4//1\n"
+	objVertices, objFaces, _, err := bc.LoadObjFromReader(strings.NewReader(obj), "front.obj")
+	if err != nil {
+		t.Fatalf("LoadObjFromReader: %v", err)
+	}
+	group := &OptimizedFaceGroup{Material: "Wall", Faces: objFaces, FaceVertices: bc.faceVertices, VertexMapping: make(map[int]int)}
+	bc.optimizeVerticesForGroup(objVertices, nil, group, map[int]bool{0: true, 1: true, 2: true, 3: true})
+	var out bytes.Buffer
+	if err := bc.writeOptimizedObj(&out, "Wall.mtl", "Wall", group); err != nil {
+		t.Fatalf("writeOptimizedObj: %v", err)
+	}
+
+	var written []Vector3
+	var normal Vector3
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 4 && (fields[0] == "v" || fields[0] == "vn") {
+			var v Vector3
+			fmt.Sscan(strings.Join(fields[1:], " "), &v.X, &v.Y, &v.Z)
+			if fields[0] == "v" {
+				written = append(written, v)
+			} else {
+				normal = v
+			}
+		}
+	}
+	if len(written) != 4 {
+		t.Fatalf("read back %d vertices, want 4", len(written))
+	}
+	faceNormal := bc.GeometryValidator.GetFaceNormal(written, Face{0, 1, 2, 3})
+	if math.Abs(normal.X-faceNormal.X) > 1e-5 || math.Abs(normal.Y-faceNormal.Y) > 1e-5 || math.Abs(normal.Z-faceNormal.Z) > 1e-5 {
+		t.Errorf("written vn = %+v, want the obfuscated face normal %+v", normal, faceNormal)
+	}
+}
+
+func TestInterruptedRunWritesRunFiles(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.ManifestPath = filepath.Join(t.TempDir(), "manifest.json")
+	bc.Obfuscation = NewObfuscationTransform(7)
+	vertices, faces := unitCube()
+	var obj strings.Builder
+	for _, v := range vertices {
+		fmt.Fprintf(&obj, "v %g %g %g\n", v.X, v.Y, v.Z)
+	}
+	for _, face := range faces {
+		fmt.Fprintf(&obj, "f %d %d %d %d\n", face[0]+1, face[1]+1, face[2]+1, face[3]+1)
+	}
+	if err := os.WriteFile(filepath.Join(bc.ObjDir, "cube.obj"), []byte(obj.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancelled before the first file, so no building is processed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bc.ProcessAllBuildings(ctx)
+
+	if _, err := os.Stat(bc.ManifestPath); err != nil {
+		t.Errorf("manifest not written after cancellation: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bc.OutputDir, "obfuscation.transform.json")); err != nil {
+		t.Errorf("obfuscation transform not written after cancellation: %v", err)
+	}
+}
+
+// BenchmarkWriteOptimizedObj writes a textured 225x225 grid, about 100k
+// triangles, as OBJ; run with -benchmem to see the allocation rate
+func BenchmarkWriteOptimizedObj(b *testing.B) {
+	bc := newTestColorizer(b)
+	const size = 225
+	group := &OptimizedFaceGroup{Material: "Roof", VertexMapping: make(map[int]int)}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			idx := y*size + x
+			group.OptimizedVertices = append(group.OptimizedVertices, Vector3{float64(x) * 0.5, float64(y) * 0.5, float64(x+y) * 0.01})
+			group.OptimizedUVs = append(group.OptimizedUVs, TexCoord{float64(x) / size, float64(y) / size, true})
+			group.VertexMapping[idx] = idx
+			if x > 0 && y > 0 {
+				group.Faces = append(group.Faces, Face{idx - size - 1, idx - size, idx}, Face{idx - size - 1, idx, idx - 1})
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := bc.writeOptimizedObj(io.Discard, "Roof.mtl", "Roof", group); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWriteStatisticsSkippedFiles(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.Stats.SkippedFiles = 2
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := bc.WriteStatistics(path); err != nil {
+		t.Fatalf("WriteStatistics: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("decoding statistics: %v", err)
+	}
+	if string(fields["skipped_files"]) != "2" {
+		t.Errorf("skipped_files = %s, want 2", fields["skipped_files"])
+	}
+
+	var decoded Statistics
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if decoded.SkippedFiles != 2 {
+		t.Errorf("decoded SkippedFiles = %d, want 2", decoded.SkippedFiles)
+	}
+}
+
+func TestHausdorffAfterSimplification(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.ComputeHausdorff = true
+	vertices, _ := unitCube()
+	faceGroups := map[string]*OptimizedFaceGroup{"Wall": cubeGroup(bc)}
+	bc.Stats.VertexOptimization["Wall"] = VertexStats{OriginalVertices: 8, OptimizedVertices: 8}
+
+	unsimplified := map[string][]Vector3{"Wall": faceGroups["Wall"].OptimizedVertices}
+	bc.recordHausdorffDistances(unsimplified, faceGroups)
+	if got := bc.Stats.VertexOptimization["Wall"]; got.HausdorffDistance != 0 || got.SymmetricHausdorff != 0 {
+		t.Errorf("Hausdorff distances without simplification = %f and %f, want 0", got.HausdorffDistance, got.SymmetricHausdorff)
+	}
+
+	// Only the bottom face survives: its corners are original vertices, but
+	// the removed top corners lie 1 m from the output
+	bc.SimplifyToFaceCount(vertices, faceGroups, 1)
+	bc.recordHausdorffDistances(unsimplified, faceGroups)
+	got := bc.Stats.VertexOptimization["Wall"]
+	if got.HausdorffDistance != 0 {
+		t.Errorf("directed HausdorffDistance after simplification = %f, want 0", got.HausdorffDistance)
+	}
+	if math.Abs(got.SymmetricHausdorff-1) > 1e-9 {
+		t.Errorf("SymmetricHausdorff after simplification = %f, want 1", got.SymmetricHausdorff)
+	}
+}
+
+func TestManifestVolumeAndRoofPitch(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.ManifestPath = filepath.Join(t.TempDir(), "manifest.json")
+	bc.WriteVolume = true
+	bc.Stats.BuildingVolumes["cube.obj"] = 1
+	bc.Stats.RoofPitches["cube.obj"] = 30
+	group := cubeGroup(bc)
+	group.Material = "Roof"
+	if err := bc.CreateSeparateObjFiles(filepath.Join(bc.ObjDir, "cube.obj"), map[string]*OptimizedFaceGroup{"Roof": group}); err != nil {
+		t.Fatalf("CreateSeparateObjFiles: %v", err)
+	}
+	if err := bc.WriteManifest(bc.ManifestPath); err != nil {
+		t.Fatalf("WriteManifest: %v", err)
+	}
+
+	data, err := os.ReadFile(bc.ManifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Files []map[string]json.RawMessage `json:"files"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decoding manifest: %v", err)
+	}
+	if len(doc.Files) != 1 {
+		t.Fatalf("manifest lists %d files, want 1", len(doc.Files))
+	}
+	if volume := string(doc.Files[0]["volume"]); volume != "1" {
+		t.Errorf("volume = %s, want 1", volume)
+	}
+	if pitch := string(doc.Files[0]["roof_pitch"]); pitch != "30" {
+		t.Errorf("roof_pitch = %s, want 30", pitch)
+	}
+}
+
+// gableRoof returns a roof group of two planes sloping away from a ridge
+// along Y, each split into size x size quads of two triangles. The faces of
+// the west plane come first.
+func gableRoof(size int) *OptimizedFaceGroup {
+	group := &OptimizedFaceGroup{Material: "Roof", VertexMapping: make(map[int]int)}
+	for _, side := range []float64{-1, 1} {
+		base := len(group.OptimizedVertices)
+		for y := 0; y <= size; y++ {
+			for x := 0; x <= size; x++ {
+				// Run from the ridge at X=0 down to the eave at X=side*size
+				dx := float64(x)
+				group.OptimizedVertices = append(group.OptimizedVertices, Vector3{side * dx, float64(y), 10 - 0.5*dx})
+			}
+		}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				a := base + y*(size+1) + x
+				b, c, d := a+1, a+size+2, a+size+1
+				if side < 0 {
+					group.Faces = append(group.Faces, Face{a, c, b}, Face{a, d, c})
+				} else {
+					group.Faces = append(group.Faces, Face{a, b, c}, Face{a, c, d})
+				}
+			}
+		}
+	}
+	for i := range group.OptimizedVertices {
+		group.VertexMapping[i] = i
+	}
+	return group
+}
+
+func TestClusterRoofPlanesGable(t *testing.T) {
+	bc := newTestColorizer(t)
+	const size = 30
+	group := gableRoof(size)
+
+	planes := bc.ClusterRoofPlanes(group)
+	if len(planes) != 2 {
+		t.Fatalf("found %d roof planes, want 2", len(planes))
+	}
+	perPlane := 2 * size * size
+	for p, plane := range planes {
+		if len(plane.Faces) != perPlane {
+			t.Fatalf("plane %d has %d faces, want %d", p, len(plane.Faces), perPlane)
+		}
+		for i, face := range plane.Faces {
+			if face != p*perPlane+i {
+				t.Fatalf("plane %d holds face %d, want faces %d-%d", p, face, p*perPlane, (p+1)*perPlane-1)
+			}
+		}
+		// Both slopes rise 0.5 per unit towards the ridge and face upwards
+		wantX := 0.5 / math.Sqrt(1.25)
+		if p == 0 {
+			wantX = -wantX
+		}
+		if math.Abs(plane.Normal.X-wantX) > 1e-9 || math.Abs(plane.Normal.Y) > 1e-9 || plane.Normal.Z <= 0 {
+			t.Errorf("plane %d normal = %+v, want (%g, 0, +)", p, plane.Normal, wantX)
+		}
+	}
+}
+
+func TestClusterRoofPlanesMergedCentroid(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.RoofClusterAngle = 60 // Wide enough to merge both slopes
+	const size = 4
+	planes := bc.ClusterRoofPlanes(gableRoof(size))
+	if len(planes) != 1 {
+		t.Fatalf("found %d roof planes, want 1", len(planes))
+	}
+
+	// Both slopes have the same area, so the centroid sits on the ridge
+	// line halfway down the slope
+	want := Vector3{0, size / 2.0, 10 - 0.25*size}
+	got := planes[0].Centroid
+	if math.Abs(got.X-want.X) > 1e-9 || math.Abs(got.Y-want.Y) > 1e-9 || math.Abs(got.Z-want.Z) > 1e-9 {
+		t.Errorf("merged centroid = %+v, want %+v", got, want)
+	}
+}
+
+func TestDryRunWritesNoFiles(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.OutputDir = t.TempDir()
+	bc.SummaryOnly = true
+	bc.DryRun = true
+	bc.ExportFaceAttrs = true
+	bc.ExportHeightFeats = true
+	bc.ManifestPath = filepath.Join(bc.OutputDir, "manifest.json")
+	vertices, faces := unitCube()
+	var obj strings.Builder
+	for _, v := range vertices {
+		fmt.Fprintf(&obj, "v %g %g %g\n", v.X, v.Y, v.Z)
+	}
+	for _, face := range faces {
+		fmt.Fprintf(&obj, "f %d %d %d %d\n", face[0]+1, face[1]+1, face[2]+1, face[3]+1)
+	}
+	if err := os.WriteFile(filepath.Join(bc.ObjDir, "cube.obj"), []byte(obj.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bc.ProcessAllBuildings(context.Background())
+	if err := bc.WriteExports(filepath.Join(bc.OutputDir, "faces.bin"), filepath.Join(bc.OutputDir, "heights.csv")); err != nil {
+		t.Fatalf("WriteExports: %v", err)
+	}
+
+	if bc.Stats.ProcessedFiles != 1 || len(bc.plannedFiles) == 0 {
+		t.Fatalf("dry run processed %d files and planned %d split files, want 1 and some", bc.Stats.ProcessedFiles, len(bc.plannedFiles))
+	}
+	entries, err := os.ReadDir(bc.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("dry run wrote %s", entry.Name())
+	}
+}
+
+func TestSharedWallFileFormat(t *testing.T) {
+	bc := newTestColorizer(t)
+	bc.OutputDir = t.TempDir()
+	bc.OutputFormat = OutputFormatGLTF
+	wall := cubeGroup(bc)
+	bc.wallGroups["cube"] = wall
+
+	if err := bc.writeSharedWallFile("cube", wall.Faces[2:4]); err != nil {
+		t.Fatalf("writeSharedWallFile: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(bc.OutputDir, "cube-shared.glb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 4 || binary.LittleEndian.Uint32(data) != glbMagic {
+		t.Errorf("cube-shared.glb does not start with the GLB magic")
+	}
+	for _, name := range []string{"cube-shared.obj", "cube-shared.mtl"} {
+		if _, err := os.Stat(filepath.Join(bc.OutputDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written for glTF output", name)
+		}
+	}
+}
+
+func TestSkipExistingUsesRecordedSplitFiles(t *testing.T) {
+	outputDir := t.TempDir()
+	run := func(bc *BuildingColorizer) *BuildingColorizer {
+		bc.OutputDir = outputDir
+		bc.OrientWalls = true
+		bc.SkipExisting = true
+		return bc
+	}
+
+	first := run(newTestColorizer(t))
+	vertices, faces := unitCube()
+	var obj strings.Builder
+	for _, v := range vertices {
+		fmt.Fprintf(&obj, "v %g %g %g\n", v.X, v.Y, v.Z)
+	}
+	for _, face := range faces {
+		fmt.Fprintf(&obj, "f %d %d %d %d\n", face[0]+1, face[1]+1, face[2]+1, face[3]+1)
+	}
+	objPath := filepath.Join(first.ObjDir, "cube.obj")
+	if err := os.WriteFile(objPath, []byte(obj.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(objPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	first.ProcessAllBuildings(context.Background())
+	if first.Stats.ProcessedFiles != 1 || len(first.ExpectedSplitFiles[objPath]) == 0 {
+		t.Fatalf("first run processed %d files with split files %v", first.Stats.ProcessedFiles, first.ExpectedSplitFiles[objPath])
+	}
+
+	second := run(newTestColorizer(t))
+	second.ObjDir = first.ObjDir
+	second.ProcessAllBuildings(context.Background())
+	if second.Stats.SkippedFiles != 1 {
+		t.Errorf("second run skipped %d files, want 1", second.Stats.SkippedFiles)
+	}
+
+	// A newer input is processed again
+	if err := os.Chtimes(objPath, time.Now().Add(time.Hour), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	third := run(newTestColorizer(t))
+	third.ObjDir = first.ObjDir
+	third.ProcessAllBuildings(context.Background())
+	if third.Stats.SkippedFiles != 0 || third.Stats.ProcessedFiles != 1 {
+		t.Errorf("third run skipped %d and processed %d files, want 0 and 1", third.Stats.SkippedFiles, third.Stats.ProcessedFiles)
+	}
+}
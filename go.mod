@@ -2,4 +2,15 @@ module citygml-gen
 
 go 1.24.1
 
-require github.com/lukeroth/gdal v0.0.0-20240301124940-d4ff2229365e
+require (
+	github.com/lib/pq v1.12.3
+	github.com/lukeroth/gdal v0.0.0-20240301124940-d4ff2229365e
+	golang.org/x/net v0.38.0
+	golang.org/x/term v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+)
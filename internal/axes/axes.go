@@ -0,0 +1,77 @@
+// Package axes parses the --xyz-swap output axis order shared by the tools
+// and applies it to output coordinates.
+package axes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permutation gives, for each output axis, the input axis it is taken from:
+// XZY is {0, 2, 1}.
+type Permutation [3]int
+
+// Identity keeps the input axis order
+var Identity = Permutation{0, 1, 2}
+
+// Parse parses a permutation string such as "XZY"
+func Parse(permutation string) (Permutation, error) {
+	var perm Permutation
+	permutation = strings.ToUpper(strings.TrimSpace(permutation))
+	if len(permutation) != 3 {
+		return perm, fmt.Errorf("invalid axis permutation '%s': must be 3 characters (e.g. XZY)", permutation)
+	}
+
+	seen := make(map[byte]bool)
+	for i := 0; i < 3; i++ {
+		axis := permutation[i]
+		if seen[axis] {
+			return perm, fmt.Errorf("invalid axis permutation '%s': axis %c used more than once", permutation, axis)
+		}
+		seen[axis] = true
+
+		switch axis {
+		case 'X':
+			perm[i] = 0
+		case 'Y':
+			perm[i] = 1
+		case 'Z':
+			perm[i] = 2
+		default:
+			return perm, fmt.Errorf("invalid axis permutation '%s': unknown axis %c", permutation, axis)
+		}
+	}
+
+	return perm, nil
+}
+
+// Apply returns x, y and z reordered according to p
+func (p Permutation) Apply(x, y, z float64) (float64, float64, float64) {
+	coords := [3]float64{x, y, z}
+	return coords[p[0]], coords[p[1]], coords[p[2]]
+}
+
+// Odd reports whether p swaps an odd number of axes (XZY, YXZ, ZYX). Such
+// a permutation mirrors the geometry, so faces must have their vertex order
+// reversed to keep their normals pointing outwards.
+func (p Permutation) Odd() bool {
+	inversions := 0
+	for i := 0; i < 3; i++ {
+		for j := i + 1; j < 3; j++ {
+			if p[i] > p[j] {
+				inversions++
+			}
+		}
+	}
+	return inversions%2 == 1
+}
+
+// NormalLine returns an OBJ "vn x y z" line with its components reordered
+// according to p. Other lines are returned unchanged.
+func (p Permutation) NormalLine(line string) string {
+	fields := strings.Fields(line)
+	if p == Identity || len(fields) != 4 || fields[0] != "vn" {
+		return line
+	}
+	return "vn " + fields[1+p[0]] + " " + fields[1+p[1]] + " " + fields[1+p[2]]
+}
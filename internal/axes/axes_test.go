@@ -0,0 +1,39 @@
+package axes
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Permutation
+		odd  bool
+	}{
+		{"XYZ", Identity, false},
+		{"xzy", Permutation{0, 2, 1}, true},
+		{"YXZ", Permutation{1, 0, 2}, true},
+		{"ZYX", Permutation{2, 1, 0}, true},
+		{"YZX", Permutation{1, 2, 0}, false},
+		{"ZXY", Permutation{2, 0, 1}, false},
+	} {
+		perm, err := Parse(tc.in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.in, err)
+		}
+		if perm != tc.want || perm.Odd() != tc.odd {
+			t.Errorf("Parse(%q) = %v odd=%t, want %v odd=%t", tc.in, perm, perm.Odd(), tc.want, tc.odd)
+		}
+	}
+
+	for _, in := range []string{"XY", "XXZ", "XYW"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	x, y, z := Permutation{0, 2, 1}.Apply(1, 2, 3)
+	if x != 1 || y != 3 || z != 2 {
+		t.Errorf("XZY.Apply(1, 2, 3) = %g, %g, %g, want 1, 3, 2", x, y, z)
+	}
+}
@@ -0,0 +1,190 @@
+// Package config reads the --config files of the tools. A config file is a
+// JSON or YAML object whose keys are the long flag names, written with
+// underscores or dashes (obj_dir or obj-dir). Each tool decodes it into a
+// plain struct whose yaml tags mirror its flags; flags given on the command
+// line take precedence over the file.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// List is the config value of a flag taking comma-separated values. It may
+// be written as a list or as one comma-separated string.
+type List []string
+
+var listType = reflect.TypeOf(List(nil))
+
+// Load reads the config file at path into cfg, a pointer to a struct whose
+// yaml tags name the options the file may set, and returns the options it
+// set. Unknown keys and values of the wrong type are errors; a key without
+// a value leaves the option unset.
+func Load(path string, cfg interface{}) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file '%s': %v", path, err)
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		err = decoder.Decode(&values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file '%s': %v", path, err)
+	}
+
+	target := reflect.ValueOf(cfg).Elem()
+	fields := make(map[string]int)
+	for i := 0; i < target.NumField(); i++ {
+		fields[Key(target.Type().Field(i))] = i
+	}
+
+	present := make(map[string]bool)
+	for key, value := range values {
+		name := strings.ReplaceAll(key, "-", "_")
+		index, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown option '%s' in config file '%s'", key, path)
+		}
+		if value == nil {
+			continue
+		}
+		if err := setField(target.Field(index), value); err != nil {
+			return nil, fmt.Errorf("invalid value for '%s' in config file '%s': %v", key, path, err)
+		}
+		present[name] = true
+	}
+	return present, nil
+}
+
+// Key returns the config key of a struct field: its yaml tag name, or the
+// lower-cased field name when it has none
+func Key(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
+
+// setField stores a decoded JSON or YAML value in field
+func setField(field reflect.Value, value interface{}) error {
+	if field.Type() == listType {
+		var items []string
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+		case string:
+			items = strings.Split(v, ",")
+		default:
+			return fmt.Errorf("expected a list or comma-separated string, got %v", value)
+		}
+		field.Set(reflect.ValueOf(List(items)))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf("expected a string, got %v", value)
+		}
+		field.SetString(fmt.Sprint(value))
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected true or false, got %v", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(fmt.Sprint(value), 10, 64)
+		if err != nil || isString(value) {
+			return fmt.Errorf("expected an integer, got %v", value)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+		if err != nil || isString(value) {
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported option type %s", field.Type())
+	}
+	return nil
+}
+
+// isString reports whether value was written as a string rather than as a
+// number. JSON numbers decode as json.Number, which is not a string here.
+func isString(value interface{}) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+// Apply sets the flag of fs behind each option in present, as returned by
+// Load, to its value in cfg. Flags given on the command line are left
+// alone, so Apply runs after fs has been parsed.
+func Apply(fs *flag.FlagSet, cfg interface{}, present map[string]bool) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	source := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < source.NumField(); i++ {
+		key := Key(source.Type().Field(i))
+		if !present[key] {
+			continue
+		}
+		name := strings.ReplaceAll(key, "_", "-")
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("config option '%s' has no --%s flag", key, name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		value := source.Field(i)
+		text := fmt.Sprint(value.Interface())
+		switch {
+		case value.Type() == listType:
+			text = strings.Join(value.Interface().(List), ",")
+		case value.Kind() == reflect.Float64:
+			text = strconv.FormatFloat(value.Float(), 'g', -1, 64)
+		}
+		if err := f.Value.Set(text); err != nil {
+			return fmt.Errorf("invalid value for '%s' in config file: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// MissingError reports required options that were given neither on the
+// command line nor in the config file at path. Each name is a flag name
+// without dashes; alternatives are joined with " or ", e.g. "dtm or dtm-dir".
+func MissingError(path string, names ...string) error {
+	flags := make([]string, len(names))
+	keys := make([]string, len(names))
+	for i, name := range names {
+		flags[i] = "--" + strings.ReplaceAll(name, " or ", " or --")
+		keys[i] = strings.ReplaceAll(name, "-", "_")
+	}
+	return fmt.Errorf("missing required options: %s (set them on the command line or as %s in config file '%s')",
+		strings.Join(flags, ", "), strings.Join(keys, ", "), path)
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	ObjDir  string  `yaml:"obj_dir"`
+	Workers int     `yaml:"workers"`
+	Debug   bool    `yaml:"debug"`
+	ZMin    float64 `yaml:"z_min"`
+	Attrs   List    `yaml:"attrs"`
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAndApply(t *testing.T) {
+	for _, tc := range []struct{ name, content string }{
+		{"config.yaml", "obj_dir: from-file\nworkers: 3\ndebug: true\nz_min: -.inf\nattrs: [a, b]\n"},
+		{"config.json", `{"obj-dir": "from-file", "workers": 3, "debug": true, "z_min": -2.5, "attrs": "a,b"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg testConfig
+			present, err := Load(writeConfig(t, tc.name, tc.content), &cfg)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			objDir := fs.String("obj-dir", "", "")
+			workers := fs.Int("workers", 1, "")
+			debug := fs.Bool("debug", false, "")
+			zMin := fs.Float64("z-min", 0, "")
+			attrs := fs.String("attrs", "", "")
+			if err := fs.Parse([]string{"--workers", "8"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := Apply(fs, &cfg, present); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			if *objDir != "from-file" || !*debug || *attrs != "a,b" || *zMin > -2 {
+				t.Errorf("config values not applied: obj-dir=%q debug=%t attrs=%q z-min=%g", *objDir, *debug, *attrs, *zMin)
+			}
+			if *workers != 8 {
+				t.Errorf("workers = %d, want the command-line value 8", *workers)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct{ content, want string }{
+		{"obj_dir: x\nunknown: 1\n", "unknown option 'unknown'"},
+		{"workers: many\n", "invalid value for 'workers'"},
+		{"debug: yes please\n", "invalid value for 'debug'"},
+	} {
+		var cfg testConfig
+		_, err := Load(writeConfig(t, "config.yaml", tc.content), &cfg)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%q) error = %v, want it to contain %q", tc.content, err, tc.want)
+		}
+	}
+}
+
+func TestMissingError(t *testing.T) {
+	got := MissingError("run.yaml", "obj-dir", "dtm or dtm-dir").Error()
+	want := "missing required options: --obj-dir, --dtm or --dtm-dir (set them on the command line or as obj_dir, dtm or dtm_dir in config file 'run.yaml')"
+	if got != want {
+		t.Errorf("MissingError =\n%s\nwant\n%s", got, want)
+	}
+}
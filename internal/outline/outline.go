@@ -0,0 +1,40 @@
+// Package outline tests points against the X/Y building and district
+// outlines the tools load from GeoJSON.
+package outline
+
+// Polygon is an X/Y outline ring with optional holes. Coordinates beyond X
+// and Y are ignored.
+type Polygon struct {
+	Coordinates [][]float64   // Exterior ring
+	Holes       [][][]float64 // Interior rings
+}
+
+// Contains reports whether (x, y) lies inside the exterior ring of p and
+// outside every hole
+func (p Polygon) Contains(x, y float64) bool {
+	if !PointInRing(x, y, p.Coordinates) {
+		return false
+	}
+	for _, hole := range p.Holes {
+		if PointInRing(x, y, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// PointInRing reports whether (x, y) lies inside ring using ray casting
+func PointInRing(x, y float64, ring [][]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		if len(ring[i]) < 2 || len(ring[j]) < 2 {
+			continue
+		}
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,23 @@
+package outline
+
+import "testing"
+
+func TestContains(t *testing.T) {
+	square := Polygon{
+		Coordinates: [][]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		Holes:       [][][]float64{{{4, 4, 0}, {6, 4, 0}, {6, 6, 0}, {4, 6, 0}}},
+	}
+	for _, tc := range []struct {
+		x, y float64
+		want bool
+	}{
+		{1, 1, true},
+		{5, 5, false}, // In the hole
+		{11, 5, false},
+		{-1, -1, false},
+	} {
+		if got := square.Contains(tc.x, tc.y); got != tc.want {
+			t.Errorf("Contains(%g, %g) = %t, want %t", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Package progress draws the per-file progress bar shared by the tools.
+package progress
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Bar draws an in-place "[=====>    ] 412/1000 (41%) ETA 3m12s" line on
+// standard error as files complete. Nothing is drawn when standard error is
+// not a terminal.
+type Bar struct {
+	Total int // Files to process
+	Done  int // Files completed so far
+
+	start   time.Time
+	enabled bool
+}
+
+// New creates a progress bar for total files. It stays hidden when show is
+// false or standard error is not a terminal.
+func New(total int, show bool) *Bar {
+	return &Bar{
+		Total:   total,
+		start:   time.Now(),
+		enabled: show && total > 0 && term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+// Increment records one completed file and redraws the bar
+func (p *Bar) Increment() {
+	p.Done++
+	if !p.enabled {
+		return
+	}
+
+	const width = 30
+	filled := width * p.Done / p.Total
+	if filled > width {
+		filled = width
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+
+	var eta time.Duration
+	if p.Done < p.Total {
+		eta = time.Since(p.start) / time.Duration(p.Done) * time.Duration(p.Total-p.Done)
+	}
+	// \033[K clears the rest of a longer previous line
+	fmt.Fprintf(os.Stderr, "\r[%s] %d/%d (%d%%) ETA %s\033[K", bar, p.Done, p.Total, 100*p.Done/p.Total, eta.Round(time.Second))
+}
+
+// Finish ends the bar's line so that later output starts on a new line
+func (p *Bar) Finish() {
+	if p.enabled && p.Done > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -0,0 +1,11 @@
+// Package units holds the --obj-units length units shared by the tools.
+package units
+
+// Scales maps the supported --obj-units values to their factor to metres
+var Scales = map[string]float64{
+	"mm": 0.001,
+	"cm": 0.01,
+	"m":  1,
+	"ft": 0.3048,
+	"in": 0.0254,
+}
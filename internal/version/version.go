@@ -0,0 +1,76 @@
+// Package version checks the latest published release of the tools for
+// --version-check.
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// releasesURL is the GitHub API endpoint for the latest published release
+const releasesURL = "https://api.github.com/repos/DhiasNaufal/converter-docker/releases/latest"
+
+// CheckForUpdate queries the latest GitHub release and reports whether a
+// version newer than current is available
+func CheckForUpdate(current string) string {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(releasesURL)
+	if err != nil {
+		return fmt.Sprintf("Version check failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("Version check failed: %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return fmt.Sprintf("Version check failed: %v", err)
+	}
+
+	if compareVersions(release.TagName, current) > 0 {
+		return fmt.Sprintf("Update available: v%s", strings.TrimPrefix(release.TagName, "v"))
+	}
+	return "You are up to date"
+}
+
+// compareVersions compares two semantic versions (major.minor.patch, optional
+// "v" prefix) and returns -1, 0 or 1. Pre-release suffixes are ignored.
+func compareVersions(a, b string) int {
+	partsA := strings.Split(strings.TrimPrefix(strings.TrimSpace(a), "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(strings.TrimSpace(b), "v"), ".")
+
+	for i := 0; i < 3; i++ {
+		na, nb := versionPart(partsA, i), versionPart(partsB, i)
+		if na < nb {
+			return -1
+		}
+		if na > nb {
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionPart returns the numeric value of the i-th version component, or 0 if absent
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	part := parts[i]
+	if idx := strings.IndexAny(part, "-+"); idx != -1 {
+		part = part[:idx]
+	}
+	n, err := strconv.Atoi(part)
+	if err != nil {
+		return 0
+	}
+	return n
+}
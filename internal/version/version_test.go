@@ -0,0 +1,20 @@
+package version
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.2.0", "1.1.9", 1},
+		{"1.0", "1.0.1", -1},
+		{"v2.0.0-rc1", "2.0.0", 0},
+		{" 10.0.0 ", "9.9.9", 1},
+	} {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}